@@ -0,0 +1,237 @@
+package auth
+
+import (
+	"errors"
+	"strconv"
+	"strings"
+	"time"
+
+	"backend-meta-data/models"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/golang-jwt/jwt/v5"
+	log "github.com/sirupsen/logrus"
+	"gorm.io/gorm"
+)
+
+const (
+	// Purposes of the tokens mailed by RequestPasswordReset and RequestEmailVerification
+	purposePasswordReset = "password_reset"
+	purposeVerifyEmail   = "verify_email"
+	// accountMailInterval is the least time between two emails of one kind to a user
+	accountMailInterval = time.Minute
+)
+
+var (
+	// ErrInvalidAccountToken is returned for a reset or verification link that
+	// is malformed, expired or already used
+	ErrInvalidAccountToken = errors.New("invalid or expired link")
+	// ErrNoEmail is returned when asking to verify an account without an email address
+	ErrNoEmail = errors.New("account has no email address")
+	// ErrEmailVerified is returned when the email address is already verified
+	ErrEmailVerified = errors.New("email address is already verified")
+	// ErrPasswordRequired is returned by ResetPassword for an empty password
+	ErrPasswordRequired = errors.New("password is required")
+)
+
+// AccountMail is a link to email to a user; the controller renders and sends it
+type AccountMail struct {
+	User      *models.User
+	To        string
+	Token     string
+	ExpiresAt time.Time
+}
+
+// accountToken signs a single-use token for purpose that expires after ttl
+func (s *Service) accountToken(user *models.User, purpose string, ttl time.Duration) (*AccountMail, error) {
+	now := time.Now()
+	claims := &Claims{
+		Username: user.Username,
+		Purpose:  purpose,
+		RegisteredClaims: jwt.RegisteredClaims{
+			ID:        randomToken(16),
+			Subject:   strconv.FormatUint(uint64(user.ID), 10),
+			IssuedAt:  jwt.NewNumericDate(now),
+			ExpiresAt: jwt.NewNumericDate(now.Add(ttl)),
+		},
+	}
+	if purpose == purposeVerifyEmail {
+		claims.Email = user.Email
+	}
+	token, err := jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString([]byte(s.cfg.JWTSecret))
+	if err != nil {
+		return nil, err
+	}
+	return &AccountMail{User: user, To: user.Email, Token: token, ExpiresAt: claims.ExpiresAt.Time}, nil
+}
+
+// userFromAccountToken checks a token from accountToken without using it up
+func (s *Service) userFromAccountToken(raw, purpose string) (*models.User, *Claims, error) {
+	claims, err := s.parseClaims(raw)
+	if err != nil || claims.Purpose != purpose {
+		return nil, nil, ErrInvalidAccountToken
+	}
+	revoked, err := models.IsAccessTokenRevoked(s.db, claims.ID)
+	if err != nil {
+		return nil, nil, err
+	}
+	if revoked {
+		return nil, nil, ErrInvalidAccountToken
+	}
+	id, err := claims.UserID()
+	if err != nil {
+		return nil, nil, ErrInvalidAccountToken
+	}
+	user, err := lookup(models.FindUserByID(s.db, id))
+	if errors.Is(err, ErrUnauthenticated) {
+		return nil, nil, ErrInvalidAccountToken
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+	if err := user.CanLogin(); err != nil {
+		return nil, nil, err
+	}
+	return user, claims, nil
+}
+
+// useAccountToken denylists the token so no other request can use it
+func (s *Service) useAccountToken(user *models.User, claims *Claims) error {
+	ok, err := models.UseToken(s.db, claims.ID, user.ID, claims.ExpiresAt.Time)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return ErrInvalidAccountToken
+	}
+	return nil
+}
+
+// mailAllowed reports whether user may be sent another purpose email now,
+// recording the send so the next one waits accountMailInterval
+func (s *Service) mailAllowed(user *models.User, purpose string) (bool, error) {
+	return models.ClaimAccountMail(s.db, user.ID, purpose, time.Now(), accountMailInterval)
+}
+
+// RequestPasswordReset returns a reset link for the active local account whose
+// username or email address is login. It returns nil, nil when there is nothing
+// to send: unknown, disabled or directory accounts, accounts without a verified
+// email, an email shared by several accounts, or a link already sent within the
+// last minute. Callers answer the same either way, so accounts cannot be probed.
+func (s *Service) RequestPasswordReset(c *fiber.Ctx, login string) (*AccountMail, error) {
+	login = strings.TrimSpace(login)
+	if login == "" {
+		return nil, nil
+	}
+	user, err := models.FindUserByUsername(s.db, login)
+	if errors.Is(err, gorm.ErrRecordNotFound) && strings.Contains(login, "@") {
+		users, ferr := models.FindUsersByEmail(s.db, login)
+		if ferr != nil {
+			return nil, ferr
+		}
+		if len(users) == 1 {
+			user, err = &users[0], nil
+		}
+	}
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		log.Infof("auth: password reset requested for unknown account %q from %s", login, c.IP())
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if user.Source != "local" || user.Email == "" || user.EmailVerifiedAt == nil || user.CanLogin() != nil {
+		log.Infof("auth: password reset for %s not sent: no active local account with a verified email", user.Username)
+		return nil, nil
+	}
+	if ok, err := s.mailAllowed(user, purposePasswordReset); err != nil || !ok {
+		return nil, err
+	}
+	mail, err := s.accountToken(user, purposePasswordReset, s.cfg.PasswordResetTTL)
+	if err != nil {
+		return nil, err
+	}
+	s.logActivity(c, user, "password_reset_requested", "")
+	return mail, nil
+}
+
+// ResetPassword sets the password of the account a reset link was sent to.
+// The link is used up, every session and token of the user ends, and a lock
+// from failed logins is lifted.
+func (s *Service) ResetPassword(c *fiber.Ctx, raw, plain string) (*models.User, error) {
+	user, claims, err := s.userFromAccountToken(raw, purposePasswordReset)
+	if err != nil {
+		return nil, err
+	}
+	// A completed reset or "log out everywhere" voids links sent before it
+	if user.Source != "local" || (user.SessionsRevokedAt != nil && claims.IssuedAt.Unix() < user.SessionsRevokedAt.Unix()) {
+		return nil, ErrInvalidAccountToken
+	}
+	if plain == "" {
+		return nil, ErrPasswordRequired
+	}
+	if err := s.useAccountToken(user, claims); err != nil {
+		return nil, err
+	}
+	if err := models.UpdatePassword(s.db, user, plain); err != nil {
+		return nil, err
+	}
+	if err := models.EndUserSessions(s.db, user.ID, time.Now()); err != nil {
+		return nil, err
+	}
+	if err := models.UnlockUser(s.db, user.ID); err != nil {
+		return nil, err
+	}
+	if err := models.ClearLoginFailures(s.db, UserThrottleKey(user.Username)); err != nil {
+		return nil, err
+	}
+	s.logActivity(c, user, "password_reset", "")
+	return user, nil
+}
+
+// RequestEmailVerification returns a link confirming user's email address.
+// A link already sent within the last minute is reported as a ThrottledError.
+func (s *Service) RequestEmailVerification(c *fiber.Ctx, user *models.User) (*AccountMail, error) {
+	if user.Email == "" {
+		return nil, ErrNoEmail
+	}
+	if user.EmailVerifiedAt != nil {
+		return nil, ErrEmailVerified
+	}
+	ok, err := s.mailAllowed(user, purposeVerifyEmail)
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		return nil, &ThrottledError{RetryAfter: accountMailInterval}
+	}
+	mail, err := s.accountToken(user, purposeVerifyEmail, s.cfg.EmailVerifyTTL)
+	if err != nil {
+		return nil, err
+	}
+	s.logActivity(c, user, "email_verification_requested", user.Email)
+	return mail, nil
+}
+
+// VerifyEmail marks the address a verification link was sent to as verified,
+// as long as it is still the user's email
+func (s *Service) VerifyEmail(c *fiber.Ctx, raw string) (*models.User, error) {
+	user, claims, err := s.userFromAccountToken(raw, purposeVerifyEmail)
+	if err != nil {
+		return nil, err
+	}
+	if claims.Email == "" || !strings.EqualFold(claims.Email, user.Email) {
+		return nil, ErrInvalidAccountToken
+	}
+	if err := s.useAccountToken(user, claims); err != nil {
+		return nil, err
+	}
+	if err := models.SetEmailVerified(s.db, user, user.Email, time.Now()); err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrInvalidAccountToken
+		}
+		return nil, err
+	}
+	s.logActivity(c, user, "email_verified", user.Email)
+	return user, nil
+}
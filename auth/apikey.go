@@ -0,0 +1,127 @@
+package auth
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+
+	"backend-meta-data/models"
+
+	"github.com/gofiber/fiber/v2"
+	log "github.com/sirupsen/logrus"
+	"gorm.io/gorm"
+)
+
+// APIKeyHeader carries an API key instead of a Bearer token or session
+const APIKeyHeader = "X-API-Key"
+
+// apiKeyPrefix starts every key so leaked keys are easy to recognise
+const apiKeyPrefix = "fnk_"
+
+// apiKeyTouchInterval limits how often last_used_at is written for a busy key
+const apiKeyTouchInterval = time.Minute
+
+var (
+	// ErrAPIKeyScope is returned by Identify when the key's scopes do not cover the request
+	ErrAPIKeyScope = errors.New("API key scope does not allow this request")
+	// ErrInvalidScope is returned by CreateAPIKey for a malformed scope
+	ErrInvalidScope = errors.New("invalid API key scope")
+)
+
+// scopePattern is resource:access, where resource is the segment after /api/
+// (e.g. instruments, station) or *
+var scopePattern = regexp.MustCompile(`^(\*|[a-z0-9][a-z0-9_-]*):(read|write)$`)
+
+// NormalizeScopes validates scopes and returns them trimmed and lowercased
+func NormalizeScopes(scopes []string) ([]string, error) {
+	var out []string
+	for _, s := range scopes {
+		s = strings.ToLower(strings.TrimSpace(s))
+		if s == "" {
+			continue
+		}
+		if !scopePattern.MatchString(s) {
+			return nil, fmt.Errorf("%w: %q is not resource:read or resource:write", ErrInvalidScope, s)
+		}
+		out = append(out, s)
+	}
+	if len(out) == 0 {
+		return nil, fmt.Errorf("%w: at least one scope is required", ErrInvalidScope)
+	}
+	return out, nil
+}
+
+// ScopeAllows reports whether a comma separated scope list covers method on
+// path. Keys only reach /api routes; read allows GET, HEAD and OPTIONS, write
+// allows every method.
+func ScopeAllows(scopes, method, path string) bool {
+	rest, ok := strings.CutPrefix(path, "/api/")
+	if !ok {
+		return false
+	}
+	resource, _, _ := strings.Cut(rest, "/")
+	resource, _, _ = strings.Cut(resource, "?")
+	read := method == fiber.MethodGet || method == fiber.MethodHead || method == fiber.MethodOptions
+	for _, s := range strings.Split(scopes, ",") {
+		res, access, _ := strings.Cut(strings.TrimSpace(s), ":")
+		if res != "*" && res != resource {
+			continue
+		}
+		if access == "write" || (access == "read" && read) {
+			return true
+		}
+	}
+	return false
+}
+
+// CreateAPIKey issues a key for user. ttl is capped at [auth] api_key_ttl,
+// which is also the default when ttl is 0. The raw key is only returned here.
+func (s *Service) CreateAPIKey(user *models.User, name string, scopes []string, ttl time.Duration) (string, *models.APIKey, error) {
+	scopes, err := NormalizeScopes(scopes)
+	if err != nil {
+		return "", nil, err
+	}
+	if ttl <= 0 || ttl > s.cfg.APIKeyTTL {
+		ttl = s.cfg.APIKeyTTL
+	}
+	raw := apiKeyPrefix + randomToken(24)
+	key := &models.APIKey{
+		UserID:    user.ID,
+		Name:      name,
+		Prefix:    raw[:len(apiKeyPrefix)+8],
+		KeyHash:   hashToken(raw),
+		Scopes:    strings.Join(scopes, ","),
+		ExpiresAt: time.Now().Add(ttl),
+	}
+	if err := models.CreateAPIKey(s.db, key); err != nil {
+		return "", nil, err
+	}
+	return raw, key, nil
+}
+
+// userFromAPIKey resolves the owner of an active key whose scopes cover the request
+func (s *Service) userFromAPIKey(c *fiber.Ctx, raw string) (*models.User, error) {
+	key, err := models.FindAPIKey(s.db, hashToken(raw))
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, ErrUnauthenticated
+	}
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now()
+	if key.RevokedAt != nil || now.After(key.ExpiresAt) {
+		return nil, ErrUnauthenticated
+	}
+	if !ScopeAllows(key.Scopes, c.Method(), c.Path()) {
+		return nil, ErrAPIKeyScope
+	}
+	if key.LastUsedAt == nil || now.Sub(*key.LastUsedAt) >= apiKeyTouchInterval || key.LastUsedIP != c.IP() {
+		if err := models.TouchAPIKey(s.db, key.ID, c.IP(), now); err != nil {
+			log.Warnf("auth: recording use of API key %s failed: %v", key.Prefix, err)
+		}
+	}
+	c.Locals("apiKey", key)
+	return lookup(models.FindUserByID(s.db, key.UserID))
+}
@@ -0,0 +1,227 @@
+// Package directory finds users in LDAP / Active Directory. A service account
+// searches for the entry matching the login name (see [ldap] user_filter) and
+// the password is then checked by binding as the DN that was found, so no DN
+// is ever assembled from user input. Connections bound as the service account
+// are pooled.
+package directory
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"net"
+	"net/url"
+	"os"
+	"strings"
+
+	"backend-meta-data/config"
+
+	"github.com/go-ldap/ldap/v3"
+)
+
+var (
+	// ErrNotFound is returned when no entry matches the login name
+	ErrNotFound = errors.New("directory: user not found")
+	// ErrInvalidCredentials is returned when the bind as the user fails
+	ErrInvalidCredentials = errors.New("directory: invalid credentials")
+)
+
+// Entry is the directory data mapped onto models.User
+type Entry struct {
+	DN          string
+	Username    string
+	Email       string
+	DisplayName string
+	// Groups holds the memberOf DNs
+	Groups []string
+}
+
+// roleRank orders the roles [ldap.group_roles] can grant, lowest first
+var roleRank = map[string]int{"Inspector": 1, "Admin": 2, "Root": 3}
+
+// Role returns the highest role groupRoles grants to the entry's groups; ok is
+// false when none of them is mapped. Group DNs compare case-insensitively.
+func (e *Entry) Role(groupRoles map[string]string) (role string, ok bool) {
+	for group, r := range groupRoles {
+		for _, g := range e.Groups {
+			if strings.EqualFold(g, group) && roleRank[r] > roleRank[role] {
+				role = r
+			}
+		}
+	}
+	return role, role != ""
+}
+
+// Directory searches and authenticates users; it is safe for concurrent use
+type Directory struct {
+	cfg  config.LDAPConfig
+	tls  *tls.Config
+	pool chan *ldap.Conn
+}
+
+// New prepares a directory for cfg; connections are opened on first use
+func New(cfg config.LDAPConfig) (*Directory, error) {
+	u, err := url.Parse(cfg.URL)
+	if err != nil {
+		return nil, fmt.Errorf("directory: %w", err)
+	}
+	tc := &tls.Config{
+		ServerName:         u.Hostname(),
+		MinVersion:         tls.VersionTLS12,
+		InsecureSkipVerify: cfg.InsecureSkipVerify, // refused outside local/dev by config validation
+	}
+	if cfg.CACert != "" {
+		pem, err := os.ReadFile(cfg.CACert)
+		if err != nil {
+			return nil, fmt.Errorf("directory: %w", err)
+		}
+		pool, err := x509.SystemCertPool()
+		if err != nil {
+			pool = x509.NewCertPool()
+		}
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("directory: no certificates in %s", cfg.CACert)
+		}
+		tc.RootCAs = pool
+	}
+	return &Directory{cfg: cfg, tls: tc, pool: make(chan *ldap.Conn, cfg.PoolSize)}, nil
+}
+
+// Close closes the idle pooled connections
+func (d *Directory) Close() {
+	for {
+		select {
+		case conn := <-d.pool:
+			conn.Close()
+		default:
+			return
+		}
+	}
+}
+
+// Authenticate finds username and checks password by binding as its DN
+func (d *Directory) Authenticate(username, password string) (*Entry, error) {
+	// An empty password is an unauthenticated bind, which AD accepts
+	if username == "" || password == "" {
+		return nil, ErrInvalidCredentials
+	}
+	conn, err := d.get()
+	if err != nil {
+		return nil, err
+	}
+	entry, err := d.search(conn, username)
+	if err != nil {
+		d.put(conn, err)
+		return nil, err
+	}
+	if err := conn.Bind(entry.DN, password); err != nil {
+		if ldap.IsErrorWithCode(err, ldap.LDAPResultInvalidCredentials) {
+			// Rebind as the service account before the connection goes back to the pool
+			d.put(conn, d.bindService(conn))
+			return nil, ErrInvalidCredentials
+		}
+		d.put(conn, err)
+		return nil, fmt.Errorf("directory: bind as %s: %w", entry.DN, err)
+	}
+	d.put(conn, d.bindService(conn))
+	return entry, nil
+}
+
+// Lookup finds username without checking a password
+func (d *Directory) Lookup(username string) (*Entry, error) {
+	conn, err := d.get()
+	if err != nil {
+		return nil, err
+	}
+	entry, err := d.search(conn, username)
+	d.put(conn, err)
+	return entry, err
+}
+
+func (d *Directory) search(conn *ldap.Conn, username string) (*Entry, error) {
+	filter := strings.ReplaceAll(d.cfg.UserFilter, "{username}", ldap.EscapeFilter(username))
+	attrs := []string{d.cfg.UsernameAttr, "uid", d.cfg.EmailAttr, d.cfg.DisplayNameAttr, "memberOf"}
+	req := ldap.NewSearchRequest(d.cfg.BaseDN, ldap.ScopeWholeSubtree, ldap.NeverDerefAliases,
+		2, int(d.cfg.Timeout.Seconds()), false, filter, attrs, nil)
+	res, err := conn.Search(req)
+	if err != nil && !ldap.IsErrorWithCode(err, ldap.LDAPResultSizeLimitExceeded) {
+		return nil, fmt.Errorf("directory: search %s: %w", filter, err)
+	}
+	switch {
+	case res == nil || len(res.Entries) == 0:
+		return nil, ErrNotFound
+	case len(res.Entries) > 1:
+		return nil, fmt.Errorf("directory: %q matches more than one entry", username)
+	}
+	e := res.Entries[0]
+	entry := &Entry{
+		DN:          e.DN,
+		Username:    e.GetAttributeValue(d.cfg.UsernameAttr),
+		Email:       e.GetAttributeValue(d.cfg.EmailAttr),
+		DisplayName: e.GetAttributeValue(d.cfg.DisplayNameAttr),
+		Groups:      e.GetAttributeValues("memberOf"),
+	}
+	if entry.Username == "" {
+		entry.Username = e.GetAttributeValue("uid")
+	}
+	if entry.Username == "" {
+		entry.Username = username
+	}
+	return entry, nil
+}
+
+// get returns a pooled connection bound as the service account, or a new one
+func (d *Directory) get() (*ldap.Conn, error) {
+	for {
+		select {
+		case conn := <-d.pool:
+			if !conn.IsClosing() {
+				return conn, nil
+			}
+		default:
+			return d.dial()
+		}
+	}
+}
+
+// put returns conn to the pool, or closes it after an error or when the pool is full
+func (d *Directory) put(conn *ldap.Conn, err error) {
+	if err != nil && !errors.Is(err, ErrNotFound) {
+		conn.Close()
+		return
+	}
+	select {
+	case d.pool <- conn:
+	default:
+		conn.Close()
+	}
+}
+
+func (d *Directory) dial() (*ldap.Conn, error) {
+	conn, err := ldap.DialURL(d.cfg.URL,
+		ldap.DialWithTLSConfig(d.tls),
+		ldap.DialWithDialer(&net.Dialer{Timeout: d.cfg.Timeout}))
+	if err != nil {
+		return nil, fmt.Errorf("directory: connect %s: %w", d.cfg.URL, err)
+	}
+	conn.SetTimeout(d.cfg.Timeout)
+	if d.cfg.StartTLS {
+		if err := conn.StartTLS(d.tls); err != nil {
+			conn.Close()
+			return nil, fmt.Errorf("directory: StartTLS: %w", err)
+		}
+	}
+	if err := d.bindService(conn); err != nil {
+		conn.Close()
+		return nil, err
+	}
+	return conn, nil
+}
+
+func (d *Directory) bindService(conn *ldap.Conn) error {
+	if err := conn.Bind(d.cfg.ServiceUser, d.cfg.ServicePass); err != nil {
+		return fmt.Errorf("directory: service account bind: %w", err)
+	}
+	return nil
+}
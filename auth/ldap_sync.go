@@ -0,0 +1,111 @@
+package auth
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"backend-meta-data/auth/directory"
+	"backend-meta-data/config"
+	"backend-meta-data/models"
+
+	log "github.com/sirupsen/logrus"
+	"gorm.io/gorm"
+)
+
+// groupRole is the role [ldap.group_roles] maps entry's groups to, or
+// default_role when none of them is mapped; ok is false when no group roles
+// are configured, so roles are managed locally
+func groupRole(cfg config.LDAPConfig, entry *directory.Entry) (role string, ok bool) {
+	if len(cfg.GroupRoles) == 0 {
+		return "", false
+	}
+	if role, ok := entry.Role(cfg.GroupRoles); ok {
+		return role, true
+	}
+	return cfg.DefaultRole, true
+}
+
+// applyGroupRole gives user their groupRole in both Users.Role and Casbin, so
+// a user removed from every mapped group drops to default_role
+func applyGroupRole(db *gorm.DB, cfg config.LDAPConfig, setRole func(username, role string) error, user *models.User, entry *directory.Entry) error {
+	role, ok := groupRole(cfg, entry)
+	if !ok || role == user.Role {
+		return nil
+	}
+	log.Infof("auth: %s moves from %s to %s by LDAP group", user.Username, user.Role, role)
+	if err := models.SetUserRole(db, user, role); err != nil {
+		return err
+	}
+	if setRole != nil {
+		if err := setRole(user.Username, role); err != nil {
+			return fmt.Errorf("assigning %s to %s: %w", role, user.Username, err)
+		}
+	}
+	return nil
+}
+
+// LDAPSyncResult counts what SyncLDAPUsers did
+type LDAPSyncResult struct {
+	Checked     int
+	Updated     int // profile or role changed
+	Deactivated int // no longer found in the directory
+	Failed      int
+}
+
+// SyncLDAPUsers looks up every active user with source ldap in the directory:
+// users that are gone are deactivated and logged out everywhere, the rest
+// get their email, display name and group role refreshed. With dryRun nothing
+// is written. Lookup errors are logged and counted; the sync carries on.
+func SyncLDAPUsers(db *gorm.DB, cfg config.LDAPConfig, dir *directory.Directory, setRole func(username, role string) error, dryRun bool) (LDAPSyncResult, error) {
+	var res LDAPSyncResult
+	var users []models.User
+	if err := db.Where("source = ? AND active = ? AND deleted = ?", "ldap", true, "No").
+		Order("id").Find(&users).Error; err != nil {
+		return res, err
+	}
+	for i := range users {
+		user := &users[i]
+		res.Checked++
+		entry, err := dir.Lookup(user.Username)
+		switch {
+		case errors.Is(err, directory.ErrNotFound):
+			log.Infof("ldap sync: %s is no longer in the directory, deactivating", user.Username)
+			res.Deactivated++
+			if dryRun {
+				continue
+			}
+			if err := models.DeactivateUser(db, user.ID); err != nil {
+				return res, err
+			}
+			if err := models.EndUserSessions(db, user.ID, time.Now()); err != nil {
+				return res, err
+			}
+			continue
+		case err != nil:
+			log.Warnf("ldap sync: %s: %v", user.Username, err)
+			res.Failed++
+			continue
+		}
+
+		role, mapped := groupRole(cfg, entry)
+		changed := (mapped && role != user.Role) ||
+			(entry.Email != "" && entry.Email != user.Email) ||
+			(entry.DisplayName != "" && entry.DisplayName != user.DisplayName)
+		if !changed {
+			continue
+		}
+		res.Updated++
+		if dryRun {
+			log.Infof("ldap sync: %s would be updated", user.Username)
+			continue
+		}
+		if err := models.UpdateExternalProfile(db, user, "ldap", entry.Email, entry.DisplayName); err != nil {
+			return res, err
+		}
+		if err := applyGroupRole(db, cfg, setRole, user, entry); err != nil {
+			return res, err
+		}
+	}
+	return res, nil
+}
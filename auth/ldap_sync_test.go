@@ -0,0 +1,62 @@
+package auth
+
+import (
+	"testing"
+
+	"backend-meta-data/auth/directory"
+	"backend-meta-data/config"
+	"backend-meta-data/models"
+
+	"github.com/glebarez/sqlite"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+func TestGroupRoleDemotesUsersInNoMappedGroup(t *testing.T) {
+	db, err := gorm.Open(sqlite.Open("file::memory:"), &gorm.Config{Logger: logger.Discard})
+	if err != nil {
+		t.Fatal(err)
+	}
+	sqlDB, err := db.DB()
+	if err != nil {
+		t.Fatal(err)
+	}
+	sqlDB.SetMaxOpenConns(1)
+	if err := db.AutoMigrate(&models.User{}); err != nil {
+		t.Fatal(err)
+	}
+	user := models.User{Username: "dave", Password: "x", Role: "Root", Source: "ldap"}
+	if err := models.CreateUser(db, &user); err != nil {
+		t.Fatal(err)
+	}
+	cfg := config.LDAPConfig{
+		DefaultRole: "Inspector",
+		GroupRoles:  map[string]string{"CN=Root,DC=example,DC=com": "Root"},
+	}
+	casbinRoles := map[string]string{}
+	setRole := func(username, role string) error {
+		casbinRoles[username] = role
+		return nil
+	}
+	entry := &directory.Entry{Username: "dave", Groups: []string{"CN=Staff,DC=example,DC=com"}}
+	if err := applyGroupRole(db, cfg, setRole, &user, entry); err != nil {
+		t.Fatal(err)
+	}
+	var stored models.User
+	if err := db.First(&stored, user.ID).Error; err != nil {
+		t.Fatal(err)
+	}
+	if stored.Role != "Inspector" || casbinRoles["dave"] != "Inspector" {
+		t.Fatalf("role=%q casbin=%q, want Inspector", stored.Role, casbinRoles["dave"])
+	}
+
+	// Without group_roles the role is left alone
+	cfg.GroupRoles = nil
+	user.Role = "Admin"
+	if err := applyGroupRole(db, cfg, setRole, &user, entry); err != nil {
+		t.Fatal(err)
+	}
+	if user.Role != "Admin" {
+		t.Fatalf("role changed to %q without group_roles", user.Role)
+	}
+}
@@ -0,0 +1,260 @@
+package auth
+
+import (
+	"crypto/rand"
+	"encoding/base32"
+	"errors"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"backend-meta-data/auth/totp"
+	"backend-meta-data/models"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/golang-jwt/jwt/v5"
+)
+
+const (
+	// mfaTokenTTL is how long the client has to send the code after the password
+	mfaTokenTTL = 5 * time.Minute
+	// mfaSkew accepts codes one step either side of now for clock drift
+	mfaSkew = 1
+	// recoveryCodeCount is how many recovery codes an enrollment gets
+	recoveryCodeCount = 10
+)
+
+var (
+	// ErrInvalidMFACode is returned for a wrong, reused or expired code
+	ErrInvalidMFACode = errors.New("invalid MFA code")
+	// ErrInvalidMFAToken is returned when the token from the login step is bad or expired
+	ErrInvalidMFAToken = errors.New("invalid or expired MFA token")
+	// ErrMFAEnabled is returned when enrolling a user who already has MFA
+	ErrMFAEnabled = errors.New("MFA is already enabled")
+	// ErrMFANotEnrolled is returned when confirming or using MFA before enrollment
+	ErrMFANotEnrolled = errors.New("MFA is not enrolled")
+	// ErrMFARequired is returned when disabling MFA that [auth] mfa_required_roles enforces
+	ErrMFARequired = errors.New("MFA is required for this role")
+)
+
+// mfaRequired reports whether role must use MFA
+func (s *Service) mfaRequired(role string) bool {
+	return slices.Contains(s.cfg.MFARoles(), role)
+}
+
+// needsMFA reports whether a login through provider must be completed with a
+// code. SSO and OIDC logins are left to the identity provider's own MFA.
+func (s *Service) needsMFA(user *models.User, provider string) bool {
+	if !slices.Contains(s.password, provider) {
+		return false
+	}
+	return user.MFAEnabled || s.mfaRequired(user.Role)
+}
+
+// mfaChallenge is the Result of a correct password when a code is still needed
+func (s *Service) mfaChallenge(user *models.User, provider string) (*Result, error) {
+	now := time.Now()
+	claims := &Claims{
+		Username: user.Username,
+		Provider: provider,
+		Purpose:  "mfa",
+		RegisteredClaims: jwt.RegisteredClaims{
+			ID:        randomToken(16),
+			Subject:   strconv.FormatUint(uint64(user.ID), 10),
+			IssuedAt:  jwt.NewNumericDate(now),
+			ExpiresAt: jwt.NewNumericDate(now.Add(mfaTokenTTL)),
+		},
+	}
+	token, err := jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString([]byte(s.cfg.JWTSecret))
+	if err != nil {
+		return nil, err
+	}
+	return &Result{User: user, Provider: provider, MFAToken: token, MFAEnroll: !user.MFAEnabled}, nil
+}
+
+// userFromMFAToken returns the user, provider and claims of a token from mfaChallenge
+func (s *Service) userFromMFAToken(raw string) (*models.User, string, *Claims, error) {
+	claims, err := s.parseClaims(raw)
+	if err != nil || claims.Purpose != "mfa" {
+		return nil, "", nil, ErrInvalidMFAToken
+	}
+	revoked, err := models.IsAccessTokenRevoked(s.db, claims.ID)
+	if err != nil {
+		return nil, "", nil, err
+	}
+	if revoked {
+		return nil, "", nil, ErrInvalidMFAToken
+	}
+	id, err := claims.UserID()
+	if err != nil {
+		return nil, "", nil, ErrInvalidMFAToken
+	}
+	user, err := lookup(models.FindUserByID(s.db, id))
+	if errors.Is(err, ErrUnauthenticated) {
+		return nil, "", nil, ErrInvalidMFAToken
+	}
+	if err != nil {
+		return nil, "", nil, err
+	}
+	if err := user.CanLogin(); err != nil {
+		return nil, "", nil, err
+	}
+	return user, claims.Provider, claims, nil
+}
+
+// EnrollMFA gives user a new pending TOTP secret and its otpauth:// URI; MFA
+// is only enabled once ConfirmMFA accepts a code for it
+func (s *Service) EnrollMFA(user *models.User) (secret, uri string, err error) {
+	if user.MFAEnabled {
+		return "", "", ErrMFAEnabled
+	}
+	if secret, err = totp.GenerateSecret(); err != nil {
+		return "", "", err
+	}
+	if err := models.SetMFASecret(s.db, user, secret); err != nil {
+		return "", "", err
+	}
+	return secret, totp.URI(s.cfg.MFAIssuer, user.Username, secret), nil
+}
+
+// EnrollMFAWithToken is EnrollMFA for a user whose role requires MFA, during
+// the login that asked them to enroll
+func (s *Service) EnrollMFAWithToken(mfaToken string) (secret, uri string, err error) {
+	user, _, _, err := s.userFromMFAToken(mfaToken)
+	if err != nil {
+		return "", "", err
+	}
+	return s.EnrollMFA(user)
+}
+
+// ConfirmMFA enables MFA when code matches the pending secret and returns the
+// new recovery codes, which are only shown here
+func (s *Service) ConfirmMFA(c *fiber.Ctx, user *models.User, code string) ([]string, error) {
+	if user.MFAEnabled {
+		return nil, ErrMFAEnabled
+	}
+	if user.MFASecret == "" {
+		return nil, ErrMFANotEnrolled
+	}
+	step, ok := totp.Validate(user.MFASecret, code, time.Now(), mfaSkew, user.MFALastStep)
+	if !ok {
+		return nil, ErrInvalidMFACode
+	}
+	codes, hashes := newRecoveryCodes()
+	if err := models.EnableMFA(s.db, user, step, hashes); err != nil {
+		return nil, err
+	}
+	s.logActivity(c, user, "mfa_enabled", "")
+	return codes, nil
+}
+
+// VerifyMFA accepts a current TOTP code or an unused recovery code for user
+func (s *Service) VerifyMFA(c *fiber.Ctx, user *models.User, code string) error {
+	if !user.MFAEnabled {
+		return ErrMFANotEnrolled
+	}
+	if step, ok := totp.Validate(user.MFASecret, code, time.Now(), mfaSkew, user.MFALastStep); ok {
+		if err := models.UseMFAStep(s.db, user, step); errors.Is(err, models.ErrInvalidCredentials) {
+			return ErrInvalidMFACode
+		} else if err != nil {
+			return err
+		}
+		return nil
+	}
+	normalized := normalizeRecoveryCode(code)
+	if len(normalized) != 10 {
+		return ErrInvalidMFACode
+	}
+	err := models.UseRecoveryCode(s.db, user.ID, hashToken(normalized), time.Now())
+	if errors.Is(err, models.ErrInvalidCredentials) {
+		return ErrInvalidMFACode
+	}
+	if err != nil {
+		return err
+	}
+	left, _ := models.CountRecoveryCodes(s.db, user.ID)
+	s.logActivity(c, user, "mfa_recovery_code_used", strconv.FormatInt(left, 10)+" left")
+	return nil
+}
+
+// CompleteMFALogin finishes a login that returned an MFA token, which can only
+// be used once. A user who was made to enroll confirms their pending secret
+// here and gets recovery codes.
+func (s *Service) CompleteMFALogin(c *fiber.Ctx, mfaToken, code string) (*Result, []string, error) {
+	user, provider, claims, err := s.userFromMFAToken(mfaToken)
+	if err != nil {
+		return nil, nil, err
+	}
+	if err := s.checkThrottle(c, user.Username); err != nil {
+		return nil, nil, err
+	}
+	var codes []string
+	if user.MFAEnabled {
+		err = s.VerifyMFA(c, user, code)
+	} else {
+		codes, err = s.ConfirmMFA(c, user, code)
+	}
+	if errors.Is(err, ErrInvalidMFACode) {
+		s.loginFailed(c, user.Username, "mfa")
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+	s.loginSucceeded(user.Username)
+	if err := models.RevokeAccessToken(s.db, claims.ID, user.ID, claims.ExpiresAt.Time); err != nil {
+		return nil, nil, err
+	}
+	res, err := s.issue(c, user, provider)
+	return res, codes, err
+}
+
+// DisableMFA turns MFA off after checking code; refused while the user's role requires it
+func (s *Service) DisableMFA(c *fiber.Ctx, user *models.User, code string) error {
+	if s.mfaRequired(user.Role) {
+		return ErrMFARequired
+	}
+	if err := s.VerifyMFA(c, user, code); err != nil {
+		return err
+	}
+	if err := models.DisableMFA(s.db, user); err != nil {
+		return err
+	}
+	s.logActivity(c, user, "mfa_disabled", "")
+	return nil
+}
+
+// RegenerateRecoveryCodes replaces the user's recovery codes after checking code
+func (s *Service) RegenerateRecoveryCodes(c *fiber.Ctx, user *models.User, code string) ([]string, error) {
+	if err := s.VerifyMFA(c, user, code); err != nil {
+		return nil, err
+	}
+	codes, hashes := newRecoveryCodes()
+	if err := models.ReplaceRecoveryCodes(s.db, user.ID, hashes); err != nil {
+		return nil, err
+	}
+	s.logActivity(c, user, "mfa_recovery_codes_regenerated", "")
+	return codes, nil
+}
+
+var recoveryEncoding = base32.StdEncoding.WithPadding(base32.NoPadding)
+
+// newRecoveryCodes returns codes formatted xxxxx-xxxxx and their hashes
+func newRecoveryCodes() (codes, hashes []string) {
+	for range recoveryCodeCount {
+		b := make([]byte, 7)
+		if _, err := rand.Read(b); err != nil {
+			panic("auth: crypto/rand failed: " + err.Error())
+		}
+		raw := strings.ToLower(recoveryEncoding.EncodeToString(b))[:10]
+		codes = append(codes, raw[:5]+"-"+raw[5:])
+		hashes = append(hashes, hashToken(raw))
+	}
+	return codes, hashes
+}
+
+// normalizeRecoveryCode drops separators and case so codes can be typed loosely
+func normalizeRecoveryCode(code string) string {
+	code = strings.ToLower(code)
+	return strings.NewReplacer("-", "", " ", "").Replace(code)
+}
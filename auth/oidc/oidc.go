@@ -0,0 +1,277 @@
+// Package oidc verifies ID and access tokens issued by an OpenID Connect
+// provider. The provider's signing keys are found through its discovery
+// document and cached; a token signed with an unknown key id triggers a
+// refetch, so key rotation on the provider side needs no restart.
+package oidc
+
+import (
+	"context"
+	"crypto/ecdh"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math/big"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// SigningMethods are the algorithms Verify accepts
+var SigningMethods = []string{"RS256", "ES256"}
+
+// minRefetch bounds how often an unknown key id may trigger a JWKS fetch
+const minRefetch = 30 * time.Second
+
+// ErrUnknownKey is returned when no cached or refetched key matches the token's kid
+var ErrUnknownKey = errors.New("oidc: token signed with an unknown key")
+
+// Config identifies the provider and the audience tokens must be issued for
+type Config struct {
+	Issuer   string
+	Audience string
+	// CacheTTL is how long fetched keys are used before refetching (default 1h)
+	CacheTTL time.Duration
+	// Leeway tolerates clock skew on exp, nbf and iat (default 1m)
+	Leeway time.Duration
+	// HTTPClient fetches the discovery document and keys (default: 10s timeout)
+	HTTPClient *http.Client
+}
+
+// Verifier checks token signatures against the provider's JWKS and the
+// iss, aud and exp claims against Config. It is safe for concurrent use.
+type Verifier struct {
+	cfg Config
+
+	mu        sync.RWMutex
+	jwksURI   string
+	keys      map[string]any // kid -> *rsa.PublicKey or *ecdsa.PublicKey
+	fetchedAt time.Time
+	// triedAt and lastErr rate-limit fetches, including failed ones
+	triedAt time.Time
+	lastErr error
+
+	fetchMu sync.Mutex // serialises discovery and JWKS fetches
+}
+
+// NewVerifier returns a verifier for cfg; nothing is fetched until the first Verify
+func NewVerifier(cfg Config) *Verifier {
+	if cfg.CacheTTL <= 0 {
+		cfg.CacheTTL = time.Hour
+	}
+	if cfg.Leeway <= 0 {
+		cfg.Leeway = time.Minute
+	}
+	if cfg.HTTPClient == nil {
+		cfg.HTTPClient = &http.Client{Timeout: 10 * time.Second}
+	}
+	return &Verifier{cfg: cfg}
+}
+
+// Verify checks raw and returns its claims
+func (v *Verifier) Verify(ctx context.Context, raw string) (jwt.MapClaims, error) {
+	claims := jwt.MapClaims{}
+	_, err := jwt.ParseWithClaims(raw, claims, func(t *jwt.Token) (any, error) {
+		kid, _ := t.Header["kid"].(string)
+		return v.key(ctx, kid)
+	},
+		jwt.WithValidMethods(SigningMethods),
+		jwt.WithIssuer(v.cfg.Issuer),
+		jwt.WithAudience(v.cfg.Audience),
+		jwt.WithExpirationRequired(),
+		jwt.WithIssuedAt(),
+		jwt.WithLeeway(v.cfg.Leeway),
+	)
+	if err != nil {
+		return nil, err
+	}
+	return claims, nil
+}
+
+// key returns the key for kid, fetching the JWKS when the cache is stale or
+// does not know kid
+func (v *Verifier) key(ctx context.Context, kid string) (any, error) {
+	v.mu.RLock()
+	k, ok := lookupKey(v.keys, kid)
+	stale := time.Since(v.fetchedAt) > v.cfg.CacheTTL
+	v.mu.RUnlock()
+	if ok && !stale {
+		return k, nil
+	}
+	if err := v.refresh(ctx); err != nil {
+		if ok {
+			// Keep using the cached key while the provider is unreachable
+			return k, nil
+		}
+		return nil, err
+	}
+	v.mu.RLock()
+	defer v.mu.RUnlock()
+	if k, ok := lookupKey(v.keys, kid); ok {
+		return k, nil
+	}
+	return nil, ErrUnknownKey
+}
+
+// lookupKey finds kid; a token without kid matches when there is a single key
+func lookupKey(keys map[string]any, kid string) (any, bool) {
+	if kid == "" && len(keys) == 1 {
+		for _, k := range keys {
+			return k, true
+		}
+	}
+	k, ok := keys[kid]
+	return k, ok
+}
+
+// refresh fetches the discovery document (until it succeeds once) and the
+// current keys, at most once per minRefetch
+func (v *Verifier) refresh(ctx context.Context) error {
+	v.fetchMu.Lock()
+	defer v.fetchMu.Unlock()
+	v.mu.RLock()
+	uri, triedAt, lastErr := v.jwksURI, v.triedAt, v.lastErr
+	v.mu.RUnlock()
+	if time.Since(triedAt) < minRefetch {
+		return lastErr // fetched (or failed) moments ago, possibly while we waited
+	}
+	keys, err := v.fetch(ctx, &uri)
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	v.triedAt, v.lastErr, v.jwksURI = time.Now(), err, uri
+	if err == nil {
+		v.keys, v.fetchedAt = keys, v.triedAt
+	}
+	return err
+}
+
+func (v *Verifier) fetch(ctx context.Context, uri *string) (map[string]any, error) {
+	if *uri == "" {
+		u, err := v.discover(ctx)
+		if err != nil {
+			return nil, err
+		}
+		*uri = u
+	}
+	return v.fetchKeys(ctx, *uri)
+}
+
+func (v *Verifier) discover(ctx context.Context) (string, error) {
+	var doc struct {
+		Issuer  string `json:"issuer"`
+		JWKSURI string `json:"jwks_uri"`
+	}
+	wellKnown := strings.TrimSuffix(v.cfg.Issuer, "/") + "/.well-known/openid-configuration"
+	if err := v.getJSON(ctx, wellKnown, &doc); err != nil {
+		return "", err
+	}
+	if doc.Issuer != v.cfg.Issuer {
+		return "", fmt.Errorf("oidc: discovery document is for issuer %q, expected %q", doc.Issuer, v.cfg.Issuer)
+	}
+	if doc.JWKSURI == "" {
+		return "", errors.New("oidc: discovery document has no jwks_uri")
+	}
+	return doc.JWKSURI, nil
+}
+
+type jwk struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Use string `json:"use"`
+	Alg string `json:"alg"`
+	N   string `json:"n"`
+	E   string `json:"e"`
+	Crv string `json:"crv"`
+	X   string `json:"x"`
+	Y   string `json:"y"`
+}
+
+func (v *Verifier) fetchKeys(ctx context.Context, uri string) (map[string]any, error) {
+	var set struct {
+		Keys []jwk `json:"keys"`
+	}
+	if err := v.getJSON(ctx, uri, &set); err != nil {
+		return nil, err
+	}
+	keys := make(map[string]any, len(set.Keys))
+	for _, k := range set.Keys {
+		if k.Use != "" && k.Use != "sig" {
+			continue
+		}
+		pub, err := k.publicKey()
+		if err != nil {
+			// Skip keys we cannot use rather than rejecting the whole set
+			continue
+		}
+		keys[k.Kid] = pub
+	}
+	if len(keys) == 0 {
+		return nil, errors.New("oidc: JWKS has no usable RS256 or ES256 signing keys")
+	}
+	return keys, nil
+}
+
+func (k jwk) publicKey() (any, error) {
+	b64 := base64.RawURLEncoding
+	switch k.Kty {
+	case "RSA":
+		n, err := b64.DecodeString(k.N)
+		if err != nil {
+			return nil, err
+		}
+		e, err := b64.DecodeString(k.E)
+		if err != nil || len(e) == 0 || len(e) > 4 {
+			return nil, errors.New("invalid RSA exponent")
+		}
+		pub := &rsa.PublicKey{N: new(big.Int).SetBytes(n), E: int(new(big.Int).SetBytes(e).Int64())}
+		if pub.N.BitLen() < 2048 {
+			return nil, errors.New("RSA key shorter than 2048 bits")
+		}
+		return pub, nil
+	case "EC":
+		if k.Crv != "P-256" {
+			return nil, fmt.Errorf("unsupported curve %q", k.Crv)
+		}
+		x, err := b64.DecodeString(k.X)
+		if err != nil || len(x) != 32 {
+			return nil, errors.New("invalid EC x coordinate")
+		}
+		y, err := b64.DecodeString(k.Y)
+		if err != nil || len(y) != 32 {
+			return nil, errors.New("invalid EC y coordinate")
+		}
+		// crypto/ecdh rejects points that are not on the curve
+		if _, err := ecdh.P256().NewPublicKey(append(append([]byte{4}, x...), y...)); err != nil {
+			return nil, err
+		}
+		return &ecdsa.PublicKey{Curve: elliptic.P256(), X: new(big.Int).SetBytes(x), Y: new(big.Int).SetBytes(y)}, nil
+	}
+	return nil, fmt.Errorf("unsupported key type %q", k.Kty)
+}
+
+func (v *Verifier) getJSON(ctx context.Context, url string, out any) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Accept", "application/json")
+	resp, err := v.cfg.HTTPClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("oidc: fetching %s: %w", url, err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("oidc: fetching %s: %s", url, resp.Status)
+	}
+	if err := json.NewDecoder(io.LimitReader(resp.Body, 1<<20)).Decode(out); err != nil {
+		return fmt.Errorf("oidc: decoding %s: %w", url, err)
+	}
+	return nil
+}
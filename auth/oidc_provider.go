@@ -0,0 +1,79 @@
+package auth
+
+import (
+	"errors"
+
+	"backend-meta-data/auth/oidc"
+	"backend-meta-data/config"
+	"backend-meta-data/models"
+
+	"github.com/gofiber/fiber/v2"
+	log "github.com/sirupsen/logrus"
+	"gorm.io/gorm"
+)
+
+// OIDCProvider accepts tokens from the [oidc] issuer, sent as the id_token
+// of a login or as a Bearer token, and maps them to a local user
+type OIDCProvider struct {
+	DB       *gorm.DB
+	Config   config.OIDCConfig
+	Verifier *oidc.Verifier
+	// AssignRole gives provisioned users their Casbin role; see Service.AssignRole
+	AssignRole func(username, role string) error
+}
+
+func (p *OIDCProvider) Name() string { return "oidc" }
+
+func (p *OIDCProvider) Authenticate(c *fiber.Ctx, creds Credentials) (*models.User, error) {
+	raw := creds.IDToken
+	if raw == "" {
+		raw = bearerToken(c)
+	}
+	if raw == "" {
+		return nil, models.ErrInvalidCredentials
+	}
+	claims, err := p.Verifier.Verify(c.UserContext(), raw)
+	if err != nil {
+		log.Infof("auth: rejected OIDC token: %v", err)
+		return nil, models.ErrInvalidCredentials
+	}
+	username, _ := claims[p.Config.UsernameClaim].(string)
+	if username == "" {
+		log.Infof("auth: OIDC token has no %s claim", p.Config.UsernameClaim)
+		return nil, models.ErrInvalidCredentials
+	}
+	sub, _ := claims["sub"].(string)
+	if sub == "" {
+		log.Infof("auth: OIDC token for %s has no sub claim", username)
+		return nil, models.ErrInvalidCredentials
+	}
+	email, _ := claims[p.Config.EmailClaim].(string)
+	name, _ := claims["name"].(string)
+	user, err := p.findUser(sub, username)
+	switch {
+	case err == nil:
+		return user, models.UpdateExternalProfile(p.DB, user, "oidc", email, name)
+	case !errors.Is(err, gorm.ErrRecordNotFound):
+		return nil, err
+	case !p.Config.AutoProvision:
+		log.Infof("auth: %s has a valid OIDC token but no local account", username)
+		return nil, models.ErrInvalidCredentials
+	}
+	return provisionUser(p.DB, p.AssignRole, "oidc", sub, username, email, name, p.Config.DefaultRole)
+}
+
+// findUser returns the oidc account linked to sub. Accounts are never matched
+// on username: when sub is unknown but username is taken the login is refused,
+// so a token can never take over a local, LDAP or unlinked account.
+func (p *OIDCProvider) findUser(sub, username string) (*models.User, error) {
+	user, err := models.FindUserByExternalID(p.DB, "oidc", sub)
+	if !errors.Is(err, gorm.ErrRecordNotFound) {
+		return user, err
+	}
+	user, err = models.FindUserByUsername(p.DB, username)
+	if err != nil {
+		return nil, err
+	}
+	log.Warnf("auth: OIDC login for %s (sub %s) refused: the account is %s and not linked to that sub", username, sub, user.Source)
+	return nil, models.ErrInvalidCredentials
+}
@@ -0,0 +1,191 @@
+package auth
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"backend-meta-data/auth/oidc"
+	"backend-meta-data/config"
+	"backend-meta-data/models"
+
+	"github.com/glebarez/sqlite"
+	"github.com/gofiber/fiber/v2"
+	"github.com/golang-jwt/jwt/v5"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+// stubIssuer serves a discovery document and a JWKS with one RSA key
+type stubIssuer struct {
+	srv *httptest.Server
+	key *rsa.PrivateKey
+}
+
+func newStubIssuer(t *testing.T) *stubIssuer {
+	t.Helper()
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := &stubIssuer{key: key}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/.well-known/openid-configuration", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(map[string]string{"issuer": s.srv.URL, "jwks_uri": s.srv.URL + "/keys"})
+	})
+	mux.HandleFunc("/keys", func(w http.ResponseWriter, r *http.Request) {
+		b64 := base64.RawURLEncoding
+		json.NewEncoder(w).Encode(map[string]any{"keys": []map[string]string{{
+			"kty": "RSA", "kid": "k1", "use": "sig", "alg": "RS256",
+			"n": b64.EncodeToString(key.N.Bytes()),
+			"e": b64.EncodeToString(big.NewInt(int64(key.E)).Bytes()),
+		}}})
+	})
+	s.srv = httptest.NewServer(mux)
+	t.Cleanup(s.srv.Close)
+	return s
+}
+
+func (s *stubIssuer) token(t *testing.T, sub, username string) string {
+	t.Helper()
+	now := time.Now()
+	tok := jwt.NewWithClaims(jwt.SigningMethodRS256, jwt.MapClaims{
+		"iss":                s.srv.URL,
+		"aud":                "fibernova",
+		"sub":                sub,
+		"preferred_username": username,
+		"email":              username + "@example.com",
+		"iat":                now.Unix(),
+		"exp":                now.Add(time.Hour).Unix(),
+	})
+	tok.Header["kid"] = "k1"
+	raw, err := tok.SignedString(s.key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return raw
+}
+
+func newOIDCTestProvider(t *testing.T) (*OIDCProvider, *stubIssuer) {
+	t.Helper()
+	db, err := gorm.Open(sqlite.Open("file::memory:"), &gorm.Config{Logger: logger.Discard})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// every connection to :memory: would get its own empty database
+	sqlDB, err := db.DB()
+	if err != nil {
+		t.Fatal(err)
+	}
+	sqlDB.SetMaxOpenConns(1)
+	if err := db.AutoMigrate(&models.User{}); err != nil {
+		t.Fatal(err)
+	}
+	iss := newStubIssuer(t)
+	p := &OIDCProvider{
+		DB: db,
+		Config: config.OIDCConfig{
+			Issuer:        iss.srv.URL,
+			Audience:      "fibernova",
+			UsernameClaim: "preferred_username",
+			EmailClaim:    "email",
+			AutoProvision: true,
+			DefaultRole:   "Inspector",
+		},
+		Verifier: oidc.NewVerifier(oidc.Config{
+			Issuer:     iss.srv.URL,
+			Audience:   "fibernova",
+			HTTPClient: iss.srv.Client(),
+		}),
+	}
+	return p, iss
+}
+
+// authenticate runs p.Authenticate for raw inside a request
+func authenticate(t *testing.T, p *OIDCProvider, raw string) (*models.User, error) {
+	t.Helper()
+	var (
+		user *models.User
+		err  error
+	)
+	app := fiber.New()
+	app.Post("/", func(c *fiber.Ctx) error {
+		user, err = p.Authenticate(c, Credentials{IDToken: raw})
+		return nil
+	})
+	if _, terr := app.Test(httptest.NewRequest(http.MethodPost, "/", nil)); terr != nil {
+		t.Fatal(terr)
+	}
+	return user, err
+}
+
+func TestOIDCProvisionsAndMatchesOnSub(t *testing.T) {
+	p, iss := newOIDCTestProvider(t)
+
+	first, err := authenticate(t, p, iss.token(t, "sub-carol", "carol"))
+	if err != nil {
+		t.Fatalf("first login: %v", err)
+	}
+	if first.Source != "oidc" || first.ExternalID != "sub-carol" {
+		t.Fatalf("provisioned source=%q external_id=%q", first.Source, first.ExternalID)
+	}
+
+	// The username claim may change; the sub still finds the same account
+	again, err := authenticate(t, p, iss.token(t, "sub-carol", "carol.new"))
+	if err != nil {
+		t.Fatalf("second login: %v", err)
+	}
+	if again.ID != first.ID {
+		t.Fatalf("second login matched user %d, want %d", again.ID, first.ID)
+	}
+}
+
+func TestOIDCRefusesAccountsNotLinkedToSub(t *testing.T) {
+	p, iss := newOIDCTestProvider(t)
+	for _, u := range []models.User{
+		{Username: "root", Password: "x", Role: "Root", Source: "local"},
+		{Username: "dave", Password: "x", Role: "Admin", Source: "ldap"},
+		{Username: "erin", Password: "x", Role: "Inspector", Source: "oidc", ExternalID: "sub-erin"},
+	} {
+		if err := models.CreateUser(p.DB, &u); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for _, name := range []string{"root", "dave", "erin"} {
+		_, err := authenticate(t, p, iss.token(t, "sub-attacker", name))
+		if !errors.Is(err, models.ErrInvalidCredentials) {
+			t.Errorf("%s: got %v, want ErrInvalidCredentials", name, err)
+		}
+	}
+	var n int64
+	p.DB.Model(&models.User{}).Where("external_id = ?", "sub-attacker").Count(&n)
+	if n != 0 {
+		t.Fatalf("sub-attacker was linked to %d accounts", n)
+	}
+}
+
+func TestOIDCDoesNotLinkByUsername(t *testing.T) {
+	p, iss := newOIDCTestProvider(t)
+	legacy := models.User{Username: "frank", Password: "x", Role: "Inspector", Source: "oidc"}
+	if err := models.CreateUser(p.DB, &legacy); err != nil {
+		t.Fatal(err)
+	}
+	_, err := authenticate(t, p, iss.token(t, "sub-frank", "frank"))
+	if !errors.Is(err, models.ErrInvalidCredentials) {
+		t.Fatalf("got %v, want ErrInvalidCredentials", err)
+	}
+	var stored models.User
+	if err := p.DB.First(&stored, legacy.ID).Error; err != nil {
+		t.Fatal(err)
+	}
+	if stored.ExternalID != "" {
+		t.Fatalf("frank was linked to %q", stored.ExternalID)
+	}
+}
@@ -0,0 +1,193 @@
+// Package password hashes and verifies local account passwords.
+//
+// Hashes are self-describing: argon2id hashes use the PHC string format
+// ($argon2id$v=19$m=65536,t=3,p=2$<salt>$<key>) and bcrypt hashes their usual
+// $2a$/$2b$ form, so Verify accepts either and NeedsRehash reports hashes made
+// with another algorithm or weaker parameters than the current Params.
+package password
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+
+	"golang.org/x/crypto/argon2"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// Supported algorithms
+const (
+	Argon2id = "argon2id"
+	Bcrypt   = "bcrypt"
+)
+
+const (
+	saltLen = 16
+	keyLen  = 32
+)
+
+// ErrUnknownFormat is returned by Verify for values that are not a supported hash
+var ErrUnknownFormat = errors.New("password: unrecognised hash format")
+
+// Params selects the algorithm and cost used for new hashes
+type Params struct {
+	Algorithm   string
+	BcryptCost  int
+	Memory      uint32 // argon2id memory in KiB
+	Iterations  uint32
+	Parallelism uint8
+}
+
+// DefaultParams follow the OWASP recommendations for argon2id
+var DefaultParams = Params{
+	Algorithm:   Argon2id,
+	BcryptCost:  12,
+	Memory:      64 * 1024,
+	Iterations:  3,
+	Parallelism: 2,
+}
+
+var (
+	mu      sync.RWMutex
+	current = DefaultParams
+)
+
+// SetDefault replaces the parameters used by Hash and NeedsRehash
+func SetDefault(p Params) error {
+	if err := p.Validate(); err != nil {
+		return err
+	}
+	mu.Lock()
+	current = p
+	mu.Unlock()
+	return nil
+}
+
+// Current returns the parameters used by Hash and NeedsRehash
+func Current() Params {
+	mu.RLock()
+	defer mu.RUnlock()
+	return current
+}
+
+// Validate reports parameters that would produce an unusable or weak hash
+func (p Params) Validate() error {
+	switch p.Algorithm {
+	case Argon2id:
+		if p.Iterations < 1 || p.Parallelism < 1 || p.Memory < 8*uint32(p.Parallelism) {
+			return fmt.Errorf("password: invalid argon2id parameters m=%d,t=%d,p=%d", p.Memory, p.Iterations, p.Parallelism)
+		}
+	case Bcrypt:
+		if p.BcryptCost < bcrypt.MinCost || p.BcryptCost > bcrypt.MaxCost {
+			return fmt.Errorf("password: bcrypt cost %d is outside %d-%d", p.BcryptCost, bcrypt.MinCost, bcrypt.MaxCost)
+		}
+	default:
+		return fmt.Errorf("password: unknown algorithm %q", p.Algorithm)
+	}
+	return nil
+}
+
+// Hash hashes plain with the current parameters
+func Hash(plain string) (string, error) {
+	return Current().Hash(plain)
+}
+
+// Hash hashes plain with p
+func (p Params) Hash(plain string) (string, error) {
+	if p.Algorithm == Bcrypt {
+		h, err := bcrypt.GenerateFromPassword([]byte(plain), p.BcryptCost)
+		if err != nil {
+			return "", fmt.Errorf("password: %w", err)
+		}
+		return string(h), nil
+	}
+	salt := make([]byte, saltLen)
+	if _, err := rand.Read(salt); err != nil {
+		return "", fmt.Errorf("password: reading salt: %w", err)
+	}
+	key := argon2.IDKey([]byte(plain), salt, p.Iterations, p.Memory, p.Parallelism, keyLen)
+	return encodeArgon2(p, salt, key), nil
+}
+
+// Verify reports whether plain matches encoded; the comparison is constant-time
+func Verify(plain, encoded string) (bool, error) {
+	switch {
+	case isBcrypt(encoded):
+		err := bcrypt.CompareHashAndPassword([]byte(encoded), []byte(plain))
+		if errors.Is(err, bcrypt.ErrMismatchedHashAndPassword) {
+			return false, nil
+		}
+		return err == nil, err
+	case strings.HasPrefix(encoded, "$"+Argon2id+"$"):
+		p, salt, key, err := decodeArgon2(encoded)
+		if err != nil {
+			return false, err
+		}
+		got := argon2.IDKey([]byte(plain), salt, p.Iterations, p.Memory, p.Parallelism, uint32(len(key)))
+		return subtle.ConstantTimeCompare(got, key) == 1, nil
+	}
+	return false, ErrUnknownFormat
+}
+
+// IsHash reports whether s looks like a hash this package produced
+func IsHash(s string) bool {
+	if isBcrypt(s) {
+		return true
+	}
+	_, _, _, err := decodeArgon2(s)
+	return err == nil
+}
+
+// NeedsRehash reports whether encoded was made with an algorithm or parameters
+// other than the current ones; callers rehash after a successful Verify
+func NeedsRehash(encoded string) bool {
+	p := Current()
+	if isBcrypt(encoded) {
+		cost, err := bcrypt.Cost([]byte(encoded))
+		return p.Algorithm != Bcrypt || err != nil || cost != p.BcryptCost
+	}
+	old, _, key, err := decodeArgon2(encoded)
+	if err != nil || p.Algorithm != Argon2id {
+		return true
+	}
+	return old.Memory != p.Memory || old.Iterations != p.Iterations ||
+		old.Parallelism != p.Parallelism || len(key) != keyLen
+}
+
+func isBcrypt(s string) bool {
+	return len(s) == 60 && (strings.HasPrefix(s, "$2a$") || strings.HasPrefix(s, "$2b$") || strings.HasPrefix(s, "$2y$"))
+}
+
+func encodeArgon2(p Params, salt, key []byte) string {
+	b64 := base64.RawStdEncoding
+	return fmt.Sprintf("$%s$v=%d$m=%d,t=%d,p=%d$%s$%s", Argon2id, argon2.Version,
+		p.Memory, p.Iterations, p.Parallelism, b64.EncodeToString(salt), b64.EncodeToString(key))
+}
+
+func decodeArgon2(encoded string) (Params, []byte, []byte, error) {
+	p := Params{Algorithm: Argon2id}
+	parts := strings.Split(encoded, "$")
+	if len(parts) != 6 || parts[0] != "" || parts[1] != Argon2id {
+		return p, nil, nil, ErrUnknownFormat
+	}
+	var version int
+	if _, err := fmt.Sscanf(parts[2], "v=%d", &version); err != nil || version != argon2.Version {
+		return p, nil, nil, ErrUnknownFormat
+	}
+	if _, err := fmt.Sscanf(parts[3], "m=%d,t=%d,p=%d", &p.Memory, &p.Iterations, &p.Parallelism); err != nil {
+		return p, nil, nil, ErrUnknownFormat
+	}
+	salt, err := base64.RawStdEncoding.DecodeString(parts[4])
+	if err != nil {
+		return p, nil, nil, ErrUnknownFormat
+	}
+	key, err := base64.RawStdEncoding.DecodeString(parts[5])
+	if err != nil || len(key) == 0 {
+		return p, nil, nil, ErrUnknownFormat
+	}
+	return p, salt, key, nil
+}
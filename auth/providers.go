@@ -0,0 +1,146 @@
+package auth
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"strings"
+
+	"backend-meta-data/auth/directory"
+	"backend-meta-data/config"
+	"backend-meta-data/models"
+
+	"github.com/gofiber/fiber/v2"
+	log "github.com/sirupsen/logrus"
+	"gorm.io/gorm"
+)
+
+// LocalProvider checks the password stored on the Users row
+type LocalProvider struct {
+	DB *gorm.DB
+}
+
+func (p *LocalProvider) Name() string { return "local" }
+
+func (p *LocalProvider) Authenticate(c *fiber.Ctx, creds Credentials) (*models.User, error) {
+	return models.AuthenticateUser(p.DB, creds.Username, creds.Password)
+}
+
+// LDAPProvider finds the user in the directory with the service account,
+// checks the password with a bind as their DN and maps them to the ldap Users
+// row with the same username, copying email, display name and the role from
+// [ldap.group_roles]
+type LDAPProvider struct {
+	DB         *gorm.DB
+	Config     config.LDAPConfig
+	Directory  *directory.Directory
+	AssignRole func(username, role string) error
+	SetRole    func(username, role string) error
+}
+
+func (p *LDAPProvider) Name() string { return "ldap" }
+
+func (p *LDAPProvider) Authenticate(c *fiber.Ctx, creds Credentials) (*models.User, error) {
+	entry, err := p.Directory.Authenticate(creds.Username, creds.Password)
+	if errors.Is(err, directory.ErrNotFound) || errors.Is(err, directory.ErrInvalidCredentials) {
+		return nil, models.ErrInvalidCredentials
+	}
+	if err != nil {
+		return nil, err
+	}
+	user, err := models.FindUserByUsername(p.DB, entry.Username)
+	switch {
+	case err == nil && user.Source != "ldap":
+		// A directory entry must never take over a local or OIDC account
+		// (e.g. Root) that happens to share its name
+		log.Warnf("auth: LDAP login for %s refused: the account with that name is %s", entry.Username, user.Source)
+		return nil, models.ErrInvalidCredentials
+	case err == nil:
+		if err := models.UpdateExternalProfile(p.DB, user, "ldap", entry.Email, entry.DisplayName); err != nil {
+			return nil, err
+		}
+		return user, applyGroupRole(p.DB, p.Config, p.SetRole, user, entry)
+	case !errors.Is(err, gorm.ErrRecordNotFound):
+		return nil, err
+	case !p.Config.AutoProvision:
+		log.Infof("auth: %s passed the AD bind but has no local account", entry.Username)
+		return nil, models.ErrInvalidCredentials
+	}
+	role, ok := entry.Role(p.Config.GroupRoles)
+	if !ok {
+		role = p.Config.DefaultRole
+	}
+	return provisionUser(p.DB, p.AssignRole, "ldap", "", entry.Username, entry.Email, entry.DisplayName, role)
+}
+
+// HeaderProvider trusts a username header set by a reverse proxy that already
+// authenticated the user (IIS/Kerberos SSO). Requests from any other address are
+// refused, and the header only maps to ldap accounts: the proxy checked an AD
+// login, not a local or OIDC one.
+type HeaderProvider struct {
+	DB      *gorm.DB
+	Header  string
+	Trusted []*net.IPNet
+}
+
+// NewHeaderProvider parses trusted, a comma separated list of IPs and CIDRs
+func NewHeaderProvider(db *gorm.DB, header, trusted string) (*HeaderProvider, error) {
+	p := &HeaderProvider{DB: db, Header: header}
+	for _, s := range strings.Split(trusted, ",") {
+		s = strings.TrimSpace(s)
+		if s == "" {
+			continue
+		}
+		if !strings.Contains(s, "/") {
+			if ip := net.ParseIP(s); ip != nil && ip.To4() != nil {
+				s += "/32"
+			} else {
+				s += "/128"
+			}
+		}
+		_, n, err := net.ParseCIDR(s)
+		if err != nil {
+			return nil, fmt.Errorf("auth.sso_trusted_proxies: %w", err)
+		}
+		p.Trusted = append(p.Trusted, n)
+	}
+	return p, nil
+}
+
+func (p *HeaderProvider) Name() string { return "sso" }
+
+func (p *HeaderProvider) Authenticate(c *fiber.Ctx, _ Credentials) (*models.User, error) {
+	// The socket address, not c.IP(), which may come from a forwarded header
+	if !p.trusts(c.Context().RemoteIP()) {
+		log.Warnf("auth: ignoring %s from untrusted address %s", p.Header, c.Context().RemoteIP())
+		return nil, models.ErrInvalidCredentials
+	}
+	username := c.Get(p.Header)
+	if _, name, ok := strings.Cut(username, `\`); ok {
+		username = name
+	}
+	if username == "" {
+		return nil, models.ErrInvalidCredentials
+	}
+	user, err := models.FindUserByUsername(p.DB, username)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, models.ErrInvalidCredentials
+	}
+	if err != nil {
+		return nil, err
+	}
+	if user.Source != "ldap" {
+		log.Warnf("auth: SSO login for %s refused: the account with that name is %s", username, user.Source)
+		return nil, models.ErrInvalidCredentials
+	}
+	return user, nil
+}
+
+func (p *HeaderProvider) trusts(ip net.IP) bool {
+	for _, n := range p.Trusted {
+		if n.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
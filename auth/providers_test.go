@@ -0,0 +1,68 @@
+package auth
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"backend-meta-data/config"
+	"backend-meta-data/models"
+
+	"github.com/glebarez/sqlite"
+	"github.com/gofiber/fiber/v2"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+func TestSSOHeaderRefusesNonLDAPAccounts(t *testing.T) {
+	db, err := gorm.Open(sqlite.Open("file::memory:"), &gorm.Config{Logger: logger.Discard})
+	if err != nil {
+		t.Fatal(err)
+	}
+	sqlDB, err := db.DB()
+	if err != nil {
+		t.Fatal(err)
+	}
+	sqlDB.SetMaxOpenConns(1)
+	if err := db.AutoMigrate(&models.User{}); err != nil {
+		t.Fatal(err)
+	}
+	for _, u := range []models.User{
+		{Username: "root", Password: "x", Role: "Root", Source: "local"},
+		{Username: "carol", Password: "x", Role: "Admin", Source: "oidc", ExternalID: "sub-carol"},
+	} {
+		if err := models.CreateUser(db, &u); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// app.Test connects from 0.0.0.0
+	s, err := New(db, &config.Config{Auth: config.AuthConfig{
+		Providers:         "local",
+		SSOHeader:         "X-AD-Username",
+		SSOTrustedProxies: "0.0.0.0",
+	}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	app := fiber.New()
+	app.Get("/auth/sso", func(c *fiber.Ctx) error {
+		if _, err := s.Login(c, "sso", Credentials{}); errors.Is(err, models.ErrInvalidCredentials) {
+			return c.SendStatus(fiber.StatusUnauthorized)
+		} else if err != nil {
+			return err
+		}
+		return c.SendStatus(fiber.StatusOK)
+	})
+	for _, name := range []string{"root", `CORP\root`, "carol"} {
+		req := httptest.NewRequest(http.MethodGet, "/auth/sso", nil)
+		req.Header.Set("X-AD-Username", name)
+		resp, err := app.Test(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if resp.StatusCode != fiber.StatusUnauthorized {
+			t.Errorf("%s: got %d, want 401", name, resp.StatusCode)
+		}
+	}
+}
@@ -0,0 +1,37 @@
+package auth
+
+import (
+	"fmt"
+
+	"backend-meta-data/models"
+
+	log "github.com/sirupsen/logrus"
+	"gorm.io/gorm"
+)
+
+// provisionUser creates the local account for a first-time external user. The
+// random password is never shown, so the account can only log in through source.
+// externalID is the provider's id for the user, if it has one.
+func provisionUser(db *gorm.DB, assignRole func(username, role string) error, source, externalID, username, email, displayName, role string) (*models.User, error) {
+	user := &models.User{
+		Username:    username,
+		Password:    randomToken(32),
+		Email:       email,
+		DisplayName: displayName,
+		Source:      source,
+		ExternalID:  externalID,
+		Role:        role,
+		Active:      true,
+		Deleted:     "No",
+	}
+	if err := models.CreateUser(db, user); err != nil {
+		return nil, fmt.Errorf("provisioning %s: %w", username, err)
+	}
+	if assignRole != nil {
+		if err := assignRole(username, role); err != nil {
+			return nil, fmt.Errorf("assigning %s to %s: %w", role, username, err)
+		}
+	}
+	log.Infof("auth: provisioned %s (%s) from %s", username, role, source)
+	return user, nil
+}
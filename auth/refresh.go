@@ -0,0 +1,127 @@
+package auth
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"time"
+
+	"backend-meta-data/models"
+
+	"github.com/gofiber/fiber/v2"
+	log "github.com/sirupsen/logrus"
+	"gorm.io/gorm"
+)
+
+var (
+	// ErrInvalidRefreshToken is returned by Refresh for unknown or expired tokens
+	ErrInvalidRefreshToken = errors.New("invalid or expired refresh token")
+	// ErrRefreshTokenReused is returned by Refresh when a rotated token is presented
+	// again; the whole rotation chain is revoked, so the user has to log in again
+	ErrRefreshTokenReused = errors.New("refresh token reuse detected")
+)
+
+// hashToken is how refresh tokens are stored and looked up
+func hashToken(raw string) string {
+	sum := sha256.Sum256([]byte(raw))
+	return hex.EncodeToString(sum[:])
+}
+
+// newRefreshToken returns a random token and its unsaved row in family
+func (s *Service) newRefreshToken(c *fiber.Ctx, user *models.User, provider, family string, expires time.Time) (string, *models.RefreshToken) {
+	raw := randomToken(32)
+	return raw, &models.RefreshToken{
+		UserID:    user.ID,
+		FamilyID:  family,
+		TokenHash: hashToken(raw),
+		Provider:  provider,
+		IPAddress: c.IP(),
+		UserAgent: c.Get(fiber.HeaderUserAgent),
+		ExpiresAt: expires,
+	}
+}
+
+// Refresh exchanges a refresh token for a new access token and a new refresh
+// token. The presented token is revoked; presenting it again revokes every
+// token rotated from the same login.
+func (s *Service) Refresh(c *fiber.Ctx, raw string) (*Result, error) {
+	if raw == "" {
+		return nil, ErrInvalidRefreshToken
+	}
+	old, err := models.FindRefreshToken(s.db, hashToken(raw))
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, ErrInvalidRefreshToken
+	}
+	if err != nil {
+		return nil, err
+	}
+	if old.RevokedAt != nil {
+		return nil, s.reused(c, old)
+	}
+	if time.Now().After(old.ExpiresAt) {
+		return nil, ErrInvalidRefreshToken
+	}
+	user, err := lookup(models.FindUserByID(s.db, old.UserID))
+	if err != nil {
+		return nil, ErrInvalidRefreshToken
+	}
+	if err := user.CanLogin(); err != nil {
+		return nil, err
+	}
+
+	// Rotations keep the family's expiry, so a login lasts at most refresh_ttl
+	next, rt := s.newRefreshToken(c, user, old.Provider, old.FamilyID, old.ExpiresAt)
+	if err := models.RotateRefreshToken(s.db, old, rt); err != nil {
+		if errors.Is(err, models.ErrTokenRotated) {
+			return nil, s.reused(c, old)
+		}
+		return nil, err
+	}
+	token, claims, err := s.IssueToken(user, old.Provider)
+	if err != nil {
+		return nil, err
+	}
+	return &Result{User: user, Provider: old.Provider, Token: token, Claims: claims,
+		Refresh: next, RefreshExpiresAt: rt.ExpiresAt}, nil
+}
+
+// reused revokes the family of a token that was presented after rotation
+func (s *Service) reused(c *fiber.Ctx, t *models.RefreshToken) error {
+	if err := models.RevokeRefreshFamily(s.db, t.FamilyID); err != nil {
+		return err
+	}
+	log.Warnf("auth: refresh token %d of user %d reused from %s; revoked its family", t.ID, t.UserID, c.IP())
+	if user, err := models.FindUserByID(s.db, t.UserID); err == nil {
+		s.logActivity(c, user, "refresh_token_reuse", "all tokens from that login were revoked")
+	}
+	return ErrRefreshTokenReused
+}
+
+// revokeAccessToken denylists the Bearer token of the request, if it is valid
+func (s *Service) revokeAccessToken(c *fiber.Ctx) error {
+	raw := bearerToken(c)
+	if raw == "" {
+		return nil
+	}
+	claims, err := s.ParseToken(raw)
+	if err != nil {
+		return nil
+	}
+	id, _ := claims.UserID()
+	return models.RevokeAccessToken(s.db, claims.ID, id, claims.ExpiresAt.Time)
+}
+
+// revokeRefreshToken revokes the rotation chain raw belongs to
+func (s *Service) revokeRefreshToken(raw string) error {
+	if raw == "" {
+		return nil
+	}
+	t, err := models.FindRefreshToken(s.db, hashToken(raw))
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	return models.RevokeRefreshFamily(s.db, t.FamilyID)
+}
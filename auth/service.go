@@ -0,0 +1,340 @@
+// Package auth resolves who a request comes from. Every login flow goes
+// through a Provider that maps the attempt to a models.User; Service then
+// checks the account is usable and issues the same session and token for all
+// of them, so AuthMiddleware, CasbinMiddleware and /me agree on the caller.
+package auth
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"backend-meta-data/auth/directory"
+	"backend-meta-data/auth/oidc"
+	"backend-meta-data/config"
+	"backend-meta-data/models"
+
+	"github.com/gofiber/fiber/v2"
+	log "github.com/sirupsen/logrus"
+	"gorm.io/gorm"
+)
+
+var (
+	// ErrUnknownProvider is returned by Login for providers that are not configured
+	ErrUnknownProvider = errors.New("unknown auth provider")
+	// ErrUnauthenticated is returned by Identify when the request carries no valid login
+	ErrUnauthenticated = errors.New("not logged in")
+)
+
+// Credentials is the body of a login request
+type Credentials struct {
+	Username string `json:"username"`
+	Password string `json:"password"`
+	// IDToken is an OIDC token exchanged for a session at /auth/oidc
+	IDToken string `json:"id_token"`
+}
+
+// Provider maps a login attempt to an existing local user. Wrong credentials
+// are reported as models.ErrInvalidCredentials so Login can try the next provider.
+type Provider interface {
+	Name() string
+	Authenticate(c *fiber.Ctx, creds Credentials) (*models.User, error)
+}
+
+// Result is what every successful login or refresh returns
+type Result struct {
+	User     *models.User
+	Provider string
+	Token    string
+	Claims   *Claims
+	// Refresh is the new refresh token; it is only returned here, the table keeps its hash
+	Refresh          string
+	RefreshExpiresAt time.Time
+
+	// MFAToken is set instead of Token when the password was right but a TOTP
+	// code is still needed (see CompleteMFALogin); MFAEnroll asks the user to
+	// enroll first because their role requires MFA
+	MFAToken  string
+	MFAEnroll bool
+}
+
+// Service logs users in through its providers and identifies later requests
+type Service struct {
+	db        *gorm.DB
+	cfg       config.AuthConfig
+	providers map[string]Provider
+	// password lists the providers Login tries when none is named
+	password []string
+
+	// AssignRole adds a Casbin role to a user; main sets it to
+	// middleware.AssignRole so providers can grant roles without importing middleware
+	AssignRole func(ctx context.Context, username, role string) error
+	// SetRole replaces a user's base role; main sets it to middleware.ReplaceRole.
+	// Used when [ldap.group_roles] decides the role.
+	SetRole func(ctx context.Context, username, role string) error
+}
+
+// Default is the service used by handlers and middleware; set by Init at startup
+var Default *Service
+
+// New builds a service with the providers enabled in cfg: local and ldap per
+// auth.providers, sso when auth.sso_trusted_proxies is set and oidc when
+// oidc.issuer is set
+func New(db *gorm.DB, cfg *config.Config) (*Service, error) {
+	s := &Service{db: db, cfg: cfg.Auth, providers: map[string]Provider{}}
+	for _, name := range cfg.Auth.ProviderNames() {
+		switch name {
+		case "local":
+			s.Register(&LocalProvider{DB: db})
+		case "ldap":
+			dir, err := directory.New(cfg.LDAP)
+			if err != nil {
+				return nil, err
+			}
+			s.Register(&LDAPProvider{DB: db, Config: cfg.LDAP, Directory: dir, AssignRole: s.assignRole, SetRole: s.setRole})
+		default:
+			return nil, fmt.Errorf("%w: %s", ErrUnknownProvider, name)
+		}
+		s.password = append(s.password, name)
+	}
+	if cfg.Auth.SSOTrustedProxies != "" {
+		p, err := NewHeaderProvider(db, cfg.Auth.SSOHeader, cfg.Auth.SSOTrustedProxies)
+		if err != nil {
+			return nil, err
+		}
+		s.Register(p)
+	}
+	if cfg.OIDC.Issuer != "" {
+		s.Register(&OIDCProvider{
+			DB:     db,
+			Config: cfg.OIDC,
+			Verifier: oidc.NewVerifier(oidc.Config{
+				Issuer:   cfg.OIDC.Issuer,
+				Audience: cfg.OIDC.Audience,
+				CacheTTL: cfg.OIDC.JWKSCacheTTL,
+			}),
+			AssignRole: s.assignRole,
+		})
+	}
+	return s, nil
+}
+
+// assignRole calls AssignRole, which is set after New
+func (s *Service) assignRole(username, role string) error {
+	if s.AssignRole == nil {
+		return nil
+	}
+	return s.AssignRole(context.Background(), username, role)
+}
+
+// setRole calls SetRole, which is set after New
+func (s *Service) setRole(username, role string) error {
+	if s.SetRole == nil {
+		return nil
+	}
+	return s.SetRole(context.Background(), username, role)
+}
+
+// Init creates the Default service
+func Init(db *gorm.DB, cfg *config.Config) (*Service, error) {
+	s, err := New(db, cfg)
+	if err != nil {
+		return nil, err
+	}
+	initSessions(db, cfg.Session)
+	Default = s
+	return s, nil
+}
+
+// Register adds or replaces a provider; Login reaches it by name
+func (s *Service) Register(p Provider) {
+	s.providers[p.Name()] = p
+}
+
+// Login authenticates with the named provider, or with each password
+// provider in order when name is empty, then starts a session and issues a token
+func (s *Service) Login(c *fiber.Ctx, name string, creds Credentials) (*Result, error) {
+	names := s.password
+	if name != "" {
+		names = []string{name}
+	}
+	// Only password logins are throttled; SSO and OIDC do their own
+	throttled := name == "" || slices.Contains(s.password, name)
+	if throttled {
+		if err := s.checkThrottle(c, creds.Username); err != nil {
+			return nil, err
+		}
+	}
+	var user *models.User
+	var lastErr error
+	for _, n := range names {
+		p, ok := s.providers[n]
+		if !ok {
+			return nil, fmt.Errorf("%w: %s", ErrUnknownProvider, n)
+		}
+		u, err := p.Authenticate(c, creds)
+		if err == nil {
+			user, name = u, n
+			break
+		}
+		if !errors.Is(err, models.ErrInvalidCredentials) {
+			log.Warnf("auth: %s provider failed: %v", n, err)
+			lastErr = err
+		}
+	}
+	if user == nil {
+		// Provider errors count too, or they would be a way around the throttle
+		if throttled {
+			s.loginFailed(c, creds.Username, strings.Join(names, ","))
+		}
+		if lastErr != nil {
+			return nil, lastErr
+		}
+		return nil, models.ErrInvalidCredentials
+	}
+	if err := user.CanLogin(); err != nil {
+		return nil, err
+	}
+	if throttled {
+		// A provider may map the login name to a different local user
+		if err := user.IsLocked(time.Now()); err != nil {
+			return nil, err
+		}
+	}
+	if s.needsMFA(user, name) {
+		return s.mfaChallenge(user, name)
+	}
+	if throttled {
+		s.loginSucceeded(creds.Username)
+	}
+	return s.issue(c, user, name)
+}
+
+func (s *Service) issue(c *fiber.Ctx, user *models.User, provider string) (*Result, error) {
+	token, claims, err := s.IssueToken(user, provider)
+	if err != nil {
+		return nil, err
+	}
+	refresh, rt := s.newRefreshToken(c, user, provider, randomToken(16), time.Now().Add(s.cfg.RefreshTTL))
+	if err := models.CreateRefreshToken(s.db, rt); err != nil {
+		return nil, err
+	}
+	if err := s.startSession(c, user, provider); err != nil {
+		return nil, err
+	}
+	s.logActivity(c, user, "login", "via "+provider)
+	return &Result{User: user, Provider: provider, Token: token, Claims: claims,
+		Refresh: refresh, RefreshExpiresAt: rt.ExpiresAt}, nil
+}
+
+// Identify resolves the caller from an X-API-Key header, a Bearer token or,
+// failing both, the login session, and stores the user in c.Locals("user")
+// and c.Locals("username")
+func (s *Service) Identify(c *fiber.Ctx) (*models.User, error) {
+	if user, ok := c.Locals("user").(*models.User); ok {
+		return user, nil
+	}
+	var user *models.User
+	var err error
+	if raw := c.Get(APIKeyHeader); raw != "" {
+		user, err = s.userFromAPIKey(c, raw)
+	} else if raw := bearerToken(c); raw != "" {
+		user, err = s.userFromToken(raw)
+	} else {
+		user, err = s.userFromSession(c)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := user.CanLogin(); err != nil {
+		return nil, err
+	}
+	c.Locals("user", user)
+	c.Locals("username", user.Username)
+	return user, nil
+}
+
+// IdentifyWith resolves the caller through the named provider alone, e.g. the
+// "oidc" middleware accepting the issuer's tokens instead of ours
+func (s *Service) IdentifyWith(c *fiber.Ctx, name string) (*models.User, error) {
+	p, ok := s.providers[name]
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrUnknownProvider, name)
+	}
+	user, err := p.Authenticate(c, Credentials{})
+	if err != nil {
+		return nil, err
+	}
+	if err := user.CanLogin(); err != nil {
+		return nil, err
+	}
+	c.Locals("user", user)
+	c.Locals("username", user.Username)
+	return user, nil
+}
+
+func (s *Service) userFromToken(raw string) (*models.User, error) {
+	claims, err := s.ParseToken(raw)
+	if err != nil {
+		return nil, ErrUnauthenticated
+	}
+	revoked, err := models.IsAccessTokenRevoked(s.db, claims.ID)
+	if err != nil {
+		return nil, err
+	}
+	if revoked {
+		return nil, ErrUnauthenticated
+	}
+	id, err := claims.UserID()
+	if err != nil {
+		return nil, ErrUnauthenticated
+	}
+	user, err := lookup(models.FindUserByID(s.db, id))
+	if err != nil {
+		return nil, err
+	}
+	// iat has second precision, so compare whole seconds
+	if user.SessionsRevokedAt != nil && claims.IssuedAt != nil &&
+		claims.IssuedAt.Unix() < user.SessionsRevokedAt.Unix() {
+		return nil, ErrUnauthenticated
+	}
+	return user, nil
+}
+
+func (s *Service) userFromSession(c *fiber.Ctx) (*models.User, error) {
+	id, ok, err := models.SessionUserID(c)
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		return nil, ErrUnauthenticated
+	}
+	return lookup(models.FindUserByID(s.db, id))
+}
+
+// lookup maps a missing user to ErrUnauthenticated
+func lookup(user *models.User, err error) (*models.User, error) {
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, ErrUnauthenticated
+	}
+	return user, err
+}
+
+// logActivity records an entry shown in the user's recent activity on /me
+func (s *Service) logActivity(c *fiber.Ctx, user *models.User, activity, details string) {
+	if err := models.LogActivity(s.db, user.ID, activity, details, c.IP(), c.Get(fiber.HeaderUserAgent)); err != nil {
+		log.Warnf("auth: recording %s for %s failed: %v", activity, user.Username, err)
+	}
+}
+
+func bearerToken(c *fiber.Ctx) string {
+	h := c.Get(fiber.HeaderAuthorization)
+	if h == "" {
+		return ""
+	}
+	// A bare token is accepted too, as older clients send it without the scheme
+	return strings.TrimSpace(strings.TrimPrefix(h, "Bearer "))
+}
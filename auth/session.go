@@ -0,0 +1,59 @@
+package auth
+
+import (
+	"time"
+
+	"backend-meta-data/config"
+	"backend-meta-data/models"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/gofiber/fiber/v2/middleware/session"
+	"gorm.io/gorm"
+)
+
+// initSessions points models.Store at the Sessions table using [session]
+func initSessions(db *gorm.DB, cfg config.SessionConfig) {
+	models.InitSessionStore(db, session.Config{
+		Expiration:     cfg.IdleTimeout,
+		KeyLookup:      "cookie:" + cfg.CookieName,
+		CookieDomain:   cfg.CookieDomain,
+		CookiePath:     "/",
+		CookieSecure:   cfg.CookieSecure,
+		CookieHTTPOnly: true,
+		CookieSameSite: cfg.CookieSameSite,
+	}, cfg.AbsoluteTimeout)
+}
+
+// startSession binds a fresh session to user, recording the provider
+func (s *Service) startSession(c *fiber.Ctx, user *models.User, provider string) error {
+	return models.StartSession(c, s.db, user, map[string]any{"provider": provider})
+}
+
+// Logout ends the caller's session, denylists the Bearer token of the request
+// and revokes refresh, the refresh token of the same login (may be empty)
+func (s *Service) Logout(c *fiber.Ctx, refresh string) error {
+	if user, err := s.Identify(c); err == nil {
+		s.logActivity(c, user, "logout", "")
+	}
+	if err := s.revokeAccessToken(c); err != nil {
+		return err
+	}
+	if err := s.revokeRefreshToken(refresh); err != nil {
+		return err
+	}
+	return models.EndSession(c)
+}
+
+// LogoutAll ends every login of user: stored sessions and refresh tokens are
+// deleted and access tokens issued until now are refused
+func (s *Service) LogoutAll(c *fiber.Ctx, user *models.User) error {
+	if err := models.EndUserSessions(s.db, user.ID, time.Now()); err != nil {
+		return err
+	}
+	// Tokens issued within the current second pass the iat check; revoke this one by jti
+	if err := s.revokeAccessToken(c); err != nil {
+		return err
+	}
+	s.logActivity(c, user, "logout_all", "")
+	return models.EndSession(c)
+}
@@ -0,0 +1,128 @@
+package auth
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"backend-meta-data/models"
+
+	"github.com/gofiber/fiber/v2"
+	log "github.com/sirupsen/logrus"
+	"gorm.io/gorm"
+)
+
+// ThrottledError is returned by Login and CompleteMFALogin while a username
+// or IP is backing off after failed attempts
+type ThrottledError struct {
+	RetryAfter time.Duration
+}
+
+func (e *ThrottledError) Error() string {
+	return fmt.Sprintf("too many failed attempts, retry in %s", e.RetryAfter.Round(time.Second))
+}
+
+// UserThrottleKey and IPThrottleKey name the LoginThrottles rows of a login attempt
+func UserThrottleKey(username string) string {
+	return "user:" + strings.ToLower(strings.TrimSpace(username))
+}
+
+func IPThrottleKey(ip string) string { return "ip:" + ip }
+
+// backoff is how long a key with failures failures is blocked; nothing below
+// threshold, then throttle_backoff doubling per failure, at most lockout_duration
+func (s *Service) backoff(failures, threshold int) time.Duration {
+	if failures < threshold {
+		return 0
+	}
+	d := s.cfg.ThrottleBackoff
+	for i := threshold; i < failures && d < s.cfg.LockoutDuration; i++ {
+		d *= 2
+	}
+	return min(d, s.cfg.LockoutDuration)
+}
+
+// checkThrottle refuses an attempt for username while it or the caller's IP is
+// blocked, or while the local account of that name is locked
+func (s *Service) checkThrottle(c *fiber.Ctx, username string) error {
+	now := time.Now()
+	rows, err := models.FindLoginThrottles(s.db, UserThrottleKey(username), IPThrottleKey(c.IP()))
+	if err != nil {
+		return err
+	}
+	var wait time.Duration
+	for _, r := range rows {
+		if r.BlockedUntil != nil && r.BlockedUntil.After(now) {
+			wait = max(wait, r.BlockedUntil.Sub(now))
+		}
+	}
+	if wait > 0 {
+		return &ThrottledError{RetryAfter: wait}
+	}
+	if username == "" {
+		return nil
+	}
+	user, err := models.FindUserByUsername(s.db, username)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	return user.IsLocked(now)
+}
+
+// loginFailed counts a failed password or MFA code for username and the
+// caller's IP, locks the account at lockout_failures and records the attempt
+// in the user's activity log (unknown usernames are only logged)
+func (s *Service) loginFailed(c *fiber.Ctx, username, via string) {
+	now := time.Now()
+	userRow, err := models.RecordLoginFailure(s.db, UserThrottleKey(username), now, s.cfg.LockoutDuration,
+		func(n int) time.Duration { return s.backoff(n, s.cfg.ThrottleFailures) })
+	if err != nil {
+		log.Warnf("auth: counting failed login for %s: %v", username, err)
+		return
+	}
+	ipRow, err := models.RecordLoginFailure(s.db, IPThrottleKey(c.IP()), now, s.cfg.LockoutDuration,
+		func(n int) time.Duration { return s.backoff(n, s.cfg.ThrottleIPFailures) })
+	if err != nil {
+		log.Warnf("auth: counting failed login from %s: %v", c.IP(), err)
+		return
+	}
+	details := fmt.Sprintf("via %s: %d failure(s) for this user, %d from this IP", via, userRow.Failures, ipRow.Failures)
+
+	user, err := models.FindUserByUsername(s.db, username)
+	if err != nil {
+		log.Warnf("auth: failed login for unknown user %q from %s (%s)", username, c.IP(), details)
+		return
+	}
+	s.logActivity(c, user, "login_failed", details)
+	if userRow.Failures >= s.cfg.LockoutFailures && user.IsLocked(now) == nil {
+		if err := models.LockUser(s.db, user.ID, now.Add(s.cfg.LockoutDuration)); err != nil {
+			log.Warnf("auth: locking %s: %v", user.Username, err)
+			return
+		}
+		log.Warnf("auth: %s locked for %s after %d failed logins", user.Username, s.cfg.LockoutDuration, userRow.Failures)
+		s.logActivity(c, user, "account_locked", fmt.Sprintf("for %s after %d failed logins", s.cfg.LockoutDuration, userRow.Failures))
+	}
+}
+
+// loginSucceeded forgets the failures counted for username
+func (s *Service) loginSucceeded(username string) {
+	if err := models.ClearLoginFailures(s.db, UserThrottleKey(username)); err != nil {
+		log.Warnf("auth: clearing failed logins for %s: %v", username, err)
+	}
+}
+
+// Unlock clears the lock and failure count of user; by names the admin for the audit log
+func (s *Service) Unlock(c *fiber.Ctx, user *models.User, by string) error {
+	if err := models.UnlockUser(s.db, user.ID); err != nil {
+		return err
+	}
+	if err := models.ClearLoginFailures(s.db, UserThrottleKey(user.Username)); err != nil {
+		return err
+	}
+	s.logActivity(c, user, "account_unlocked", "by "+by)
+	return nil
+}
@@ -0,0 +1,28 @@
+package main
+
+import (
+	"backend-meta-data/auth/password"
+	"backend-meta-data/config"
+	"backend-meta-data/db"
+
+	"gorm.io/gorm"
+)
+
+// configDir holds config.toml (or the legacy .env) for commands that need the database
+const configDir = "."
+
+// connectDB loads the application config and opens the GORM connection
+func connectDB() (*config.Config, *gorm.DB, error) {
+	cfg, err := config.Load(configDir)
+	if err != nil {
+		return nil, nil, err
+	}
+	if err := password.SetDefault(cfg.Auth.PasswordParams()); err != nil {
+		return nil, nil, err
+	}
+	gormDB, err := db.ConnectGormDB(&cfg.DB)
+	if err != nil {
+		return nil, nil, err
+	}
+	return cfg, gormDB, nil
+}
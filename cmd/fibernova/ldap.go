@@ -0,0 +1,58 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+
+	"backend-meta-data/auth"
+	"backend-meta-data/auth/directory"
+	"backend-meta-data/middleware"
+)
+
+func init() {
+	register("ldap:sync", command{
+		Usage:   "[-dry-run]",
+		Summary: "Refresh LDAP users' profiles and group roles; deactivate users removed from the directory",
+		Run:     runLDAPSync,
+	})
+}
+
+func runLDAPSync(args []string) error {
+	flags := flag.NewFlagSet("ldap:sync", flag.ContinueOnError)
+	dryRun := flags.Bool("dry-run", false, "report changes without writing them")
+	if _, err := parseFlags(flags, args); err != nil {
+		return err
+	}
+	cfg, gormDB, err := connectDB()
+	if err != nil {
+		return err
+	}
+	if cfg.LDAP.URL == "" {
+		return errors.New("ldap.url is not set")
+	}
+	if err := middleware.InitCasbin(gormDB); err != nil {
+		return err
+	}
+	dir, err := directory.New(cfg.LDAP)
+	if err != nil {
+		return err
+	}
+	defer dir.Close()
+
+	setRole := func(username, role string) error {
+		return middleware.ReplaceRole(context.Background(), username, role)
+	}
+	res, err := auth.SyncLDAPUsers(gormDB, cfg.LDAP, dir, setRole, *dryRun)
+	prefix := ""
+	if *dryRun {
+		prefix = "[dry run] "
+	}
+	fmt.Printf("%sChecked %d LDAP user(s): %d updated, %d deactivated, %d failed.\n",
+		prefix, res.Checked, res.Updated, res.Deactivated, res.Failed)
+	if err == nil && res.Failed > 0 {
+		err = fmt.Errorf("%d lookup(s) failed", res.Failed)
+	}
+	return err
+}
@@ -0,0 +1,84 @@
+// Command fibernova is the FiberNova developer CLI: it scaffolds new
+// applications and generates code inside an existing one.
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+)
+
+// command describes a single CLI sub-command
+type command struct {
+	Usage   string
+	Summary string
+	Run     func(args []string) error
+}
+
+var commands = map[string]command{}
+
+// register adds a sub-command to the CLI
+func register(name string, cmd command) {
+	commands[name] = cmd
+}
+
+func main() {
+	if len(os.Args) < 2 {
+		printUsage()
+		os.Exit(1)
+	}
+	name := os.Args[1]
+	if name == "help" || name == "-h" || name == "--help" {
+		printUsage()
+		return
+	}
+	cmd, ok := commands[name]
+	if !ok {
+		fmt.Fprintf(os.Stderr, "fibernova: unknown command %q\n\n", name)
+		printUsage()
+		os.Exit(1)
+	}
+	if err := cmd.Run(os.Args[2:]); err != nil {
+		fmt.Fprintf(os.Stderr, "fibernova %s: %v\n", name, err)
+		os.Exit(1)
+	}
+}
+
+// parseFlags parses args with fs while allowing flags to appear after
+// positional arguments, returning the positional arguments in order.
+func parseFlags(fs *flag.FlagSet, args []string) ([]string, error) {
+	var positional []string
+	for {
+		if err := fs.Parse(args); err != nil {
+			return nil, err
+		}
+		args = fs.Args()
+		if len(args) == 0 {
+			return positional, nil
+		}
+		positional = append(positional, args[0])
+		args = args[1:]
+	}
+}
+
+func printUsage() {
+	fmt.Println("FiberNova CLI")
+	fmt.Println()
+	fmt.Println("Usage:")
+	names := make([]string, 0, len(commands))
+	width := 0
+	for name, cmd := range commands {
+		names = append(names, name)
+		if l := len(name + " " + cmd.Usage); l > width {
+			width = l
+		}
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		cmd := commands[name]
+		line := strings.TrimSpace(name + " " + cmd.Usage)
+		fmt.Printf("  fibernova %-*s  %s\n", width, line, cmd.Summary)
+	}
+}
@@ -0,0 +1,358 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// resourceNames are the handler factory names generated for a controller
+type resourceNames struct {
+	Resource    string
+	Label       string
+	LabelPlural string
+	List        string // index
+	Show        string // show
+	Store       string // store
+	Update      string // update
+	Destroy     string // destroy
+}
+
+// modelHelpers are the models.* functions a controller is wired to; empty
+// names fall back to a "not implemented" stub
+type modelHelpers struct {
+	Name   string
+	List   string
+	Get    string
+	Create string
+	Update string
+	Delete string
+}
+
+// controllerData is passed to stubs/make/controller.go.tmpl
+type controllerData struct {
+	resourceNames
+	Module     string
+	Path       string
+	Model      modelHelpers
+	UsesModels bool
+}
+
+func init() {
+	register("make:controller", command{
+		Usage:   "<Name>Controller [--model Name] [--path /api/...]",
+		Summary: "Generate resource handlers (index, show, store, update, destroy) in controllers/",
+		Run:     runMakeController,
+	})
+	register("make:route", command{
+		Usage:   "resource <path> <Name>Controller [--group name | --public]",
+		Summary: "Append RESTful bindings for a controller to routes.RegisterAPIRoutes",
+		Run:     runMakeRoute,
+	})
+}
+
+// newResourceNames derives handler names from "UserController", "User" or "users"
+func newResourceNames(controller string) (resourceNames, error) {
+	name := pascalCase(strings.TrimSuffix(pascalCase(controller), "Controller"))
+	if name == "" || !token.IsIdentifier(name) {
+		return resourceNames{}, fmt.Errorf("invalid controller name %q", controller)
+	}
+	plural := pluralize(name)
+	label := strings.ReplaceAll(snakeCase(name), "_", " ")
+	return resourceNames{
+		Resource:    name,
+		Label:       label,
+		LabelPlural: pluralize(label),
+		List:        "List" + plural,
+		Show:        "Get" + name,
+		Store:       "Create" + name,
+		Update:      "Update" + name,
+		Destroy:     "Delete" + name,
+	}, nil
+}
+
+// handlers returns the generated factory names in binding order
+func (r resourceNames) handlers() []string {
+	return []string{r.List, r.Show, r.Store, r.Update, r.Destroy}
+}
+
+func runMakeController(args []string) error {
+	flags := flag.NewFlagSet("make:controller", flag.ContinueOnError)
+	model := flags.String("model", "", "wire handlers to the helper functions of an existing model")
+	path := flags.String("path", "", "resource path used in handler comments (defaults to /api/<plural>)")
+	pos, err := parseFlags(flags, args)
+	if err != nil {
+		return err
+	}
+	if len(pos) != 1 {
+		return errors.New("usage: fibernova make:controller <Name>Controller [--model Name]")
+	}
+	p, err := loadProject()
+	if err != nil {
+		return err
+	}
+	names, err := newResourceNames(pos[0])
+	if err != nil {
+		return err
+	}
+	existing, err := packageFuncs(p.path("controllers"))
+	if err != nil {
+		return err
+	}
+	for _, h := range names.handlers() {
+		if _, ok := existing[h]; ok {
+			return fmt.Errorf("controllers.%s already exists", h)
+		}
+	}
+
+	data := controllerData{resourceNames: names, Module: p.Module, Path: *path}
+	if data.Path == "" {
+		data.Path = "/api/" + resourceSegment(names.Resource)
+	}
+	if *model != "" {
+		helpers, err := findModelHelpers(p, pascalCase(*model))
+		if err != nil {
+			return err
+		}
+		data.Model = helpers
+		data.UsesModels = helpers.List != "" || helpers.Get != "" || helpers.Create != "" || helpers.Update != "" || helpers.Delete != ""
+	}
+
+	src, err := renderStubFile("stubs/make/controller.go.tmpl", data)
+	if err != nil {
+		return err
+	}
+	return p.writeNew("controllers/"+snakeCase(names.Resource)+"_controller.go", src)
+}
+
+// resourceSegment turns "InventoryItem" into "inventory-items"
+func resourceSegment(name string) string {
+	return strings.ReplaceAll(snakeCase(pluralize(name)), "_", "-")
+}
+
+// findModelHelpers checks models/ for the helpers generated by make:model and
+// reports which ones can be wired; helpers with an unexpected signature are skipped.
+func findModelHelpers(p *project, name string) (modelHelpers, error) {
+	funcs, err := packageFuncs(p.path("models"))
+	if err != nil {
+		return modelHelpers{}, err
+	}
+	types, err := packageTypes(p.path("models"))
+	if err != nil {
+		return modelHelpers{}, err
+	}
+	if _, ok := types[name]; !ok {
+		return modelHelpers{}, fmt.Errorf("model %s not found in models/", name)
+	}
+	h := modelHelpers{Name: name}
+	pick := func(fn string, params int) string {
+		if n, ok := funcs[fn]; ok && n == params {
+			return fn
+		}
+		fmt.Printf("models.%s not found (or unexpected signature); generating a stub instead\n", fn)
+		return ""
+	}
+	h.List = pick("List"+pluralize(name), 3)
+	h.Get = pick("Get"+name+"ByID", 2)
+	h.Create = pick("Create"+name, 2)
+	h.Update = pick("Update"+name, 3)
+	h.Delete = pick("Mark"+name+"Deleted", 2)
+	return h, nil
+}
+
+// packageFuncs maps each top-level function in dir to its parameter count
+func packageFuncs(dir string) (map[string]int, error) {
+	out := map[string]int{}
+	err := eachGoFile(dir, func(f *ast.File) {
+		for _, d := range f.Decls {
+			if fn, ok := d.(*ast.FuncDecl); ok && fn.Recv == nil {
+				n := 0
+				for _, field := range fn.Type.Params.List {
+					if len(field.Names) == 0 {
+						n++
+					}
+					n += len(field.Names)
+				}
+				out[fn.Name.Name] = n
+			}
+		}
+	})
+	return out, err
+}
+
+// packageTypes lists the top-level type names declared in dir
+func packageTypes(dir string) (map[string]struct{}, error) {
+	out := map[string]struct{}{}
+	err := eachGoFile(dir, func(f *ast.File) {
+		for _, d := range f.Decls {
+			if gd, ok := d.(*ast.GenDecl); ok && gd.Tok == token.TYPE {
+				for _, s := range gd.Specs {
+					out[s.(*ast.TypeSpec).Name.Name] = struct{}{}
+				}
+			}
+		}
+	})
+	return out, err
+}
+
+func eachGoFile(dir string, fn func(*ast.File)) error {
+	matches, err := filepath.Glob(filepath.Join(dir, "*.go"))
+	if err != nil {
+		return err
+	}
+	if len(matches) == 0 {
+		if _, err := os.Stat(dir); err != nil {
+			return fmt.Errorf("%s: %w", filepath.Base(dir), err)
+		}
+	}
+	fset := token.NewFileSet()
+	for _, file := range matches {
+		if strings.HasSuffix(file, "_test.go") {
+			continue
+		}
+		f, err := parser.ParseFile(fset, file, nil, parser.SkipObjectResolution)
+		if err != nil {
+			return err
+		}
+		fn(f)
+	}
+	return nil
+}
+
+func runMakeRoute(args []string) error {
+	flags := flag.NewFlagSet("make:route", flag.ContinueOnError)
+	group := flags.String("group", "", "bind through this route group variable in RegisterAPIRoutes (default protected)")
+	public := flags.Bool("public", false, "bind through the public group, without authentication")
+	pos, err := parseFlags(flags, args)
+	if err != nil {
+		return err
+	}
+	if len(pos) != 3 || pos[0] != "resource" {
+		return errors.New("usage: fibernova make:route resource <path> <Name>Controller [--group name | --public]")
+	}
+	switch {
+	case *public && *group != "":
+		return errors.New("--public and --group cannot be combined")
+	case *public:
+		*group = "public"
+	case *group == "":
+		*group = "protected"
+	}
+	path := "/" + strings.Trim(pos[1], "/")
+	names, err := newResourceNames(pos[2])
+	if err != nil {
+		return err
+	}
+	p, err := loadProject()
+	if err != nil {
+		return err
+	}
+	existing, err := packageFuncs(p.path("controllers"))
+	if err != nil {
+		return err
+	}
+	for _, h := range names.handlers() {
+		if _, ok := existing[h]; !ok {
+			return fmt.Errorf("controllers.%s not found; run `fibernova make:controller %sController` first", h, names.Resource)
+		}
+	}
+	return appendResourceRoutes(p, path, names, *group)
+}
+
+// appendResourceRoutes adds Get/Post/Patch/Delete bindings to the end of
+// routes.RegisterAPIRoutes, on a named middleware group variable (e.g.
+// protected := NewGroup(api, "auth")). Paths are relative to the router group
+// created there (e.g. api := app.Group("/api")).
+func appendResourceRoutes(p *project, path string, names resourceNames, group string) error {
+	file, err := p.findFunc("routes", "RegisterAPIRoutes")
+	if err != nil {
+		return err
+	}
+	e, err := openSource(file)
+	if err != nil {
+		return err
+	}
+	fn := e.funcDecl("RegisterAPIRoutes")
+
+	dbVar := ""
+	for _, field := range fn.Type.Params.List {
+		if star, ok := field.Type.(*ast.StarExpr); ok {
+			if sel, ok := star.X.(*ast.SelectorExpr); ok && len(field.Names) > 0 && sel.Sel.Name == "DB" {
+				if x, ok := sel.X.(*ast.Ident); ok && x.Name == "gorm" {
+					dbVar = field.Names[0].Name
+				}
+			}
+		}
+	}
+	if dbVar == "" {
+		return errors.New("RegisterAPIRoutes has no *gorm.DB parameter")
+	}
+	for _, stmt := range fn.Body.List {
+		as, ok := stmt.(*ast.AssignStmt)
+		if !ok || len(as.Lhs) != 1 || len(as.Rhs) != 1 {
+			continue
+		}
+		call, ok := as.Rhs[0].(*ast.CallExpr)
+		if !ok || len(call.Args) == 0 {
+			continue
+		}
+		sel, ok := call.Fun.(*ast.SelectorExpr)
+		lit, isLit := call.Args[0].(*ast.BasicLit)
+		if !ok || sel.Sel.Name != "Group" || !isLit {
+			continue
+		}
+		// Paths are relative to the group; a path that repeats the prefix is accepted too
+		prefix, _ := strconv.Unquote(lit.Value)
+		if path == prefix || strings.HasPrefix(path, prefix+"/") {
+			path = strings.TrimPrefix(path, prefix)
+		}
+		break
+	}
+	// Routes never go on the bare router, which has no auth or rbac
+	if !assignsVar(fn.Body, group) {
+		return fmt.Errorf("group %q is not declared in RegisterAPIRoutes; declare it (e.g. %s := NewGroup(api, \"auth\")) or pass --group", group, group)
+	}
+	router := group
+	if path == "" {
+		path = "/"
+	}
+	if e.contains(fn.Body.Lbrace, fn.Body.Rbrace, "controllers."+names.List+"(") {
+		return fmt.Errorf("routes for %s are already registered in %s", names.Resource, p.rel(file))
+	}
+	item := strings.TrimSuffix(path, "/") + "/:id"
+	var b strings.Builder
+	fmt.Fprintf(&b, "\n\t// %s\n", pluralize(names.Resource))
+	fmt.Fprintf(&b, "\t%s.Get(%q, controllers.%s(%s))\n", router, path, names.List, dbVar)
+	fmt.Fprintf(&b, "\t%s.Get(%q, controllers.%s(%s))\n", router, item, names.Show, dbVar)
+	fmt.Fprintf(&b, "\t%s.Post(%q, controllers.%s(%s))\n", router, path, names.Store, dbVar)
+	fmt.Fprintf(&b, "\t%s.Patch(%q, controllers.%s(%s))\n", router, item, names.Update, dbVar)
+	fmt.Fprintf(&b, "\t%s.Delete(%q, controllers.%s(%s))\n", router, item, names.Destroy, dbVar)
+	e.insertAt(fn.Body.Rbrace, b.String())
+	e.addImport(p.Module + "/controllers")
+	if err := e.save(); err != nil {
+		return err
+	}
+	fmt.Printf("Registered %s routes in %s\n", names.Resource, p.rel(file))
+	return nil
+}
+
+// assignsVar reports whether body declares name with := at the top level
+func assignsVar(body *ast.BlockStmt, name string) bool {
+	for _, stmt := range body.List {
+		if as, ok := stmt.(*ast.AssignStmt); ok && as.Tok == token.DEFINE {
+			for _, lhs := range as.Lhs {
+				if id, ok := lhs.(*ast.Ident); ok && id.Name == name {
+					return true
+				}
+			}
+		}
+	}
+	return false
+}
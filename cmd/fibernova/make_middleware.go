@@ -0,0 +1,63 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"go/token"
+	"strings"
+)
+
+// middlewareData is passed to stubs/make/middleware.go.tmpl
+type middlewareData struct {
+	Name string
+	Key  string
+}
+
+func init() {
+	register("make:middleware", command{
+		Usage:   "<Name>Middleware [--name key]",
+		Summary: "Generate a fiber.Handler in middleware/ registered under a name",
+		Run:     runMakeMiddleware,
+	})
+}
+
+func runMakeMiddleware(args []string) error {
+	flags := flag.NewFlagSet("make:middleware", flag.ContinueOnError)
+	key := flags.String("name", "", "registry name used by routes (defaults to the kebab-case name without the Middleware suffix)")
+	pos, err := parseFlags(flags, args)
+	if err != nil {
+		return err
+	}
+	if len(pos) != 1 {
+		return errors.New("usage: fibernova make:middleware <Name>Middleware [--name key]")
+	}
+	p, err := loadProject()
+	if err != nil {
+		return err
+	}
+	base := strings.TrimSuffix(pascalCase(pos[0]), "Middleware")
+	if base == "" || !token.IsIdentifier(base) {
+		return fmt.Errorf("invalid middleware name %q", pos[0])
+	}
+	data := middlewareData{Name: base + "Middleware", Key: *key}
+	if data.Key == "" {
+		data.Key = strings.ReplaceAll(snakeCase(base), "_", "-")
+	}
+	existing, err := packageFuncs(p.path("middleware"))
+	if err != nil {
+		return err
+	}
+	if _, ok := existing[data.Name]; ok {
+		return fmt.Errorf("middleware.%s already exists", data.Name)
+	}
+	src, err := renderStubFile("stubs/make/middleware.go.tmpl", data)
+	if err != nil {
+		return err
+	}
+	if err := p.writeNew("middleware/"+snakeCase(base)+".go", src); err != nil {
+		return err
+	}
+	fmt.Printf("Attach it with routes.NewGroup(api, %q)\n", data.Key)
+	return nil
+}
@@ -0,0 +1,200 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"go/ast"
+	"go/token"
+	"strings"
+)
+
+// modelField is a single --fields entry rendered into the model struct
+type modelField struct {
+	Name   string
+	Column string
+	GoType string
+	Tag    string
+}
+
+// modelData is passed to stubs/make/model.go.tmpl
+type modelData struct {
+	Name        string
+	Plural      string
+	Table       string
+	Var         string
+	Label       string
+	LabelPlural string
+	Fields      []modelField
+}
+
+func init() {
+	register("make:model", command{
+		Usage:   "<Name> [--fields name:type,...] [--no-migrate]",
+		Summary: "Generate a GORM model in models/ and its create-table migration",
+		Run:     runMakeModel,
+	})
+}
+
+func runMakeModel(args []string) error {
+	flags := flag.NewFlagSet("make:model", flag.ContinueOnError)
+	fields := flags.String("fields", "", "comma separated name:type pairs (string, text, int, uint, float, bool, time)")
+	noMigrate := flags.Bool("no-migrate", false, "do not generate a create-table migration for the model")
+	pos, err := parseFlags(flags, args)
+	if err != nil {
+		return err
+	}
+	if len(pos) != 1 {
+		return errors.New("usage: fibernova make:model <Name> [--fields name:type,...]")
+	}
+	p, err := loadProject()
+	if err != nil {
+		return err
+	}
+	data, err := newModelData(pos[0], *fields)
+	if err != nil {
+		return err
+	}
+	src, err := renderStubFile("stubs/make/model.go.tmpl", data)
+	if err != nil {
+		return err
+	}
+	if err := p.writeNew("models/"+snakeCase(data.Name)+".go", src); err != nil {
+		return err
+	}
+	if *noMigrate {
+		return nil
+	}
+	if p.hasMigrator() {
+		return writeMigration(p, "stubs/make/create_table_migration.go.tmpl", "create_"+snakeCase(data.Table)+"_table", data.Name)
+	}
+	// Projects without versioned migrations still register models in db.InitDBIfNeeded
+	return registerAutoMigrate(p, data.Name)
+}
+
+func newModelData(name, fields string) (modelData, error) {
+	n := pascalCase(name)
+	if n == "" || !token.IsIdentifier(n) {
+		return modelData{}, fmt.Errorf("invalid model name %q", name)
+	}
+	v := camelCase(n)
+	if token.IsKeyword(v) {
+		v = "item"
+	}
+	words := strings.ReplaceAll(snakeCase(n), "_", " ")
+	d := modelData{
+		Name:        n,
+		Plural:      pluralize(n),
+		Table:       pluralize(n),
+		Var:         v,
+		Label:       words,
+		LabelPlural: pluralize(words),
+	}
+	for _, spec := range strings.Split(fields, ",") {
+		spec = strings.TrimSpace(spec)
+		if spec == "" {
+			continue
+		}
+		f, err := parseModelField(spec)
+		if err != nil {
+			return modelData{}, err
+		}
+		d.Fields = append(d.Fields, f)
+	}
+	return d, nil
+}
+
+// parseModelField turns "serial_number:string" into a struct field
+func parseModelField(spec string) (modelField, error) {
+	name, typ, ok := strings.Cut(spec, ":")
+	if !ok {
+		typ = "string"
+	}
+	f := modelField{Name: pascalCase(name), Column: snakeCase(pascalCase(name))}
+	if f.Name == "" || !token.IsIdentifier(f.Name) {
+		return f, fmt.Errorf("invalid field name %q", name)
+	}
+	var gormTag string
+	switch strings.ToLower(typ) {
+	case "string":
+		f.GoType, gormTag = "string", "size:255"
+	case "text":
+		f.GoType, gormTag = "string", "type:text"
+	case "int":
+		f.GoType = "int"
+	case "uint":
+		f.GoType = "uint"
+	case "float", "float64":
+		f.GoType = "float64"
+	case "bool":
+		f.GoType, gormTag = "bool", "default:false"
+	case "time", "datetime":
+		f.GoType = "*time.Time"
+	default:
+		return f, fmt.Errorf("unsupported field type %q for %s", typ, name)
+	}
+	if gormTag != "" {
+		f.Tag = fmt.Sprintf(`gorm:"%s" json:"%s"`, gormTag, f.Column)
+	} else {
+		f.Tag = fmt.Sprintf(`json:"%s"`, f.Column)
+	}
+	return f, nil
+}
+
+// registerAutoMigrate appends &models.<Name>{} to the db.AutoMigrate call in
+// db.InitDBIfNeeded so the new table is created on the next boot.
+func registerAutoMigrate(p *project, name string) error {
+	file, err := p.findFunc("db", "InitDBIfNeeded")
+	if err != nil {
+		return err
+	}
+	e, err := openSource(file)
+	if err != nil {
+		return err
+	}
+	fn := e.funcDecl("InitDBIfNeeded")
+	var call *ast.CallExpr
+	ast.Inspect(fn.Body, func(n ast.Node) bool {
+		if call != nil {
+			return false
+		}
+		if ce, ok := n.(*ast.CallExpr); ok {
+			if sel, ok := ce.Fun.(*ast.SelectorExpr); ok && sel.Sel.Name == "AutoMigrate" {
+				call = ce
+				return false
+			}
+		}
+		return true
+	})
+	if call == nil {
+		return fmt.Errorf("no AutoMigrate call found in InitDBIfNeeded (%s)", p.rel(file))
+	}
+	entry := "&models." + name + "{}"
+	if e.contains(call.Lparen, call.Rparen, entry) {
+		fmt.Printf("%s already registered in %s\n", entry, p.rel(file))
+		return nil
+	}
+	e.insertAt(call.Rparen, "\t"+entry+",\n\t")
+	if n := len(call.Args); n == 0 {
+		// AutoMigrate() -> AutoMigrate(\n&models.X{},\n)
+		e.insertAt(call.Rparen, "\n")
+	} else if last := call.Args[n-1]; !e.contains(last.End(), call.Rparen, ",") {
+		// Single-line call without a trailing comma
+		e.insertAt(last.End(), ",\n")
+	}
+	e.addImport(p.Module + "/models")
+	if err := e.save(); err != nil {
+		return err
+	}
+	fmt.Printf("Registered %s in %s\n", entry, p.rel(file))
+	return nil
+}
+
+// renderStubFile renders one embedded stub with data
+func renderStubFile(name string, data any) ([]byte, error) {
+	src, err := stubs.ReadFile(name)
+	if err != nil {
+		return nil, err
+	}
+	return renderStub(name, src, data)
+}
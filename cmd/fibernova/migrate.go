@@ -0,0 +1,150 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"text/tabwriter"
+	"time"
+
+	"backend-meta-data/db"
+	_ "backend-meta-data/db/migrations"
+)
+
+func init() {
+	register("migrate", command{
+		Usage:   "",
+		Summary: "Run all pending database migrations",
+		Run:     runMigrate,
+	})
+	register("migrate:rollback", command{
+		Usage:   "[--step N]",
+		Summary: "Roll back the last batch of migrations (or the last N)",
+		Run:     runMigrateRollback,
+	})
+	register("migrate:status", command{
+		Usage:   "",
+		Summary: "Show which migrations have been applied",
+		Run:     runMigrateStatus,
+	})
+	register("make:migration", command{
+		Usage:   "<name>",
+		Summary: "Generate a new migration in db/migrations/",
+		Run:     runMakeMigration,
+	})
+}
+
+func runMigrate(args []string) error {
+	_, gormDB, err := connectDB()
+	if err != nil {
+		return err
+	}
+	ran, err := db.Migrate(gormDB)
+	for _, v := range ran {
+		fmt.Println("Migrated:", v)
+	}
+	if err != nil {
+		return err
+	}
+	if len(ran) == 0 {
+		fmt.Println("Nothing to migrate.")
+	}
+	return nil
+}
+
+func runMigrateRollback(args []string) error {
+	flags := flag.NewFlagSet("migrate:rollback", flag.ContinueOnError)
+	step := flags.Int("step", 0, "number of migrations to roll back (default: the last batch)")
+	if _, err := parseFlags(flags, args); err != nil {
+		return err
+	}
+	_, gormDB, err := connectDB()
+	if err != nil {
+		return err
+	}
+	reverted, err := db.Rollback(gormDB, *step)
+	for _, v := range reverted {
+		fmt.Println("Rolled back:", v)
+	}
+	if err != nil {
+		return err
+	}
+	if len(reverted) == 0 {
+		fmt.Println("Nothing to roll back.")
+	}
+	return nil
+}
+
+func runMigrateStatus(args []string) error {
+	_, gormDB, err := connectDB()
+	if err != nil {
+		return err
+	}
+	states, err := db.MigrationStatus(gormDB)
+	if err != nil {
+		return err
+	}
+	w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(w, "STATUS\tBATCH\tAPPLIED AT\tMIGRATION")
+	for _, st := range states {
+		status, batch, at := "Pending", "", ""
+		if st.Applied {
+			status, batch, at = "Ran", fmt.Sprint(st.Batch), st.AppliedAt.Format(time.DateTime)
+		}
+		if st.Missing {
+			status = "Missing"
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\n", status, batch, at, st.Version)
+	}
+	return w.Flush()
+}
+
+// migrationData is passed to the migration stubs
+type migrationData struct {
+	Module  string
+	Version string
+	Model   string
+}
+
+func runMakeMigration(args []string) error {
+	if len(args) != 1 {
+		return errors.New("usage: fibernova make:migration <name>")
+	}
+	p, err := loadProject()
+	if err != nil {
+		return err
+	}
+	if !p.hasMigrator() {
+		return errors.New("this project has no db.RegisterMigration (e.g. one generated by `fibernova new`); its tables are created by db.InitDBIfNeeded, which `fibernova make:model` updates")
+	}
+	return writeMigration(p, "stubs/make/migration.go.tmpl", args[0], "")
+}
+
+// hasMigrator reports whether the project registers versioned migrations, which
+// the generated migration files call
+func (p *project) hasMigrator() bool {
+	if fi, err := os.Stat(p.path("db", "migrations")); err != nil || !fi.IsDir() {
+		return false
+	}
+	_, err := p.findFunc("db", "RegisterMigration")
+	return err == nil
+}
+
+// writeMigration renders a timestamped migration file into db/migrations/
+func writeMigration(p *project, stub, name, model string) error {
+	slug := snakeCase(pascalCase(name))
+	if slug == "" {
+		return fmt.Errorf("invalid migration name %q", name)
+	}
+	data := migrationData{
+		Module:  p.Module,
+		Version: time.Now().UTC().Format("20060102150405") + "_" + slug,
+		Model:   model,
+	}
+	src, err := renderStubFile(stub, data)
+	if err != nil {
+		return err
+	}
+	return p.writeNew("db/migrations/"+data.Version+".go", src)
+}
@@ -0,0 +1,132 @@
+package main
+
+import (
+	"bytes"
+	"embed"
+	"errors"
+	"flag"
+	"fmt"
+	"io/fs"
+	"os"
+	"os/exec"
+	"path"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"text/template"
+)
+
+//go:embed all:stubs
+var stubs embed.FS
+
+var appNameRe = regexp.MustCompile(`^[A-Za-z][A-Za-z0-9_-]*$`)
+
+// projectData is passed to every stub under stubs/new
+type projectData struct {
+	Name   string
+	Module string
+	Title  string
+}
+
+func init() {
+	register("new", command{
+		Usage:   "<name> [--module path] [--no-install]",
+		Summary: "Create a new FiberNova application",
+		Run:     runNew,
+	})
+}
+
+func runNew(args []string) error {
+	flags := flag.NewFlagSet("new", flag.ContinueOnError)
+	module := flags.String("module", "", "Go module path (defaults to the application name)")
+	noInstall := flags.Bool("no-install", false, "skip `go mod tidy` after generating")
+	pos, err := parseFlags(flags, args)
+	if err != nil {
+		return err
+	}
+	if len(pos) != 1 {
+		return errors.New("usage: fibernova new <name> [--module path] [--no-install]")
+	}
+	dir := pos[0]
+	name := filepath.Base(dir)
+	if !appNameRe.MatchString(name) {
+		return fmt.Errorf("invalid application name %q", name)
+	}
+	if *module == "" {
+		*module = name
+	}
+	if _, err := os.Stat(dir); err == nil {
+		return fmt.Errorf("%s already exists", dir)
+	}
+
+	data := projectData{Name: name, Module: *module, Title: titleize(name)}
+	if err := renderTree("stubs/new", dir, data); err != nil {
+		return err
+	}
+	fmt.Printf("Created FiberNova application %q in %s\n", name, dir)
+
+	if !*noInstall {
+		if _, err := exec.LookPath("go"); err != nil {
+			fmt.Println("go toolchain not found; run `go mod tidy` manually")
+		} else {
+			cmd := exec.Command("go", "mod", "tidy")
+			cmd.Dir = dir
+			cmd.Stdout = os.Stdout
+			cmd.Stderr = os.Stderr
+			if err := cmd.Run(); err != nil {
+				return fmt.Errorf("go mod tidy failed: %w", err)
+			}
+		}
+	}
+	fmt.Printf("\nNext steps:\n  cd %s\n  edit .env\n  go run .\n", dir)
+	return nil
+}
+
+// renderTree copies an embedded stub directory into dst. Files ending in
+// .tmpl are rendered with text/template and lose the suffix; everything
+// else is copied verbatim.
+func renderTree(root, dst string, data any) error {
+	return fs.WalkDir(stubs, root, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		rel := strings.TrimPrefix(strings.TrimPrefix(p, root), "/")
+		target := filepath.Join(dst, filepath.FromSlash(rel))
+		if d.IsDir() {
+			return os.MkdirAll(target, 0o755)
+		}
+		b, err := stubs.ReadFile(p)
+		if err != nil {
+			return err
+		}
+		if strings.HasSuffix(p, ".tmpl") {
+			target = strings.TrimSuffix(target, ".tmpl")
+			if b, err = renderStub(path.Base(p), b, data); err != nil {
+				return err
+			}
+		}
+		return os.WriteFile(target, b, 0o644)
+	})
+}
+
+// renderStub executes a single stub template
+func renderStub(name string, src []byte, data any) ([]byte, error) {
+	t, err := template.New(name).Parse(string(src))
+	if err != nil {
+		return nil, fmt.Errorf("parse stub %s: %w", name, err)
+	}
+	var buf bytes.Buffer
+	if err := t.Execute(&buf, data); err != nil {
+		return nil, fmt.Errorf("render stub %s: %w", name, err)
+	}
+	return buf.Bytes(), nil
+}
+
+// titleize turns "my-app" into "My App"
+func titleize(s string) string {
+	words := strings.FieldsFunc(s, func(r rune) bool { return r == '-' || r == '_' })
+	for i, w := range words {
+		words[i] = strings.ToUpper(w[:1]) + w[1:]
+	}
+	return strings.Join(words, " ")
+}
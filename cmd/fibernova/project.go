@@ -0,0 +1,195 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"go/ast"
+	"go/format"
+	"go/parser"
+	"go/token"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// project describes the FiberNova application in the working directory
+type project struct {
+	Root   string
+	Module string
+}
+
+// loadProject reads the module path from ./go.mod
+func loadProject() (*project, error) {
+	root, err := os.Getwd()
+	if err != nil {
+		return nil, err
+	}
+	f, err := os.Open(filepath.Join(root, "go.mod"))
+	if err != nil {
+		return nil, errors.New("go.mod not found; run this command from the application root")
+	}
+	defer f.Close()
+	sc := bufio.NewScanner(f)
+	for sc.Scan() {
+		line := strings.TrimSpace(sc.Text())
+		if strings.HasPrefix(line, "module ") {
+			return &project{Root: root, Module: strings.Trim(strings.TrimSpace(strings.TrimPrefix(line, "module ")), `"`)}, nil
+		}
+	}
+	return nil, errors.New("module directive not found in go.mod")
+}
+
+// path joins elements onto the project root
+func (p *project) path(elem ...string) string {
+	return filepath.Join(append([]string{p.Root}, elem...)...)
+}
+
+// writeNew writes a generated file, refusing to overwrite an existing one
+func (p *project) writeNew(rel string, content []byte) error {
+	target := p.path(rel)
+	if _, err := os.Stat(target); err == nil {
+		return fmt.Errorf("%s already exists", rel)
+	}
+	if err := os.MkdirAll(filepath.Dir(target), 0o755); err != nil {
+		return err
+	}
+	if strings.HasSuffix(rel, ".go") {
+		formatted, err := format.Source(content)
+		if err != nil {
+			return fmt.Errorf("format %s: %w", rel, err)
+		}
+		content = formatted
+	}
+	if err := os.WriteFile(target, content, 0o644); err != nil {
+		return err
+	}
+	fmt.Println("Created", rel)
+	return nil
+}
+
+// findFunc locates the Go file in dir that declares the top-level function name
+func (p *project) findFunc(dir, name string) (string, error) {
+	matches, err := filepath.Glob(p.path(dir, "*.go"))
+	if err != nil {
+		return "", err
+	}
+	for _, file := range matches {
+		src, err := os.ReadFile(file)
+		if err != nil {
+			return "", err
+		}
+		f, err := parser.ParseFile(token.NewFileSet(), file, src, parser.SkipObjectResolution)
+		if err != nil {
+			continue
+		}
+		for _, d := range f.Decls {
+			if fn, ok := d.(*ast.FuncDecl); ok && fn.Recv == nil && fn.Name.Name == name {
+				return file, nil
+			}
+		}
+	}
+	return "", fmt.Errorf("func %s not found in %s/", name, dir)
+}
+
+// sourceEdit is a parsed Go file that accumulates text insertions
+type sourceEdit struct {
+	path string
+	fset *token.FileSet
+	file *ast.File
+	src  []byte
+}
+
+func openSource(path string) (*sourceEdit, error) {
+	src, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	fset := token.NewFileSet()
+	f, err := parser.ParseFile(fset, path, src, parser.ParseComments|parser.SkipObjectResolution)
+	if err != nil {
+		return nil, err
+	}
+	return &sourceEdit{path: path, fset: fset, file: f, src: src}, nil
+}
+
+// funcDecl returns the top-level function called name
+func (e *sourceEdit) funcDecl(name string) *ast.FuncDecl {
+	for _, d := range e.file.Decls {
+		if fn, ok := d.(*ast.FuncDecl); ok && fn.Recv == nil && fn.Name.Name == name {
+			return fn
+		}
+	}
+	return nil
+}
+
+// offset converts a token position into a byte offset into src
+func (e *sourceEdit) offset(pos token.Pos) int {
+	return e.fset.Position(pos).Offset
+}
+
+// contains reports whether the source between two positions contains s
+func (e *sourceEdit) contains(from, to token.Pos, s string) bool {
+	return bytes.Contains(e.src[e.offset(from):e.offset(to)], []byte(s))
+}
+
+// insertAt inserts text at the given position. Insertions must be applied
+// from the end of the file backwards, so callers re-open the file after use.
+func (e *sourceEdit) insertAt(pos token.Pos, text string) {
+	off := e.offset(pos)
+	out := make([]byte, 0, len(e.src)+len(text))
+	out = append(out, e.src[:off]...)
+	out = append(out, text...)
+	out = append(out, e.src[off:]...)
+	e.src = out
+}
+
+// hasImport reports whether the file imports path
+func (e *sourceEdit) hasImport(path string) bool {
+	for _, imp := range e.file.Imports {
+		if v, _ := strconv.Unquote(imp.Path.Value); v == path {
+			return true
+		}
+	}
+	return false
+}
+
+// addImport adds path to the file's first import block if missing. It must
+// be called after any other insertions, since it edits near the top.
+func (e *sourceEdit) addImport(path string) {
+	if e.hasImport(path) {
+		return
+	}
+	for _, d := range e.file.Decls {
+		gd, ok := d.(*ast.GenDecl)
+		if !ok || gd.Tok != token.IMPORT {
+			continue
+		}
+		if gd.Lparen.IsValid() {
+			e.insertAt(gd.Lparen+1, "\n\t"+strconv.Quote(path))
+		} else {
+			e.insertAt(gd.Pos(), "import "+strconv.Quote(path)+"\n")
+		}
+		return
+	}
+	e.insertAt(e.file.Name.End(), "\n\nimport "+strconv.Quote(path))
+}
+
+// save formats and writes the edited source back to disk
+func (e *sourceEdit) save() error {
+	out, err := format.Source(e.src)
+	if err != nil {
+		return fmt.Errorf("format %s: %w", e.path, err)
+	}
+	return os.WriteFile(e.path, out, 0o644)
+}
+
+// rel returns path relative to the project root for display
+func (p *project) rel(path string) string {
+	if r, err := filepath.Rel(p.Root, path); err == nil {
+		return r
+	}
+	return path
+}
@@ -0,0 +1,194 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"reflect"
+	"runtime"
+	"sort"
+	"strings"
+	"text/tabwriter"
+
+	"backend-meta-data/config"
+	"backend-meta-data/middleware"
+	"backend-meta-data/routes"
+
+	"github.com/casbin/casbin/v2/util"
+	"github.com/gofiber/fiber/v2"
+)
+
+// authMiddleware are the registry names that identify the caller
+var authMiddleware = map[string]bool{"auth": true}
+
+// rbacMiddleware is the registry name of the middleware that enforces Casbin policies
+const rbacMiddleware = "rbac"
+
+func init() {
+	register("route:list", command{
+		Usage:   "[--method GET] [--path /api/users] [--open] [--no-db]",
+		Summary: "List every registered route with its middleware and matching Casbin policies",
+		Run:     runRouteList,
+	})
+}
+
+// routeRow is one line of route:list output
+type routeRow struct {
+	Method     string
+	Path       string
+	Handler    string
+	Middleware []string
+	Policies   []string
+	Open       bool
+	// Enforced is set when rbac is in the route's own chain; policies matching
+	// other routes are never checked for them
+	Enforced bool
+}
+
+func runRouteList(args []string) error {
+	flags := flag.NewFlagSet("route:list", flag.ContinueOnError)
+	method := flags.String("method", "", "only show routes for this HTTP method")
+	prefix := flags.String("path", "", "only show routes whose path starts with this prefix")
+	open := flags.Bool("open", false, "only show routes without authentication middleware")
+	noDB := flags.Bool("no-db", false, "skip connecting to the database (no Casbin policies)")
+	if _, err := parseFlags(flags, args); err != nil {
+		return err
+	}
+
+	app := fiber.New(fiber.Config{DisableStartupMessage: true})
+	// Handlers are only inspected, never called, so they need no database or settings
+	routes.RegisterRoutes(app, nil, &config.Config{})
+
+	var policies [][]string
+	if !*noDB {
+		var err error
+		if policies, err = loadPolicies(); err != nil {
+			fmt.Fprintf(os.Stderr, "warning: Casbin policies unavailable (%v); use --no-db to silence\n", err)
+		}
+	}
+
+	var rows []routeRow
+	for _, r := range app.GetRoutes(true) {
+		if r.Method == fiber.MethodHead {
+			continue // fiber mirrors every GET as HEAD
+		}
+		if *method != "" && !strings.EqualFold(r.Method, *method) {
+			continue
+		}
+		if *prefix != "" && !strings.HasPrefix(r.Path, *prefix) {
+			continue
+		}
+		row := describeRoute(r, policies)
+		if *open && !row.Open {
+			continue
+		}
+		rows = append(rows, row)
+	}
+	sort.SliceStable(rows, func(i, j int) bool {
+		if rows[i].Path != rows[j].Path {
+			return rows[i].Path < rows[j].Path
+		}
+		return rows[i].Method < rows[j].Method
+	})
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(w, "METHOD\tPATH\tHANDLER\tMIDDLEWARE\tRBAC POLICIES")
+	for _, row := range rows {
+		mw := strings.Join(row.Middleware, ",")
+		if mw == "" {
+			mw = "-"
+		}
+		if row.Open {
+			mw += " (open)"
+		}
+		pol := strings.Join(row.Policies, "; ")
+		switch {
+		case !row.Enforced:
+			pol = "not enforced"
+		case policies == nil:
+			pol = "?"
+		case pol == "":
+			pol = "-"
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\n", row.Method, row.Path, row.Handler, mw, pol)
+	}
+	if err := w.Flush(); err != nil {
+		return err
+	}
+	fmt.Printf("\n%d routes\n", len(rows))
+	return nil
+}
+
+// describeRoute splits a route's handler chain into middleware and the final handler
+func describeRoute(r fiber.Route, policies [][]string) routeRow {
+	row := routeRow{Method: r.Method, Path: r.Path, Open: true}
+	for i, h := range r.Handlers {
+		name, named := middleware.NameOf(h)
+		if !named {
+			name = funcName(h)
+		}
+		if i == len(r.Handlers)-1 {
+			row.Handler = name
+			continue
+		}
+		row.Middleware = append(row.Middleware, name)
+		if authMiddleware[name] {
+			row.Open = false
+		}
+		if name == rbacMiddleware {
+			row.Enforced = true
+		}
+	}
+	if !row.Enforced {
+		return row
+	}
+	for _, p := range policies {
+		if len(p) < 3 {
+			continue
+		}
+		if util.KeyMatch2(r.Path, p[1]) && util.RegexMatch(r.Method, p[2]) {
+			row.Policies = append(row.Policies, fmt.Sprintf("%s %s %s", p[0], p[1], p[2]))
+		}
+	}
+	return row
+}
+
+// funcName returns a short name such as "controllers.ListStations" for a handler
+func funcName(h fiber.Handler) string {
+	fn := runtime.FuncForPC(reflect.ValueOf(h).Pointer())
+	if fn == nil {
+		return "?"
+	}
+	name := fn.Name()
+	if i := strings.LastIndex(name, "/"); i >= 0 {
+		name = name[i+1:]
+	}
+	// Drop closure suffixes added by handler factories (".func1", ".func1.1")
+	for {
+		i := strings.LastIndex(name, ".func")
+		if i < 0 {
+			break
+		}
+		name = name[:i]
+	}
+	return name
+}
+
+// loadPolicies connects with the application config and returns all Casbin "p" rules
+func loadPolicies() ([][]string, error) {
+	_, gormDB, err := connectDB()
+	if err != nil {
+		return nil, err
+	}
+	if err := middleware.InitCasbin(gormDB); err != nil {
+		return nil, err
+	}
+	policies, err := middleware.Policies()
+	if err != nil {
+		return nil, err
+	}
+	if policies == nil {
+		policies = [][]string{}
+	}
+	return policies, nil
+}
@@ -0,0 +1,48 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+
+	"backend-meta-data/db"
+	_ "backend-meta-data/db/seeders"
+)
+
+func init() {
+	register("db:seed", command{
+		Usage:   "[--class Name] [--list]",
+		Summary: "Run all database seeders, or a single one",
+		Run:     runDBSeed,
+	})
+}
+
+func runDBSeed(args []string) error {
+	flags := flag.NewFlagSet("db:seed", flag.ContinueOnError)
+	class := flags.String("class", "", "run only this seeder (e.g. RootUserSeeder)")
+	list := flags.Bool("list", false, "list the registered seeders in run order")
+	if _, err := parseFlags(flags, args); err != nil {
+		return err
+	}
+	if *list {
+		for _, s := range db.Seeders() {
+			fmt.Println(s.Name())
+		}
+		return nil
+	}
+	var names []string
+	if *class != "" {
+		if _, ok := db.FindSeeder(*class); !ok {
+			return fmt.Errorf("unknown seeder %q; see `fibernova db:seed --list`", *class)
+		}
+		names = append(names, *class)
+	}
+	_, gormDB, err := connectDB()
+	if err != nil {
+		return err
+	}
+	done, err := db.Seed(gormDB, names...)
+	for _, name := range done {
+		fmt.Println("Seeded:", name)
+	}
+	return err
+}
@@ -0,0 +1,59 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"time"
+
+	"backend-meta-data/models"
+)
+
+func init() {
+	register("users:hash-passwords", command{
+		Usage:   "",
+		Summary: "Hash any user passwords still stored as plaintext",
+		Run:     runUsersHashPasswords,
+	})
+	register("auth:prune-tokens", command{
+		Usage:   "",
+		Summary: "Delete expired refresh tokens, access token denylist entries, sessions and login throttles",
+		Run:     runAuthPruneTokens,
+	})
+}
+
+func runUsersHashPasswords(args []string) error {
+	flags := flag.NewFlagSet("users:hash-passwords", flag.ContinueOnError)
+	if _, err := parseFlags(flags, args); err != nil {
+		return err
+	}
+	_, gormDB, err := connectDB()
+	if err != nil {
+		return err
+	}
+	n, err := models.HashPlaintextPasswords(gormDB)
+	fmt.Printf("Hashed %d password(s).\n", n)
+	return err
+}
+
+func runAuthPruneTokens(args []string) error {
+	flags := flag.NewFlagSet("auth:prune-tokens", flag.ContinueOnError)
+	if _, err := parseFlags(flags, args); err != nil {
+		return err
+	}
+	cfg, gormDB, err := connectDB()
+	if err != nil {
+		return err
+	}
+	now := time.Now()
+	n, err := models.PruneExpiredTokens(gormDB, now)
+	if err != nil {
+		return err
+	}
+	sessions, err := models.PruneExpiredSessions(gormDB, now)
+	if err != nil {
+		return err
+	}
+	throttles, err := models.PruneLoginThrottles(gormDB, now.Add(-cfg.Auth.LockoutDuration))
+	fmt.Printf("Deleted %d expired token(s), %d session(s) and %d login throttle(s).\n", n, sessions, throttles)
+	return err
+}
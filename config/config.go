@@ -1,24 +1,81 @@
 package config
 
 import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"backend-meta-data/auth/password"
+
 	"github.com/BurntSushi/toml"
 )
 
 type Config struct {
-	App AppConfig `toml:"app"`
-	DB  DBConfig  `toml:"db"`
+	App     AppConfig     `toml:"app"`
+	DB      DBConfig      `toml:"db"`
+	Auth    AuthConfig    `toml:"auth"`
+	LDAP    LDAPConfig    `toml:"ldap"`
+	OIDC    OIDCConfig    `toml:"oidc"`
+	Session SessionConfig `toml:"session"`
+	SMTP    SMTPConfig    `toml:"smtp"`
+	CORS    CORSConfig    `toml:"cors"`
+
+	// Files lists the config files that were merged, in load order
+	Files []string `toml:"-"`
 }
 
 type AppConfig struct {
 	WebsiteName string `toml:"website_name"`
-	Env         string `toml:"env"`
+	Env         string `toml:"env"` // local, dev, uat, prod or test; selects config.<env>.toml
 	Port        int    `toml:"port"`
 	Hostname    string `toml:"hostname"`
 	AutoMigrate bool   `toml:"auto_migrate"`
+	LogLevel    string `toml:"log_level"` // trace, debug, info (default), warn, error
+	// PublicURL is the web client that links in account emails open, e.g.
+	// https://meta.example.com; required with [smtp] host
+	PublicURL string `toml:"public_url"`
+
+	// TrustedProxies is a comma separated list of IPs or CIDRs of the reverse
+	// proxies in front of the app. Only requests from them may give the client
+	// address in ProxyHeader; empty uses the connection's address.
+	TrustedProxies string `toml:"trusted_proxies"`
+	ProxyHeader    string `toml:"proxy_header"` // default X-Forwarded-For when trusted_proxies is set
+
+	// WatchInterval polls the config files and Casbin model for changes; 0 disables
+	// polling (SIGHUP still reloads). Defaults to 2s in local/dev.
+	WatchInterval time.Duration `toml:"watch_interval"`
+	// PolicyPollInterval checks casbin_rule for changes made outside the server
+	// (fibernova ldap:sync, db:seed, other instances) and reloads the policies
+	PolicyPollInterval time.Duration `toml:"policy_poll_interval"` // default 30s
 }
 
 type DBConfig struct {
-	Type     string `toml:"type"`
+	Type     string `toml:"type"` // mysql (default), postgres, sqlserver or sqlite
+	Host     string `toml:"host"`
+	Port     int    `toml:"port"`
+	User     string `toml:"user"`
+	Password string `toml:"password"`
+	Name     string `toml:"name"`    // database name; file path for sqlite
+	SSLMode  string `toml:"sslmode"` // postgres only, defaults to "disable"
+
+	// Connection pool; zero values keep the database/sql defaults
+	MaxOpenConns    int           `toml:"max_open_conns"`
+	MaxIdleConns    int           `toml:"max_idle_conns"`
+	ConnMaxLifetime time.Duration `toml:"conn_max_lifetime"` // e.g. "30m"
+	ConnMaxIdleTime time.Duration `toml:"conn_max_idle_time"`
+
+	// Read replicas ([[db.replicas]]); list endpoints read from them via middleware.ReadFrom
+	Replicas []DBReplicaConfig `toml:"replicas"`
+	// StickyWindow keeps a client's reads on the primary for this long after it writes (default 5s)
+	StickyWindow time.Duration `toml:"sticky_window"`
+}
+
+// DBReplicaConfig is a read replica of the primary; empty fields inherit the primary's value
+type DBReplicaConfig struct {
 	Host     string `toml:"host"`
 	Port     int    `toml:"port"`
 	User     string `toml:"user"`
@@ -26,10 +83,382 @@ type DBConfig struct {
 	Name     string `toml:"name"`
 }
 
-func LoadConfig(path string) (*Config, error) {
-	var config Config
-	if _, err := toml.DecodeFile(path, &config); err != nil {
+// AuthConfig holds token settings shared by every login flow
+type AuthConfig struct {
+	JWTSecret string        `toml:"jwt_secret"`
+	TokenTTL  time.Duration `toml:"token_ttl"` // access token lifetime, default 15m
+	// RefreshTTL is how long a refresh token (and its rotations) stays usable
+	RefreshTTL time.Duration `toml:"refresh_ttl"` // default 720h
+	// APIKeyTTL is the default and longest lifetime of an API key
+	APIKeyTTL time.Duration `toml:"api_key_ttl"` // default 2160h (90 days)
+
+	// Password hashing for local accounts; hashes made with other settings are
+	// upgraded at the user's next login
+	PasswordHash      string `toml:"password_hash"`      // argon2id (default) or bcrypt
+	BcryptCost        int    `toml:"bcrypt_cost"`        // default 12
+	Argon2Memory      int    `toml:"argon2_memory"`      // KiB, default 65536
+	Argon2Iterations  int    `toml:"argon2_iterations"`  // default 3
+	Argon2Parallelism int    `toml:"argon2_parallelism"` // default 2
+
+	// MFARequiredRoles lists roles (Root, Admin, Inspector) that must pass a TOTP
+	// code on local and LDAP logins; users who have not enrolled are made to
+	// enroll at their next login. Users of other roles may opt in.
+	MFARequiredRoles string `toml:"mfa_required_roles"`
+	MFAIssuer        string `toml:"mfa_issuer"` // name shown in authenticator apps, default app.website_name
+
+	// Lifetime of the links sent by POST /auth/password/forgot and /me/email/verification
+	PasswordResetTTL time.Duration `toml:"password_reset_ttl"` // default 1h
+	EmailVerifyTTL   time.Duration `toml:"email_verify_ttl"`   // default 48h
+
+	// Brute-force protection for password and MFA attempts, counted per
+	// username and per IP. From throttle_failures failures on (throttle_ip_failures
+	// for an IP) further attempts are refused for throttle_backoff, doubling with
+	// each failure up to lockout_duration. lockout_failures failures for a
+	// username lock the account for lockout_duration. Counts are forgotten
+	// lockout_duration after the last failure.
+	ThrottleFailures   int           `toml:"throttle_failures"`    // default 5
+	ThrottleIPFailures int           `toml:"throttle_ip_failures"` // default 20
+	ThrottleBackoff    time.Duration `toml:"throttle_backoff"`     // default 30s
+	LockoutFailures    int           `toml:"lockout_failures"`     // default 10
+	LockoutDuration    time.Duration `toml:"lockout_duration"`     // default 15m
+
+	// Providers lists the password providers POST /login tries, in order:
+	// local, ldap. Defaults to "local", plus "ldap" when ldap.url is set.
+	Providers string `toml:"providers"`
+	// SSOHeader carries the username set by a reverse proxy doing Windows SSO
+	SSOHeader string `toml:"sso_header"` // default X-AD-Username
+	// SSOTrustedProxies is a comma separated list of IPs or CIDRs allowed to set
+	// SSOHeader; empty disables /auth/sso
+	SSOTrustedProxies string `toml:"sso_trusted_proxies"`
+}
+
+// ProviderNames splits Providers into trimmed, non-empty names
+func (a AuthConfig) ProviderNames() []string {
+	return splitList(a.Providers)
+}
+
+// MFARoles splits MFARequiredRoles into trimmed, non-empty roles
+func (a AuthConfig) MFARoles() []string {
+	return splitList(a.MFARequiredRoles)
+}
+
+// PasswordParams converts the hashing settings for password.SetDefault
+func (a AuthConfig) PasswordParams() password.Params {
+	return password.Params{
+		Algorithm:   a.PasswordHash,
+		BcryptCost:  a.BcryptCost,
+		Memory:      uint32(a.Argon2Memory),
+		Iterations:  uint32(a.Argon2Iterations),
+		Parallelism: uint8(a.Argon2Parallelism),
+	}
+}
+
+// LDAPConfig is the Active Directory connection; an empty URL disables LDAP logins
+type LDAPConfig struct {
+	URL         string `toml:"url"` // ldap://host:389 or ldaps://host:636
+	BaseDN      string `toml:"base_dn"`
+	ServiceUser string `toml:"service_user"` // DN or UPN of the account that searches for users
+	ServicePass string `toml:"service_pass"`
+
+	// UserFilter finds the user logging in; {username} is replaced with the
+	// escaped login name. Defaults to matching sAMAccountName, userPrincipalName or uid.
+	UserFilter      string `toml:"user_filter"`
+	UsernameAttr    string `toml:"username_attr"`     // local username, default sAMAccountName (falls back to uid)
+	EmailAttr       string `toml:"email_attr"`        // default mail
+	DisplayNameAttr string `toml:"display_name_attr"` // default displayName
+
+	StartTLS           bool          `toml:"start_tls"`            // upgrade ldap:// connections with StartTLS
+	CACert             string        `toml:"ca_cert"`              // PEM file trusted in addition to the system roots
+	InsecureSkipVerify bool          `toml:"insecure_skip_verify"` // local/dev only
+	PoolSize           int           `toml:"pool_size"`            // idle service connections kept open, default 4
+	Timeout            time.Duration `toml:"timeout"`              // dial and request timeout, default 10s
+
+	AutoProvision bool   `toml:"auto_provision"` // create a local user on first AD login
+	DefaultRole   string `toml:"default_role"`   // role of provisioned users and of users in no mapped group, default Inspector
+
+	// GroupRoles ([ldap.group_roles]) maps group DNs from memberOf to Root, Admin
+	// or Inspector; a member of several groups gets the highest role. Applied at
+	// login and by `fibernova ldap:sync`.
+	GroupRoles map[string]string `toml:"group_roles"`
+}
+
+// OIDCConfig validates tokens from an OpenID Connect provider (Azure AD, ADFS,
+// Keycloak); an empty Issuer disables the "oidc" middleware and /auth/oidc
+type OIDCConfig struct {
+	Issuer        string        `toml:"issuer"`         // e.g. https://login.microsoftonline.com/<tenant>/v2.0
+	Audience      string        `toml:"audience"`       // client ID the tokens are issued for
+	UsernameClaim string        `toml:"username_claim"` // default preferred_username
+	EmailClaim    string        `toml:"email_claim"`    // default email
+	AutoProvision bool          `toml:"auto_provision"` // create unknown users on their first token
+	DefaultRole   string        `toml:"default_role"`   // role of provisioned users, default Inspector
+	JWKSCacheTTL  time.Duration `toml:"jwks_cache_ttl"` // how long signing keys are cached, default 1h
+}
+
+// SessionConfig is the login session cookie; sessions are kept in the
+// Sessions table so every instance shares them
+type SessionConfig struct {
+	CookieName     string `toml:"cookie_name"` // default session_id
+	CookieDomain   string `toml:"cookie_domain"`
+	CookieSameSite string `toml:"cookie_samesite"` // Strict, Lax (default) or None
+	CookieSecure   bool   `toml:"cookie_secure"`   // always on outside local/dev
+
+	IdleTimeout     time.Duration `toml:"idle_timeout"`     // ends an unused session, default 30m
+	AbsoluteTimeout time.Duration `toml:"absolute_timeout"` // ends any session this long after login, default 12h
+}
+
+// SMTPConfig is the outgoing mail server; an empty Host disables sending
+type SMTPConfig struct {
+	Host      string `toml:"host"`
+	Port      int    `toml:"port"`
+	Username  string `toml:"username"`
+	Password  string `toml:"password"`
+	FromName  string `toml:"from_name"`
+	FromEmail string `toml:"from_email"`
+	UseTLS    bool   `toml:"use_tls"`
+}
+
+// CORSConfig mirrors the comma separated lists of fiber's cors.Config
+type CORSConfig struct {
+	AllowOrigins     string `toml:"allow_origins"`
+	AllowHeaders     string `toml:"allow_headers"`
+	AllowMethods     string `toml:"allow_methods"`
+	AllowCredentials bool   `toml:"allow_credentials"`
+}
+
+// EnvPrefix prefixes environment overrides: FIBERNOVA_<SECTION>_<KEY>, e.g. FIBERNOVA_DB_HOST
+const EnvPrefix = "FIBERNOVA"
+
+// Load builds the configuration for the app in dir by merging, in order:
+//
+//  1. config.toml (or the legacy .env TOML file when config.toml is absent)
+//  2. config.<env>.toml, where env is FIBERNOVA_APP_ENV or app.env
+//  3. FIBERNOVA_<SECTION>_<KEY> environment variables
+//
+// Defaults are applied afterwards and the result is validated; a
+// *ValidationError lists every missing or invalid key at once.
+func Load(dir string) (*Config, error) {
+	cfg := &Config{}
+	var problems []string
+
+	base := filepath.Join(dir, "config.toml")
+	if _, err := os.Stat(base); errors.Is(err, os.ErrNotExist) {
+		base = filepath.Join(dir, ".env")
+	}
+	if err := cfg.decodeFile(base, &problems); err != nil {
 		return nil, err
 	}
-	return &config, nil
+
+	env := cfg.App.Env
+	if v, ok := os.LookupEnv(EnvPrefix + "_APP_ENV"); ok {
+		env = v
+	}
+	if validEnv(env) { // anything else is reported by validate
+		layer := filepath.Join(dir, "config."+env+".toml")
+		if _, err := os.Stat(layer); err == nil {
+			if err := cfg.decodeFile(layer, &problems); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	problems = append(problems, applyEnv(cfg, os.Environ())...)
+	cfg.applyDefaults()
+	problems = append(problems, cfg.validate()...)
+	if len(problems) > 0 {
+		return cfg, &ValidationError{Files: cfg.Files, Problems: problems}
+	}
+	return cfg, nil
+}
+
+// decodeFile merges path into cfg; keys the file does not mention keep their value
+func (cfg *Config) decodeFile(path string, problems *[]string) error {
+	md, err := toml.DecodeFile(path, cfg)
+	if err != nil {
+		return fmt.Errorf("config %s: %w", path, err)
+	}
+	cfg.Files = append(cfg.Files, path)
+	var unknown []string
+	for _, key := range md.Undecoded() {
+		unknown = append(unknown, key.String())
+	}
+	sort.Strings(unknown)
+	for _, key := range unknown {
+		*problems = append(*problems, fmt.Sprintf("%s: unknown key in %s", key, path))
+	}
+	return nil
+}
+
+func (cfg *Config) applyDefaults() {
+	if cfg.App.Env == "" {
+		cfg.App.Env = "local"
+	}
+	if cfg.App.Port == 0 {
+		cfg.App.Port = 3881
+	}
+	if cfg.App.LogLevel == "" {
+		cfg.App.LogLevel = "info"
+	}
+	if cfg.App.WatchInterval == 0 && cfg.App.IsDevelopment() {
+		cfg.App.WatchInterval = 2 * time.Second
+	}
+	if cfg.App.PolicyPollInterval == 0 {
+		cfg.App.PolicyPollInterval = 30 * time.Second
+	}
+	if cfg.App.ProxyHeader == "" && cfg.App.TrustedProxies != "" {
+		cfg.App.ProxyHeader = "X-Forwarded-For"
+	}
+	if cfg.Auth.TokenTTL == 0 {
+		cfg.Auth.TokenTTL = 15 * time.Minute
+	}
+	if cfg.Auth.RefreshTTL == 0 {
+		cfg.Auth.RefreshTTL = 30 * 24 * time.Hour
+	}
+	if cfg.Auth.APIKeyTTL == 0 {
+		cfg.Auth.APIKeyTTL = 90 * 24 * time.Hour
+	}
+	if cfg.Auth.PasswordResetTTL == 0 {
+		cfg.Auth.PasswordResetTTL = time.Hour
+	}
+	if cfg.Auth.EmailVerifyTTL == 0 {
+		cfg.Auth.EmailVerifyTTL = 48 * time.Hour
+	}
+	if cfg.Auth.Providers == "" {
+		cfg.Auth.Providers = "local"
+		if cfg.LDAP.URL != "" {
+			cfg.Auth.Providers += ",ldap"
+		}
+	}
+	if cfg.Auth.ThrottleFailures == 0 {
+		cfg.Auth.ThrottleFailures = 5
+	}
+	if cfg.Auth.ThrottleIPFailures == 0 {
+		cfg.Auth.ThrottleIPFailures = 20
+	}
+	if cfg.Auth.ThrottleBackoff == 0 {
+		cfg.Auth.ThrottleBackoff = 30 * time.Second
+	}
+	if cfg.Auth.LockoutFailures == 0 {
+		cfg.Auth.LockoutFailures = 10
+	}
+	if cfg.Auth.LockoutDuration == 0 {
+		cfg.Auth.LockoutDuration = 15 * time.Minute
+	}
+	if cfg.Auth.MFAIssuer == "" {
+		cfg.Auth.MFAIssuer = cfg.App.WebsiteName
+	}
+	if cfg.Auth.MFAIssuer == "" {
+		cfg.Auth.MFAIssuer = "FiberNova"
+	}
+	if cfg.Auth.SSOHeader == "" {
+		cfg.Auth.SSOHeader = "X-AD-Username"
+	}
+	if cfg.Auth.PasswordHash == "" {
+		cfg.Auth.PasswordHash = password.DefaultParams.Algorithm
+	}
+	if cfg.Auth.BcryptCost == 0 {
+		cfg.Auth.BcryptCost = password.DefaultParams.BcryptCost
+	}
+	if cfg.Auth.Argon2Memory == 0 {
+		cfg.Auth.Argon2Memory = int(password.DefaultParams.Memory)
+	}
+	if cfg.Auth.Argon2Iterations == 0 {
+		cfg.Auth.Argon2Iterations = int(password.DefaultParams.Iterations)
+	}
+	if cfg.Auth.Argon2Parallelism == 0 {
+		cfg.Auth.Argon2Parallelism = int(password.DefaultParams.Parallelism)
+	}
+	if cfg.LDAP.UserFilter == "" {
+		cfg.LDAP.UserFilter = "(|(sAMAccountName={username})(userPrincipalName={username})(uid={username}))"
+	}
+	if cfg.LDAP.UsernameAttr == "" {
+		cfg.LDAP.UsernameAttr = "sAMAccountName"
+	}
+	if cfg.LDAP.EmailAttr == "" {
+		cfg.LDAP.EmailAttr = "mail"
+	}
+	if cfg.LDAP.DisplayNameAttr == "" {
+		cfg.LDAP.DisplayNameAttr = "displayName"
+	}
+	if cfg.LDAP.PoolSize == 0 {
+		cfg.LDAP.PoolSize = 4
+	}
+	if cfg.LDAP.Timeout == 0 {
+		cfg.LDAP.Timeout = 10 * time.Second
+	}
+	if cfg.LDAP.DefaultRole == "" {
+		cfg.LDAP.DefaultRole = "Inspector"
+	}
+	if cfg.OIDC.UsernameClaim == "" {
+		cfg.OIDC.UsernameClaim = "preferred_username"
+	}
+	if cfg.OIDC.EmailClaim == "" {
+		cfg.OIDC.EmailClaim = "email"
+	}
+	if cfg.OIDC.DefaultRole == "" {
+		cfg.OIDC.DefaultRole = "Inspector"
+	}
+	if cfg.OIDC.JWKSCacheTTL == 0 {
+		cfg.OIDC.JWKSCacheTTL = time.Hour
+	}
+	if cfg.Session.CookieName == "" {
+		cfg.Session.CookieName = "session_id"
+	}
+	if cfg.Session.CookieSameSite == "" {
+		cfg.Session.CookieSameSite = "Lax"
+	}
+	if !cfg.App.IsDevelopment() {
+		cfg.Session.CookieSecure = true
+	}
+	if cfg.Session.IdleTimeout == 0 {
+		cfg.Session.IdleTimeout = 30 * time.Minute
+	}
+	if cfg.Session.AbsoluteTimeout == 0 {
+		cfg.Session.AbsoluteTimeout = 12 * time.Hour
+	}
+	if cfg.SMTP.Host != "" && cfg.SMTP.Port == 0 {
+		cfg.SMTP.Port = 587
+	}
+	if cfg.CORS.AllowOrigins == "" {
+		// Without a [cors] section keep serving the local Nuxt dev server with cookies
+		cfg.CORS.AllowOrigins = "http://localhost:3000"
+		cfg.CORS.AllowCredentials = true
+	}
+	if cfg.CORS.AllowHeaders == "" {
+		cfg.CORS.AllowHeaders = "Origin, Content-Type, Accept, Authorization"
+	}
+	if cfg.CORS.AllowMethods == "" {
+		cfg.CORS.AllowMethods = "GET,POST,PUT,PATCH,DELETE,OPTIONS"
+	}
+}
+
+// ValidationError reports every configuration problem found by Load
+type ValidationError struct {
+	Files    []string
+	Problems []string
+}
+
+func (e *ValidationError) Error() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "invalid configuration (%s):", strings.Join(e.Files, ", "))
+	for _, p := range e.Problems {
+		b.WriteString("\n  - ")
+		b.WriteString(p)
+	}
+	return b.String()
+}
+
+// TrustedProxyList splits TrustedProxies for fiber.Config
+func (a AppConfig) TrustedProxyList() []string {
+	return splitList(a.TrustedProxies)
+}
+
+// splitList splits a comma separated config value, dropping empty entries
+func splitList(s string) []string {
+	var out []string
+	for _, v := range strings.Split(s, ",") {
+		if v = strings.TrimSpace(v); v != "" {
+			out = append(out, v)
+		}
+	}
+	return out
 }
@@ -0,0 +1,188 @@
+package config
+
+import (
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var durationType = reflect.TypeOf(time.Duration(0))
+
+// EnvKeys returns every supported environment override, e.g. FIBERNOVA_DB_HOST
+func EnvKeys() []string {
+	var keys []string
+	eachField(reflect.ValueOf(&Config{}).Elem(), func(section, key string, _ reflect.Value) {
+		keys = append(keys, envName(section, key))
+	})
+	return keys
+}
+
+// applyEnv overrides scalar config keys from FIBERNOVA_<SECTION>_<KEY>
+// variables in environ and returns the values that could not be parsed
+func applyEnv(cfg *Config, environ []string) []string {
+	vars := make(map[string]string, len(environ))
+	for _, kv := range environ {
+		if k, v, ok := strings.Cut(kv, "="); ok && strings.HasPrefix(k, EnvPrefix+"_") {
+			vars[k] = v
+		}
+	}
+	var problems []string
+	eachField(reflect.ValueOf(cfg).Elem(), func(section, key string, f reflect.Value) {
+		name := envName(section, key)
+		raw, ok := vars[name]
+		if !ok {
+			return
+		}
+		if err := setField(f, raw); err != nil {
+			problems = append(problems, fmt.Sprintf("%s: %v", name, err))
+		}
+	})
+	return problems
+}
+
+// redacted replaces secret values in Lookup and Diff output
+const redacted = "********"
+
+// Lookup returns the value of a scalar key such as "smtp.from_name" formatted
+// as it would be written in TOML (durations as "30m0s"). Passwords and secrets
+// are redacted.
+func (cfg *Config) Lookup(key string) (string, bool) {
+	v, ok := cfg.lookup(key)
+	if ok && v != "" && secretKey(key) {
+		v = redacted
+	}
+	return v, ok
+}
+
+func (cfg *Config) lookup(key string) (string, bool) {
+	var (
+		out   string
+		found bool
+	)
+	eachField(reflect.ValueOf(cfg).Elem(), func(section, k string, f reflect.Value) {
+		if found || section+"."+k != key {
+			return
+		}
+		found = true
+		if f.Type() == durationType {
+			out = time.Duration(f.Int()).String()
+		} else {
+			out = fmt.Sprint(f.Interface())
+		}
+	})
+	return out, found
+}
+
+func envName(section, key string) string {
+	return strings.ToUpper(EnvPrefix + "_" + section + "_" + key)
+}
+
+// eachField visits the scalar fields of every config section
+func eachField(cfg reflect.Value, fn func(section, key string, f reflect.Value)) {
+	t := cfg.Type()
+	for i := 0; i < t.NumField(); i++ {
+		section := tomlName(t.Field(i))
+		if section == "" || t.Field(i).Type.Kind() != reflect.Struct {
+			continue
+		}
+		sv := cfg.Field(i)
+		st := sv.Type()
+		for j := 0; j < st.NumField(); j++ {
+			key := tomlName(st.Field(j))
+			if key == "" {
+				continue
+			}
+			switch st.Field(j).Type.Kind() {
+			case reflect.Slice, reflect.Map, reflect.Struct:
+				continue // tables such as [[db.replicas]] are file-only
+			}
+			fn(section, key, sv.Field(j))
+		}
+	}
+}
+
+func tomlName(f reflect.StructField) string {
+	name, _, _ := strings.Cut(f.Tag.Get("toml"), ",")
+	if name == "-" {
+		return ""
+	}
+	return name
+}
+
+func setField(f reflect.Value, raw string) error {
+	raw = strings.TrimSpace(raw)
+	if f.Type() == durationType {
+		d, err := time.ParseDuration(raw)
+		if err != nil {
+			return fmt.Errorf("invalid duration %q", raw)
+		}
+		f.SetInt(int64(d))
+		return nil
+	}
+	switch f.Kind() {
+	case reflect.String:
+		f.SetString(raw)
+	case reflect.Bool:
+		b, err := strconv.ParseBool(raw)
+		if err != nil {
+			return fmt.Errorf("invalid boolean %q", raw)
+		}
+		f.SetBool(b)
+	case reflect.Int, reflect.Int64:
+		n, err := strconv.ParseInt(raw, 10, 64)
+		if err != nil {
+			return fmt.Errorf("invalid integer %q", raw)
+		}
+		f.SetInt(n)
+	default:
+		return fmt.Errorf("unsupported type %s", f.Type())
+	}
+	return nil
+}
+
+// Change is one key that differs between two configs
+type Change struct {
+	Key string
+	Old string
+	New string
+}
+
+func (c Change) String() string {
+	if secretKey(c.Key) {
+		return c.Key + " (changed)"
+	}
+	return fmt.Sprintf("%s %q -> %q", c.Key, c.Old, c.New)
+}
+
+func secretKey(key string) bool {
+	for _, s := range []string{"password", "pass", "secret"} {
+		if strings.HasSuffix(key, s) {
+			return true
+		}
+	}
+	return false
+}
+
+// Diff lists the scalar keys whose value differs between old and new; secret
+// values are compared but not returned
+func Diff(old, new *Config) []Change {
+	var changes []Change
+	eachField(reflect.ValueOf(new).Elem(), func(section, key string, _ reflect.Value) {
+		k := section + "." + key
+		a, _ := old.lookup(k)
+		b, _ := new.lookup(k)
+		if a != b {
+			ch := Change{Key: k, Old: a, New: b}
+			if secretKey(k) {
+				ch.Old, ch.New = redacted, redacted
+			}
+			changes = append(changes, ch)
+		}
+	})
+	if !reflect.DeepEqual(old.DB.Replicas, new.DB.Replicas) {
+		changes = append(changes, Change{Key: "db.replicas", Old: fmt.Sprint(len(old.DB.Replicas)), New: fmt.Sprint(len(new.DB.Replicas))})
+	}
+	return changes
+}
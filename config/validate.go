@@ -0,0 +1,283 @@
+package config
+
+import (
+	"fmt"
+	"net"
+	"net/mail"
+	"net/url"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"backend-meta-data/auth/password"
+)
+
+// Environments are the accepted values of app.env
+var Environments = []string{"local", "dev", "development", "test", "uat", "prod", "production"}
+
+// minProdSecretLen is the shortest auth.jwt_secret accepted outside local/dev/test
+const minProdSecretLen = 32
+
+// Lowest accepted password hashing costs
+const (
+	minBcryptCost   = 10
+	minArgon2Memory = 19 * 1024 // KiB
+)
+
+func validEnv(env string) bool {
+	for _, e := range Environments {
+		if env == e {
+			return true
+		}
+	}
+	return false
+}
+
+// IsDevelopment reports whether app.env is a local development environment
+func (a AppConfig) IsDevelopment() bool {
+	switch a.Env {
+	case "local", "dev", "development":
+		return true
+	}
+	return false
+}
+
+// validate returns one message per missing or invalid key
+func (cfg *Config) validate() []string {
+	var problems []string
+	add := func(key, format string, args ...any) {
+		problems = append(problems, key+": "+fmt.Sprintf(format, args...))
+	}
+
+	if !validEnv(cfg.App.Env) {
+		add("app.env", "%q is not one of %s", cfg.App.Env, strings.Join(Environments, ", "))
+	}
+	if cfg.App.Port < 1 || cfg.App.Port > 65535 {
+		add("app.port", "%d is not a valid port", cfg.App.Port)
+	}
+	switch strings.ToLower(cfg.App.LogLevel) {
+	case "trace", "debug", "info", "warn", "warning", "error":
+	default:
+		add("app.log_level", "%q is not one of trace, debug, info, warn, error", cfg.App.LogLevel)
+	}
+	if cfg.App.WatchInterval < 0 {
+		add("app.watch_interval", "must not be negative")
+	}
+	if cfg.App.PublicURL != "" {
+		if u, err := url.Parse(cfg.App.PublicURL); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			add("app.public_url", "%q is not a URL such as https://meta.example.com", cfg.App.PublicURL)
+		}
+	}
+	for _, p := range splitList(cfg.App.TrustedProxies) {
+		if _, _, err := net.ParseCIDR(p); err != nil && net.ParseIP(p) == nil {
+			add("app.trusted_proxies", "%q is not an IP address or CIDR", p)
+		}
+	}
+	if cfg.App.ProxyHeader != "" && cfg.App.TrustedProxies == "" {
+		add("app.proxy_header", "requires app.trusted_proxies, or any client could set its own address")
+	}
+
+	switch strings.ToLower(cfg.DB.Type) {
+	case "", "mysql", "mariadb", "postgres", "postgresql", "pgsql", "sqlserver", "mssql":
+		if cfg.DB.Host == "" {
+			add("db.host", "required")
+		}
+	case "sqlite", "sqlite3":
+	default:
+		add("db.type", "%q is not one of mysql, postgres, sqlserver, sqlite", cfg.DB.Type)
+	}
+	if cfg.DB.Name == "" {
+		add("db.name", "required")
+	}
+	if cfg.DB.Port < 0 || cfg.DB.Port > 65535 {
+		add("db.port", "%d is not a valid port", cfg.DB.Port)
+	}
+	for i, r := range cfg.DB.Replicas {
+		if r.Host == "" && r.Name == "" {
+			add(fmt.Sprintf("db.replicas[%d]", i), "needs at least a host or name")
+		}
+	}
+
+	switch {
+	case cfg.Auth.JWTSecret == "":
+		add("auth.jwt_secret", "required")
+	case !cfg.App.IsDevelopment() && cfg.App.Env != "test" && len(cfg.Auth.JWTSecret) < minProdSecretLen:
+		add("auth.jwt_secret", "must be at least %d characters in %s", minProdSecretLen, cfg.App.Env)
+	}
+	if cfg.Auth.TokenTTL < 0 {
+		add("auth.token_ttl", "must be positive")
+	}
+	if cfg.Auth.RefreshTTL < 0 {
+		add("auth.refresh_ttl", "must be positive")
+	} else if cfg.Auth.RefreshTTL > 0 && cfg.Auth.RefreshTTL < cfg.Auth.TokenTTL {
+		add("auth.refresh_ttl", "must not be shorter than token_ttl")
+	}
+	if cfg.Auth.APIKeyTTL < time.Hour {
+		add("auth.api_key_ttl", "must be at least 1h")
+	}
+	if cfg.Auth.PasswordResetTTL < 5*time.Minute || cfg.Auth.PasswordResetTTL > 24*time.Hour {
+		add("auth.password_reset_ttl", "must be between 5m and 24h")
+	}
+	if cfg.Auth.EmailVerifyTTL < time.Hour {
+		add("auth.email_verify_ttl", "must be at least 1h")
+	}
+	if cfg.Auth.ThrottleFailures < 1 || cfg.Auth.ThrottleIPFailures < 1 || cfg.Auth.LockoutFailures < 1 {
+		add("auth.throttle_failures", "throttle_failures, throttle_ip_failures and lockout_failures must be positive")
+	}
+	if cfg.Auth.ThrottleBackoff <= 0 || cfg.Auth.LockoutDuration < cfg.Auth.ThrottleBackoff {
+		add("auth.lockout_duration", "throttle_backoff must be positive and lockout_duration not shorter than it")
+	}
+	for _, role := range cfg.Auth.MFARoles() {
+		if role != "Root" && role != "Admin" && role != "Inspector" {
+			add("auth.mfa_required_roles", "%q is not one of Root, Admin, Inspector", role)
+		}
+	}
+	for _, name := range cfg.Auth.ProviderNames() {
+		switch name {
+		case "local":
+		case "ldap":
+			if cfg.LDAP.URL == "" {
+				add("auth.providers", "ldap needs ldap.url")
+			}
+		default:
+			add("auth.providers", "%q is not one of local, ldap", name)
+		}
+	}
+	for _, p := range splitList(cfg.Auth.SSOTrustedProxies) {
+		if _, _, err := net.ParseCIDR(p); err != nil && net.ParseIP(p) == nil {
+			add("auth.sso_trusted_proxies", "%q is not an IP address or CIDR", p)
+		}
+	}
+	switch cfg.Auth.PasswordHash {
+	case password.Argon2id:
+		if cfg.Auth.Argon2Iterations < 1 {
+			add("auth.argon2_iterations", "must be at least 1")
+		}
+		if cfg.Auth.Argon2Parallelism < 1 || cfg.Auth.Argon2Parallelism > 255 {
+			add("auth.argon2_parallelism", "%d is outside 1-255", cfg.Auth.Argon2Parallelism)
+		}
+		if cfg.Auth.Argon2Memory < minArgon2Memory {
+			add("auth.argon2_memory", "must be at least %d KiB", minArgon2Memory)
+		}
+	case password.Bcrypt:
+		if cfg.Auth.BcryptCost < minBcryptCost || cfg.Auth.BcryptCost > 31 {
+			add("auth.bcrypt_cost", "%d is outside %d-31", cfg.Auth.BcryptCost, minBcryptCost)
+		}
+	default:
+		add("auth.password_hash", "%q is not one of argon2id, bcrypt", cfg.Auth.PasswordHash)
+	}
+
+	if cfg.LDAP.URL != "" {
+		if u, err := url.Parse(cfg.LDAP.URL); err != nil || (u.Scheme != "ldap" && u.Scheme != "ldaps") || u.Host == "" {
+			add("ldap.url", "%q must look like ldap://host:389 or ldaps://host:636", cfg.LDAP.URL)
+		}
+		if cfg.LDAP.BaseDN == "" {
+			add("ldap.base_dn", "required when ldap.url is set")
+		}
+		if cfg.LDAP.ServiceUser == "" || cfg.LDAP.ServicePass == "" {
+			add("ldap.service_user", "service_user and service_pass are required to search for users")
+		}
+		if !strings.Contains(cfg.LDAP.UserFilter, "{username}") {
+			add("ldap.user_filter", "must contain {username}")
+		}
+		if cfg.LDAP.StartTLS && strings.HasPrefix(cfg.LDAP.URL, "ldaps:") {
+			add("ldap.start_tls", "not needed with ldaps://")
+		}
+		if cfg.LDAP.CACert != "" {
+			if _, err := os.Stat(cfg.LDAP.CACert); err != nil {
+				add("ldap.ca_cert", "%v", err)
+			}
+		}
+		if cfg.LDAP.InsecureSkipVerify && !cfg.App.IsDevelopment() {
+			add("ldap.insecure_skip_verify", "only allowed in local/dev")
+		}
+		if cfg.LDAP.PoolSize < 0 {
+			add("ldap.pool_size", "must not be negative")
+		}
+		if cfg.LDAP.DefaultRole != "Admin" && cfg.LDAP.DefaultRole != "Inspector" {
+			add("ldap.default_role", "%q is not one of Admin, Inspector", cfg.LDAP.DefaultRole)
+		}
+		groups := make([]string, 0, len(cfg.LDAP.GroupRoles))
+		for group := range cfg.LDAP.GroupRoles {
+			groups = append(groups, group)
+		}
+		sort.Strings(groups)
+		for _, group := range groups {
+			if role := cfg.LDAP.GroupRoles[group]; role != "Root" && role != "Admin" && role != "Inspector" {
+				add("ldap.group_roles", "%q maps to %q, which is not one of Root, Admin, Inspector", group, role)
+			}
+		}
+	}
+
+	switch cfg.Session.CookieSameSite {
+	case "Strict", "Lax":
+	case "None":
+		if !cfg.Session.CookieSecure {
+			add("session.cookie_samesite", "None needs cookie_secure")
+		}
+	default:
+		add("session.cookie_samesite", "%q is not one of Strict, Lax, None", cfg.Session.CookieSameSite)
+	}
+	if cfg.Session.IdleTimeout < time.Minute {
+		add("session.idle_timeout", "must be at least 1m")
+	}
+	if cfg.Session.AbsoluteTimeout < cfg.Session.IdleTimeout {
+		add("session.absolute_timeout", "must not be shorter than idle_timeout")
+	}
+
+	if cfg.OIDC.Issuer != "" {
+		u, err := url.Parse(cfg.OIDC.Issuer)
+		switch {
+		case err != nil || u.Host == "":
+			add("oidc.issuer", "%q is not a URL", cfg.OIDC.Issuer)
+		case u.Scheme != "https" && !(u.Scheme == "http" && isLoopback(u.Hostname())):
+			add("oidc.issuer", "must use https (http is only allowed for localhost)")
+		}
+		if cfg.OIDC.Audience == "" {
+			add("oidc.audience", "required when oidc.issuer is set")
+		}
+		if cfg.OIDC.DefaultRole != "Admin" && cfg.OIDC.DefaultRole != "Inspector" {
+			add("oidc.default_role", "%q is not one of Admin, Inspector", cfg.OIDC.DefaultRole)
+		}
+		if cfg.OIDC.JWKSCacheTTL < time.Minute {
+			add("oidc.jwks_cache_ttl", "must be at least 1m")
+		}
+	}
+
+	if cfg.SMTP.Host != "" {
+		if cfg.SMTP.Port < 1 || cfg.SMTP.Port > 65535 {
+			add("smtp.port", "%d is not a valid port", cfg.SMTP.Port)
+		}
+		if cfg.App.PublicURL == "" {
+			add("app.public_url", "required when smtp.host is set, for the links in account emails")
+		}
+		if cfg.SMTP.FromEmail == "" {
+			add("smtp.from_email", "required when smtp.host is set")
+		} else if _, err := mail.ParseAddress(cfg.SMTP.FromEmail); err != nil {
+			add("smtp.from_email", "%q is not an email address", cfg.SMTP.FromEmail)
+		}
+	}
+
+	for _, origin := range strings.Split(cfg.CORS.AllowOrigins, ",") {
+		origin = strings.TrimSpace(origin)
+		if origin == "*" {
+			if cfg.CORS.AllowCredentials {
+				add("cors.allow_origins", `"*" cannot be combined with allow_credentials`)
+			}
+			continue
+		}
+		if u, err := url.Parse(origin); err != nil || u.Scheme == "" || u.Host == "" {
+			add("cors.allow_origins", "%q is not an origin such as https://app.example.com", origin)
+		}
+	}
+	return problems
+}
+
+func isLoopback(host string) bool {
+	if host == "localhost" {
+		return true
+	}
+	ip := net.ParseIP(host)
+	return ip != nil && ip.IsLoopback()
+}
@@ -0,0 +1,199 @@
+package controllers
+
+import (
+	"errors"
+	"fmt"
+	"html"
+	"math"
+	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"backend-meta-data/auth"
+	"backend-meta-data/config"
+	"backend-meta-data/models"
+
+	"github.com/gofiber/fiber/v2"
+	log "github.com/sirupsen/logrus"
+	"gorm.io/gorm"
+)
+
+var (
+	// tmplHeaderRe matches the "Template Name:" comment, which is not sent
+	tmplHeaderRe = regexp.MustCompile(`(?s)^\s*<!--.*?-->\s*`)
+	tmplTitleRe  = regexp.MustCompile(`(?is)<title>(.*?)</title>`)
+)
+
+// accountMailConfigured reports whether account emails can be sent. Links are
+// only ever built from app.public_url: the request's Host header is chosen by
+// the client and would let anyone point a reset link at their own site.
+func accountMailConfigured(cfg *config.Config) bool {
+	return cfg.SMTP.Host != "" && cfg.App.PublicURL != ""
+}
+
+// accountLink is the client page a mailed token opens, under app.public_url
+func accountLink(cfg *config.Config, page, token string) string {
+	return strings.TrimRight(cfg.App.PublicURL, "/") + "/" + page + "?token=" + url.QueryEscape(token)
+}
+
+// renderAccountMail fills email_templates/account/<name>.html and returns the
+// subject (its <title>) and body
+func renderAccountMail(cfg *config.Config, name, link string, m *auth.AccountMail) (string, string, error) {
+	b, err := os.ReadFile(filepath.Join(resolveTemplatesDir("account"), name+".html"))
+	if err != nil {
+		return "", "", err
+	}
+	site := cfg.App.WebsiteName
+	if site == "" {
+		site = "FiberNova"
+	}
+	displayName := m.User.DisplayName
+	if displayName == "" {
+		displayName = m.User.Username
+	}
+	r := strings.NewReplacer(
+		"{{website_name}}", html.EscapeString(site),
+		"{{username}}", html.EscapeString(m.User.Username),
+		"{{display_name}}", html.EscapeString(displayName),
+		"{{email}}", html.EscapeString(m.To),
+		"{{link}}", html.EscapeString(link),
+		"{{expires_at}}", m.ExpiresAt.Format("2006-01-02 15:04 MST"),
+	)
+	body := r.Replace(tmplHeaderRe.ReplaceAllString(string(b), ""))
+	subject := site
+	if t := tmplTitleRe.FindStringSubmatch(body); len(t) > 1 {
+		subject = html.UnescapeString(strings.TrimSpace(t[1]))
+	}
+	return subject, body, nil
+}
+
+// sendAccountMail renders template name with link and sends it to m.To
+func sendAccountMail(cfg *config.Config, name, link string, m *auth.AccountMail) error {
+	subject, body, err := renderAccountMail(cfg, name, link, m)
+	if err != nil {
+		return fmt.Errorf("rendering %s: %w", name, err)
+	}
+	return sendSMTP(&cfg.SMTP, m.To, subject, body)
+}
+
+// accountError maps auth errors of the reset and verification routes to a response
+func accountError(c *fiber.Ctx, err error) error {
+	switch {
+	case errors.Is(err, auth.ErrInvalidAccountToken):
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid or expired link"})
+	case errors.Is(err, auth.ErrPasswordRequired):
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
+	case errors.Is(err, models.ErrAccountDisabled):
+		return c.Status(fiber.StatusForbidden).JSON(fiber.Map{"error": "Account is disabled"})
+	}
+	return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Request failed"})
+}
+
+// ForgotPassword handles POST /auth/password/forgot with {"login"}, a username
+// or email address. It always answers 200 so accounts cannot be probed; the
+// email is sent in the background.
+func ForgotPassword(cfg *config.Config) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		if auth.Default == nil {
+			return c.Status(fiber.StatusServiceUnavailable).JSON(fiber.Map{"error": "Authentication is not configured"})
+		}
+		if !accountMailConfigured(cfg) {
+			return c.Status(fiber.StatusServiceUnavailable).JSON(fiber.Map{"error": "Email is not configured"})
+		}
+		var req struct {
+			Login string `json:"login"`
+		}
+		if err := c.BodyParser(&req); err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid request body"})
+		}
+		m, err := auth.Default.RequestPasswordReset(c, req.Login)
+		if err != nil {
+			log.Errorf("password reset for %q: %v", req.Login, err)
+		}
+		if m != nil {
+			link := accountLink(cfg, "reset-password", m.Token)
+			go func() {
+				if err := sendAccountMail(cfg, "password_reset", link, m); err != nil {
+					log.Errorf("sending password reset to %s: %v", m.User.Username, err)
+				}
+			}()
+		}
+		return c.JSON(fiber.Map{"message": "If the account exists and has an email address, a reset link has been sent"})
+	}
+}
+
+// ResetPassword handles POST /auth/password/reset with {"token", "password"}
+func ResetPassword() fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		if auth.Default == nil {
+			return c.Status(fiber.StatusServiceUnavailable).JSON(fiber.Map{"error": "Authentication is not configured"})
+		}
+		var req struct {
+			Token    string `json:"token"`
+			Password string `json:"password"`
+		}
+		if err := c.BodyParser(&req); err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid request body"})
+		}
+		if _, err := auth.Default.ResetPassword(c, req.Token, req.Password); err != nil {
+			return accountError(c, err)
+		}
+		return c.JSON(fiber.Map{"message": "Password changed; log in with the new password"})
+	}
+}
+
+// VerifyEmail handles POST /auth/email/verify with {"token"}
+func VerifyEmail() fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		if auth.Default == nil {
+			return c.Status(fiber.StatusServiceUnavailable).JSON(fiber.Map{"error": "Authentication is not configured"})
+		}
+		var req struct {
+			Token string `json:"token"`
+		}
+		if err := c.BodyParser(&req); err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid request body"})
+		}
+		user, err := auth.Default.VerifyEmail(c, req.Token)
+		if err != nil {
+			return accountError(c, err)
+		}
+		return c.JSON(fiber.Map{"message": "Email address verified", "email": user.Email, "email_verified_at": user.EmailVerifiedAt})
+	}
+}
+
+// RequestEmailVerification handles POST /me/email/verification: mails the
+// caller a link confirming their email address
+func RequestEmailVerification(db *gorm.DB, cfg *config.Config) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		user, err := models.GetLoggedInUser(c, db)
+		if err != nil || auth.Default == nil {
+			return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"error": "User not logged in"})
+		}
+		if !accountMailConfigured(cfg) {
+			return c.Status(fiber.StatusServiceUnavailable).JSON(fiber.Map{"error": "Email is not configured"})
+		}
+		m, err := auth.Default.RequestEmailVerification(c, user)
+		var throttled *auth.ThrottledError
+		switch {
+		case errors.As(err, &throttled):
+			secs := int(math.Ceil(throttled.RetryAfter.Seconds()))
+			c.Set(fiber.HeaderRetryAfter, strconv.Itoa(secs))
+			return c.Status(fiber.StatusTooManyRequests).JSON(fiber.Map{"error": "A verification email was sent recently", "retry_after": secs})
+		case errors.Is(err, auth.ErrNoEmail):
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
+		case errors.Is(err, auth.ErrEmailVerified):
+			return c.Status(fiber.StatusConflict).JSON(fiber.Map{"error": err.Error()})
+		case err != nil:
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to create verification link"})
+		}
+		if err := sendAccountMail(cfg, "verify_email", accountLink(cfg, "verify-email", m.Token), m); err != nil {
+			log.Errorf("sending email verification to %s: %v", user.Username, err)
+			return c.Status(fiber.StatusBadGateway).JSON(fiber.Map{"error": "Failed to send verification email"})
+		}
+		return c.JSON(fiber.Map{"message": "Verification email sent to " + m.To, "expires_at": m.ExpiresAt})
+	}
+}
@@ -0,0 +1,136 @@
+package controllers
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"backend-meta-data/auth"
+	"backend-meta-data/models"
+
+	"github.com/gofiber/fiber/v2"
+	"gorm.io/gorm"
+)
+
+// errKeyManagesKeys refuses key management requests authenticated by an API key
+var errKeyManagesKeys = errors.New("API keys cannot manage API keys")
+
+// errOutranked refuses managing the keys of a user whose role is not below the caller's
+var errOutranked = errors.New("can only manage API keys of users whose role is below yours")
+
+// apiKeyOwner returns the user whose keys a request manages and the caller:
+// :id on the admin /api/users/:id/api-keys routes, otherwise the caller
+// (/me/api-keys)
+func apiKeyOwner(c *fiber.Ctx, db *gorm.DB) (owner, caller *models.User, err error) {
+	if c.Locals("apiKey") != nil {
+		return nil, nil, errKeyManagesKeys
+	}
+	caller, err = models.GetLoggedInUser(c, db)
+	if err != nil {
+		return nil, nil, err
+	}
+	if c.Params("id") == "" {
+		return caller, caller, nil
+	}
+	id, err := c.ParamsInt("id")
+	if err != nil || id <= 0 {
+		return nil, nil, fiber.ErrBadRequest
+	}
+	owner, err = models.FindUserByID(db, uint(id))
+	if err != nil {
+		return nil, nil, err
+	}
+	if caller.ID != owner.ID && !caller.Outranks(owner) {
+		return nil, nil, errOutranked
+	}
+	return owner, caller, nil
+}
+
+// apiKeyOwnerError maps apiKeyOwner errors to a response
+func apiKeyOwnerError(c *fiber.Ctx, err error) error {
+	switch {
+	case errors.Is(err, errKeyManagesKeys), errors.Is(err, errOutranked):
+		return c.Status(fiber.StatusForbidden).JSON(fiber.Map{"error": err.Error()})
+	case errors.Is(err, fiber.ErrBadRequest):
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "invalid user id"})
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "user not found"})
+	}
+	return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"error": "unauthorized"})
+}
+
+// ListAPIKeys handles GET /me/api-keys and GET /api/users/:id/api-keys
+func ListAPIKeys(db *gorm.DB) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		user, _, err := apiKeyOwner(c, db)
+		if err != nil {
+			return apiKeyOwnerError(c, err)
+		}
+		keys, err := models.ListAPIKeys(db, user.ID)
+		if err != nil {
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "failed to list API keys"})
+		}
+		return c.JSON(fiber.Map{"data": keys})
+	}
+}
+
+// CreateAPIKey handles POST /me/api-keys and POST /api/users/:id/api-keys.
+// The key is in the response only; afterwards just its prefix is shown.
+func CreateAPIKey(db *gorm.DB) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		user, caller, err := apiKeyOwner(c, db)
+		if err != nil {
+			return apiKeyOwnerError(c, err)
+		}
+		var req struct {
+			Name          string   `json:"name"`
+			Scopes        []string `json:"scopes"`
+			ExpiresInDays int      `json:"expires_in_days"` // 0 or more than [auth] api_key_ttl uses api_key_ttl
+		}
+		if err := c.BodyParser(&req); err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid request body"})
+		}
+		if req.Name == "" || len(req.Name) > 100 {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "name is required (at most 100 characters)"})
+		}
+		if req.ExpiresInDays < 0 {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "expires_in_days must not be negative"})
+		}
+		if auth.Default == nil {
+			return c.Status(fiber.StatusServiceUnavailable).JSON(fiber.Map{"error": "Authentication is not configured"})
+		}
+		ttl := time.Duration(req.ExpiresInDays) * 24 * time.Hour
+		raw, key, err := auth.Default.CreateAPIKey(user, req.Name, req.Scopes, ttl)
+		if errors.Is(err, auth.ErrInvalidScope) {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
+		}
+		if err != nil {
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "failed to create API key"})
+		}
+		logActivity(c, db, user, "api_key_created", fmt.Sprintf("%q (%s) by %s", key.Name, key.Prefix, caller.Username))
+		return c.Status(fiber.StatusCreated).JSON(fiber.Map{"key": raw, "api_key": key})
+	}
+}
+
+// RevokeAPIKey handles DELETE /me/api-keys/:keyId and DELETE /api/users/:id/api-keys/:keyId
+func RevokeAPIKey(db *gorm.DB) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		user, caller, err := apiKeyOwner(c, db)
+		if err != nil {
+			return apiKeyOwnerError(c, err)
+		}
+		keyID, err := c.ParamsInt("keyId")
+		if err != nil || keyID <= 0 {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "invalid key id"})
+		}
+		err = models.RevokeAPIKey(db, user.ID, uint(keyID), time.Now())
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "API key not found"})
+		}
+		if err != nil {
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "failed to revoke API key"})
+		}
+		logActivity(c, db, user, "api_key_revoked", fmt.Sprintf("key %d by %s", keyID, caller.Username))
+		return c.JSON(fiber.Map{"message": "API key revoked"})
+	}
+}
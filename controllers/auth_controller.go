@@ -3,146 +3,128 @@ package controllers
 import (
 	"backend-meta-data/auth"
 	"backend-meta-data/models"
-	"net/http"
-	"os"
-	"strings"
-	"time"
+	"errors"
+	"math"
+	"strconv"
 
-	"github.com/go-ldap/ldap/v3"
 	"github.com/gofiber/fiber/v2"
-	"github.com/golang-jwt/jwt/v5"
-	"gorm.io/gorm"
 )
 
-// AuthController handles user authentication
-func AuthController(db *gorm.DB) fiber.Handler {
+// Login handles the password and SSO login routes. provider names the auth
+// provider to use; empty tries each password provider in auth.providers order.
+func Login(provider string) fiber.Handler {
 	return func(c *fiber.Ctx) error {
-		var req struct {
-			Username string `json:"username"`
-			Password string `json:"password"`
+		if auth.Default == nil {
+			return c.Status(fiber.StatusServiceUnavailable).JSON(fiber.Map{"error": "Authentication is not configured"})
 		}
-		if err := c.BodyParser(&req); err != nil {
-			return c.Status(400).JSON(fiber.Map{"error": "Invalid request body"})
+		var creds auth.Credentials
+		if len(c.Body()) > 0 {
+			if err := c.BodyParser(&creds); err != nil {
+				return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid request body"})
+			}
 		}
-
-		var user models.User
-		if err := db.Where("username = ?", req.Username).First(&user).Error; err != nil {
-			return c.Status(401).JSON(fiber.Map{"error": "Invalid username or password"})
+		res, err := auth.Default.Login(c, provider, creds)
+		var throttled *auth.ThrottledError
+		switch {
+		case errors.As(err, &throttled):
+			return tooManyAttempts(c, throttled)
+		case errors.Is(err, models.ErrAccountLocked):
+			return c.Status(fiber.StatusLocked).JSON(fiber.Map{"error": "Account is temporarily locked after too many failed logins"})
+		case errors.Is(err, models.ErrInvalidCredentials):
+			return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"error": "Invalid username or password"})
+		case errors.Is(err, models.ErrAccountDisabled):
+			return c.Status(fiber.StatusForbidden).JSON(fiber.Map{"error": "Account is disabled"})
+		case errors.Is(err, auth.ErrUnknownProvider):
+			return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "Login method is not enabled"})
+		case err != nil:
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Login failed"})
 		}
-
-		if user.Password != req.Password {
-			return c.Status(401).JSON(fiber.Map{"error": "Invalid username or password"})
+		if res.MFAToken != "" {
+			return c.Status(fiber.StatusAccepted).JSON(mfaChallengeResponse(res))
 		}
+		return c.JSON(tokenResponse("Login successful", res))
+	}
+}
+
+// tooManyAttempts is the 429 for a username or IP that is backing off
+func tooManyAttempts(c *fiber.Ctx, e *auth.ThrottledError) error {
+	secs := int(math.Ceil(e.RetryAfter.Seconds()))
+	c.Set(fiber.HeaderRetryAfter, strconv.Itoa(secs))
+	return c.Status(fiber.StatusTooManyRequests).JSON(fiber.Map{"error": "Too many failed attempts", "retry_after": secs})
+}
 
-		// TODO: Set session or return JWT token
-		return c.JSON(fiber.Map{"message": "Login successful", "user": user})
+// tokenResponse is the body of every login and refresh
+func tokenResponse(message string, res *auth.Result) fiber.Map {
+	return fiber.Map{
+		"message":            message,
+		"token":              res.Token,
+		"expires_at":         res.Claims.ExpiresAt.Time,
+		"refresh_token":      res.Refresh,
+		"refresh_expires_at": res.RefreshExpiresAt,
+		"provider":           res.Provider,
+		"user":               res.User,
 	}
 }
 
-// OIDCAuthMiddleware validates JWT from Azure AD or ADFS
-func OIDCAuthMiddleware() fiber.Handler {
+type refreshRequest struct {
+	RefreshToken string `json:"refresh_token"`
+}
+
+// RefreshToken handles POST /auth/refresh: the refresh token is rotated and a
+// new access token issued
+func RefreshToken() fiber.Handler {
 	return func(c *fiber.Ctx) error {
-		authHeader := c.Get("Authorization")
-		if authHeader == "" || !strings.HasPrefix(authHeader, "Bearer ") {
-			return c.Status(http.StatusUnauthorized).JSON(fiber.Map{"error": "Missing or invalid Authorization header"})
+		if auth.Default == nil {
+			return c.Status(fiber.StatusServiceUnavailable).JSON(fiber.Map{"error": "Authentication is not configured"})
 		}
-		tokenString := strings.TrimPrefix(authHeader, "Bearer ")
-		// TODO: Replace with your Azure AD/ADFS public key or JWKS validation
-		token, err := jwt.Parse(tokenString, func(token *jwt.Token) (interface{}, error) {
-			// Validate signing method and return public key
-			// For demo, accept any method and no key (DO NOT use in production)
-			return []byte("your_secret_key"), nil
-		})
-		if err != nil || !token.Valid {
-			return c.Status(http.StatusUnauthorized).JSON(fiber.Map{"error": "Invalid token"})
+		var req refreshRequest
+		if err := c.BodyParser(&req); err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid request body"})
 		}
-		claims, ok := token.Claims.(jwt.MapClaims)
-		if !ok {
-			return c.Status(http.StatusUnauthorized).JSON(fiber.Map{"error": "Invalid token claims"})
+		res, err := auth.Default.Refresh(c, req.RefreshToken)
+		switch {
+		case errors.Is(err, auth.ErrInvalidRefreshToken), errors.Is(err, auth.ErrRefreshTokenReused):
+			return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"error": err.Error()})
+		case errors.Is(err, models.ErrAccountDisabled):
+			return c.Status(fiber.StatusForbidden).JSON(fiber.Map{"error": "Account is disabled"})
+		case err != nil:
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Refresh failed"})
 		}
-		// Extract user info from claims (e.g., email, name, AD account)
-		c.Locals("user", claims)
-		return c.Next()
+		return c.JSON(tokenResponse("Token refreshed", res))
 	}
 }
 
-// SSOController handles Windows AD SSO via LDAP
-func SSOController(db *gorm.DB) fiber.Handler {
+// Logout ends the login session and revokes the Bearer token and the
+// refresh_token in the body, if any
+func Logout() fiber.Handler {
 	return func(c *fiber.Ctx) error {
-		// Try to get username from request header (e.g., REMOTE_USER, for IIS/AD integration)
-		adUsername := c.Get("X-AD-Username")
-		if adUsername == "" {
-			return c.Status(401).JSON(fiber.Map{"error": "No AD username detected"})
+		if auth.Default == nil {
+			return c.JSON(fiber.Map{"message": "Logout successful"})
 		}
-
-		// Use a service account to bind to LDAP and check if user exists
-		ldapURL := os.Getenv("LDAP_URL")
-		baseDN := os.Getenv("LDAP_BASE_DN")
-		serviceUser := os.Getenv("LDAP_SERVICE_USER")
-		servicePass := os.Getenv("LDAP_SERVICE_PASS")
-
-		ok, err := auth.AuthenticateAD(ldapURL, baseDN, serviceUser, servicePass)
-		if err != nil || !ok {
-			return c.Status(500).JSON(fiber.Map{"error": "LDAP service bind failed"})
+		var req refreshRequest
+		if len(c.Body()) > 0 {
+			if err := c.BodyParser(&req); err != nil {
+				return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid request body"})
+			}
 		}
-
-		// Optionally, check if user exists in AD (not authenticating password here)
-		// For demo, assume user exists if header is present
-
-		// Find user in local DB
-		var user models.User
-		if err := db.Where("username = ?", adUsername).First(&user).Error; err != nil {
-			return c.Status(401).JSON(fiber.Map{"error": "User not found in system"})
+		if err := auth.Default.Logout(c, req.RefreshToken); err != nil {
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Logout failed"})
 		}
-
-		// Generate JWT token
-		token := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{
-			"username": user.Username,
-			"id":       user.ID,
-		})
-		tokenString, err := token.SignedString([]byte(os.Getenv("JWT_SECRET")))
-		if err != nil {
-			return c.Status(500).JSON(fiber.Map{"error": "Token generation failed"})
-		}
-
-		return c.JSON(fiber.Map{"token": tokenString, "user": user})
+		return c.JSON(fiber.Map{"message": "Logout successful"})
 	}
 }
 
-// ADLoginHandler handles Active Directory login
-func ADLoginHandler(c *fiber.Ctx) error {
-	type LoginRequest struct {
-		Username string `json:"username"`
-		Password string `json:"password"`
-	}
-	var req LoginRequest
-	if err := c.BodyParser(&req); err != nil {
-		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid request"})
-	}
-
-	ldapURL := os.Getenv("LDAP_URL")
-	baseDN := os.Getenv("LDAP_BASE_DN")
-
-	l, err := ldap.DialURL(ldapURL)
-	if err != nil {
-		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "LDAP connection failed"})
-	}
-	defer l.Close()
-
-	userDN := "CN=" + req.Username + "," + baseDN
-	if err := l.Bind(userDN, req.Password); err != nil {
-		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"error": "AD authentication failed"})
-	}
-
-	jwtSecret := os.Getenv("JWT_SECRET")
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{
-		"username": req.Username,
-		"exp":      time.Now().Add(time.Hour * 24).Unix(),
-	})
-	tokenString, err := token.SignedString([]byte(jwtSecret))
-	if err != nil {
-		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "JWT generation failed"})
+// LogoutAll handles POST /auth/logout-all: ends every session, refresh token
+// and access token of the current user, on every device
+func LogoutAll() fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		user, ok := c.Locals("user").(*models.User)
+		if !ok || auth.Default == nil {
+			return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"error": "Not logged in"})
+		}
+		if err := auth.Default.LogoutAll(c, user); err != nil {
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Logout failed"})
+		}
+		return c.JSON(fiber.Map{"message": "Logged out of all sessions"})
 	}
-
-	return c.JSON(fiber.Map{"token": tokenString})
 }
@@ -0,0 +1,91 @@
+package controllers
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+
+	"backend-meta-data/models"
+
+	"github.com/gofiber/fiber/v2"
+	"gorm.io/gorm"
+)
+
+// avatarURL links to GetUserAvatar; the version changes whenever the image does
+func avatarURL(av *models.UserAvatar) string {
+	return fmt.Sprintf("/api/users/%d/avatar?v=%d", av.UserID, av.UpdatedAt.Unix())
+}
+
+// GetUserAvatar handles GET /api/users/:id/avatar
+func GetUserAvatar(db *gorm.DB) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		id, err := c.ParamsInt("id")
+		if err != nil || id <= 0 {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "invalid user id"})
+		}
+		av, err := models.GetUserAvatar(db, uint(id))
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "avatar not found"})
+		}
+		if err != nil {
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "failed to fetch avatar"})
+		}
+		c.Set(fiber.HeaderContentType, av.ContentType)
+		c.Set(fiber.HeaderXContentTypeOptions, "nosniff")
+		c.Set(fiber.HeaderCacheControl, "private, max-age=86400")
+		c.Set(fiber.HeaderLastModified, av.UpdatedAt.UTC().Format(http.TimeFormat))
+		return c.Send(av.Data)
+	}
+}
+
+// UploadMyAvatar handles PUT /me/avatar with a multipart "avatar" file or a raw image body
+func UploadMyAvatar(db *gorm.DB) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		user, err := models.GetLoggedInUser(c, db)
+		if err != nil {
+			return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"error": "unauthorized"})
+		}
+		data := c.Body()
+		if fh, err := c.FormFile("avatar"); err == nil {
+			if fh.Size > models.MaxAvatarSize {
+				return c.Status(fiber.StatusRequestEntityTooLarge).JSON(fiber.Map{"error": "avatar must be at most 2 MB"})
+			}
+			f, err := fh.Open()
+			if err != nil {
+				return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "invalid avatar upload"})
+			}
+			defer f.Close()
+			if data, err = io.ReadAll(f); err != nil {
+				return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "invalid avatar upload"})
+			}
+		}
+		// The type is sniffed from the bytes; the client's Content-Type is not trusted
+		if err := models.UpsertUserAvatar(db, user.ID, data, ""); err != nil {
+			var fe *fiber.Error
+			if errors.As(err, &fe) {
+				return c.Status(fe.Code).JSON(fiber.Map{"error": fe.Message})
+			}
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "failed to save avatar"})
+		}
+		av, err := models.GetUserAvatarInfo(db, user.ID)
+		if err != nil {
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "failed to fetch avatar"})
+		}
+		return c.JSON(fiber.Map{"data": av, "avatar_url": avatarURL(av)})
+	}
+}
+
+// DeleteMyAvatar handles DELETE /me/avatar
+func DeleteMyAvatar(db *gorm.DB) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		user, err := models.GetLoggedInUser(c, db)
+		if err != nil {
+			return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"error": "unauthorized"})
+		}
+		if err := models.RemoveUserAvatar(db, user.ID); err != nil {
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "failed to delete avatar"})
+		}
+		return c.SendStatus(fiber.StatusNoContent)
+	}
+}
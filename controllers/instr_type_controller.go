@@ -1,6 +1,7 @@
 package controllers
 
 import (
+	"backend-meta-data/middleware"
 	"backend-meta-data/models"
 	"strconv"
 
@@ -34,7 +35,7 @@ func CreateInstrumentType(db *gorm.DB) fiber.Handler {
 func ListInstrumentTypes(db *gorm.DB) fiber.Handler {
 	return func(c *fiber.Ctx) error {
 		var items []models.InstrumentType
-		if err := db.Order("name asc").Find(&items).Error; err != nil {
+		if err := middleware.ReadFrom(c, db).Order("name asc").Find(&items).Error; err != nil {
 			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "failed to fetch instrument types"})
 		}
 		return c.JSON(fiber.Map{"data": items})
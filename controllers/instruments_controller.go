@@ -6,6 +6,7 @@ import (
 	"github.com/gofiber/fiber/v2"
 	"gorm.io/gorm"
 
+	"backend-meta-data/middleware"
 	"backend-meta-data/models"
 )
 
@@ -17,7 +18,7 @@ func ListInstruments(db *gorm.DB) fiber.Handler {
 			_ = db.AutoMigrate(&models.Instrument{})
 		}
 		var list []models.Instrument
-		if err := db.Order("id desc").Find(&list).Error; err != nil {
+		if err := middleware.ReadFrom(c, db).Order("id desc").Find(&list).Error; err != nil {
 			// If table missing or similar, try migrate and retry once
 			msg := strings.ToLower(err.Error())
 			if strings.Contains(msg, "doesn't exist") || strings.Contains(msg, "no such table") {
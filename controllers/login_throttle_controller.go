@@ -0,0 +1,66 @@
+package controllers
+
+import (
+	"errors"
+	"net/url"
+	"time"
+
+	"backend-meta-data/auth"
+	"backend-meta-data/models"
+
+	"github.com/gofiber/fiber/v2"
+	"gorm.io/gorm"
+)
+
+// UnlockUser handles POST /api/users/:id/unlock: clears a lock from failed
+// logins and the user's failure count
+func UnlockUser(db *gorm.DB) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		admin, err := models.GetLoggedInUser(c, db)
+		if err != nil || auth.Default == nil {
+			return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"error": "unauthorized"})
+		}
+		id, err := c.ParamsInt("id")
+		if err != nil || id <= 0 {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "invalid user id"})
+		}
+		user, err := models.FindUserByID(db, uint(id))
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "user not found"})
+		}
+		if err != nil {
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "failed to fetch user"})
+		}
+		if err := auth.Default.Unlock(c, user, admin.Username); err != nil {
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "failed to unlock user"})
+		}
+		return c.JSON(fiber.Map{"message": "User unlocked"})
+	}
+}
+
+// ListLoginThrottles handles GET /api/login-throttles: usernames and IPs with
+// failed logins in the last day, most failures first
+func ListLoginThrottles(db *gorm.DB) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		rows, err := models.ListLoginThrottles(db, time.Now().Add(-24*time.Hour))
+		if err != nil {
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "failed to list login throttles"})
+		}
+		return c.JSON(fiber.Map{"data": rows})
+	}
+}
+
+// ClearLoginThrottle handles DELETE /api/login-throttles/:key, e.g.
+// ip:10.0.0.5 (URL-encoded), to lift an IP or username backoff early
+func ClearLoginThrottle(db *gorm.DB) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		key, err := url.PathUnescape(c.Params("key"))
+		if err != nil || key == "" {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "invalid key"})
+		}
+		if err := models.ClearLoginFailures(db, key); err != nil {
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "failed to clear login throttle"})
+		}
+		return c.JSON(fiber.Map{"message": "Login throttle cleared"})
+	}
+}
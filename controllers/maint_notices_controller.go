@@ -4,56 +4,26 @@ import (
 	"crypto/tls"
 	"fmt"
 	"net/smtp"
-	"os"
-	"strconv"
 	"strings"
 	"time"
 
 	"github.com/gofiber/fiber/v2"
-	"gorm.io/driver/mysql"
+	log "github.com/sirupsen/logrus"
 	"gorm.io/gorm"
 
+	"backend-meta-data/config"
 	"backend-meta-data/models"
+	"backend-meta-data/settings"
 )
 
-var gormOnceDB *gorm.DB
-
-func getDB() (*gorm.DB, error) {
-	if gormOnceDB != nil {
-		return gormOnceDB, nil
-	}
-	host := os.Getenv("DB_HOST")
-	if host == "" {
-		host = "127.0.0.1"
-	}
-	portStr := os.Getenv("DB_PORT")
-	if portStr == "" {
-		portStr = "3306"
-	}
-	user := os.Getenv("DB_USER")
-	pass := os.Getenv("DB_PASSWORD")
-	name := os.Getenv("DB_NAME")
-	dsn := fmt.Sprintf("%s:%s@tcp(%s:%s)/%s?parseTime=true&charset=utf8mb4,utf8", user, pass, host, portStr, name)
-	db, err := gorm.Open(mysql.Open(dsn), &gorm.Config{})
-	if err != nil {
-		return nil, err
-	}
-	gormOnceDB = db
-	return db, nil
-}
-
-func sendSMTP(to string, subject string, htmlBody string) error {
-	host := os.Getenv("SMTP_HOST")
-	portStr := os.Getenv("SMTP_PORT")
-	user := os.Getenv("SMTP_USERNAME")
-	pass := os.Getenv("SMTP_PASSWORD")
-	fromName := os.Getenv("SMTP_FROM_NAME")
-	fromEmail := os.Getenv("SMTP_FROM_EMAIL")
-	useTLS := strings.ToLower(os.Getenv("SMTP_USE_TLS")) == "true"
-	if host == "" || portStr == "" || fromEmail == "" {
+func sendSMTP(cfg *config.SMTPConfig, to string, subject string, htmlBody string) error {
+	host, port := cfg.Host, cfg.Port
+	user, pass := cfg.Username, cfg.Password
+	fromName, fromEmail := settings.Default.String("smtp.from_name", cfg.FromName), cfg.FromEmail
+	useTLS := cfg.UseTLS
+	if host == "" || port == 0 || fromEmail == "" {
 		return fmt.Errorf("smtp config missing")
 	}
-	port, _ := strconv.Atoi(portStr)
 
 	from := fromEmail
 	headers := make(map[string]string)
@@ -71,7 +41,10 @@ func sendSMTP(to string, subject string, htmlBody string) error {
 	msg.WriteString(htmlBody)
 
 	addr := fmt.Sprintf("%s:%d", host, port)
-	auth := smtp.PlainAuth("", user, pass, host)
+	var auth smtp.Auth
+	if user != "" {
+		auth = smtp.PlainAuth("", user, pass, host)
+	}
 
 	// Try STARTTLS if useTLS
 	if useTLS {
@@ -87,7 +60,7 @@ func sendSMTP(to string, subject string, htmlBody string) error {
 				return err
 			}
 		}
-		if user != "" {
+		if auth != nil {
 			if ok, _ := c.Extension("AUTH"); ok {
 				if err := c.Auth(auth); err != nil {
 					return err
@@ -114,7 +87,7 @@ func sendSMTP(to string, subject string, htmlBody string) error {
 }
 
 // CreateMaintNotice handles POST /api/maint-notices
-func CreateMaintNotice() fiber.Handler {
+func CreateMaintNotice(db *gorm.DB, cfg *config.Config) fiber.Handler {
 	return func(c *fiber.Ctx) error {
 		var payload struct {
 			Station  string     `json:"station"`
@@ -133,12 +106,9 @@ func CreateMaintNotice() fiber.Handler {
 		if payload.ToAddr == "" || payload.Station == "" || payload.Subject == "" || payload.Body == "" {
 			return fiber.NewError(fiber.StatusBadRequest, "missing required fields")
 		}
-		db, err := getDB()
-		if err != nil {
-			return fiber.NewError(fiber.StatusInternalServerError, "db error")
+		if payload.Template == "" {
+			payload.Template = settings.Default.String("notice.default_template", "")
 		}
-		// ensure table exists
-		_ = db.AutoMigrate(&models.MaintNoticeEmail{})
 		n := models.MaintNoticeEmail{
 			Station:      payload.Station,
 			FromTime:     payload.From,
@@ -154,9 +124,12 @@ func CreateMaintNotice() fiber.Handler {
 		if err := db.Create(&n).Error; err != nil {
 			return fiber.NewError(fiber.StatusInternalServerError, "save failed")
 		}
-		if err := sendSMTP(n.To, n.Subject, n.Body); err != nil {
+		if !settings.Default.Bool("notice.send_email", true) {
+			return c.JSON(fiber.Map{"data": n})
+		}
+		if err := sendSMTP(&cfg.SMTP, n.To, n.Subject, n.Body); err != nil {
 			// log but still return success with warning
-			fmt.Println("smtp send failed:", err)
+			log.Errorf("smtp send to %s failed: %v", n.To, err)
 		}
 		return c.JSON(fiber.Map{"data": n})
 	}
@@ -0,0 +1,209 @@
+package controllers
+
+import (
+	"errors"
+
+	"backend-meta-data/auth"
+	"backend-meta-data/models"
+
+	"github.com/gofiber/fiber/v2"
+	"gorm.io/gorm"
+)
+
+type mfaRequest struct {
+	MFAToken string `json:"mfa_token"`
+	Code     string `json:"code"` // TOTP code or recovery code
+}
+
+// mfaChallengeResponse is the 202 body of a login that still needs a code
+func mfaChallengeResponse(res *auth.Result) fiber.Map {
+	message := "MFA code required"
+	if res.MFAEnroll {
+		message = "MFA enrollment required: POST /auth/mfa/enroll, then /auth/mfa/verify"
+	}
+	return fiber.Map{
+		"message":      message,
+		"mfa_required": true,
+		"mfa_enroll":   res.MFAEnroll,
+		"mfa_token":    res.MFAToken,
+		"provider":     res.Provider,
+	}
+}
+
+// mfaError maps auth MFA errors to a response
+func mfaError(c *fiber.Ctx, err error) error {
+	var throttled *auth.ThrottledError
+	switch {
+	case errors.As(err, &throttled):
+		return tooManyAttempts(c, throttled)
+	case errors.Is(err, models.ErrAccountLocked):
+		return c.Status(fiber.StatusLocked).JSON(fiber.Map{"error": "Account is temporarily locked after too many failed logins"})
+	case errors.Is(err, auth.ErrInvalidMFACode), errors.Is(err, auth.ErrInvalidMFAToken):
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"error": err.Error()})
+	case errors.Is(err, auth.ErrMFAEnabled), errors.Is(err, auth.ErrMFANotEnrolled):
+		return c.Status(fiber.StatusConflict).JSON(fiber.Map{"error": err.Error()})
+	case errors.Is(err, auth.ErrMFARequired), errors.Is(err, models.ErrAccountDisabled):
+		return c.Status(fiber.StatusForbidden).JSON(fiber.Map{"error": err.Error()})
+	}
+	return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "MFA request failed"})
+}
+
+// parseMFARequest reads the body; it is optional for routes that need no code
+func parseMFARequest(c *fiber.Ctx) (mfaRequest, error) {
+	var req mfaRequest
+	if len(c.Body()) > 0 {
+		if err := c.BodyParser(&req); err != nil {
+			return req, err
+		}
+	}
+	return req, nil
+}
+
+// MFAEnrollWithToken handles POST /auth/mfa/enroll: a user whose role requires
+// MFA gets a secret during the login that asked them to enroll
+func MFAEnrollWithToken() fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		if auth.Default == nil {
+			return c.Status(fiber.StatusServiceUnavailable).JSON(fiber.Map{"error": "Authentication is not configured"})
+		}
+		req, err := parseMFARequest(c)
+		if err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid request body"})
+		}
+		secret, uri, err := auth.Default.EnrollMFAWithToken(req.MFAToken)
+		if err != nil {
+			return mfaError(c, err)
+		}
+		return c.JSON(fiber.Map{"secret": secret, "otpauth_uri": uri})
+	}
+}
+
+// MFAVerify handles POST /auth/mfa/verify: the second login step. Users who
+// just enrolled also get their recovery codes.
+func MFAVerify() fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		if auth.Default == nil {
+			return c.Status(fiber.StatusServiceUnavailable).JSON(fiber.Map{"error": "Authentication is not configured"})
+		}
+		req, err := parseMFARequest(c)
+		if err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid request body"})
+		}
+		res, codes, err := auth.Default.CompleteMFALogin(c, req.MFAToken, req.Code)
+		if err != nil {
+			return mfaError(c, err)
+		}
+		body := tokenResponse("Login successful", res)
+		if codes != nil {
+			body["recovery_codes"] = codes
+		}
+		return c.JSON(body)
+	}
+}
+
+// EnrollMyMFA handles POST /me/mfa: starts (or restarts) enrollment with a new secret
+func EnrollMyMFA(db *gorm.DB) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		user, err := models.GetLoggedInUser(c, db)
+		if err != nil || auth.Default == nil {
+			return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"error": "unauthorized"})
+		}
+		secret, uri, err := auth.Default.EnrollMFA(user)
+		if err != nil {
+			return mfaError(c, err)
+		}
+		return c.JSON(fiber.Map{"secret": secret, "otpauth_uri": uri})
+	}
+}
+
+// ConfirmMyMFA handles POST /me/mfa/confirm: enables MFA with a first code and
+// returns the recovery codes
+func ConfirmMyMFA(db *gorm.DB) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		user, err := models.GetLoggedInUser(c, db)
+		if err != nil || auth.Default == nil {
+			return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"error": "unauthorized"})
+		}
+		req, err := parseMFARequest(c)
+		if err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid request body"})
+		}
+		codes, err := auth.Default.ConfirmMFA(c, user, req.Code)
+		if err != nil {
+			return mfaError(c, err)
+		}
+		return c.JSON(fiber.Map{"message": "MFA enabled", "recovery_codes": codes})
+	}
+}
+
+// DisableMyMFA handles DELETE /me/mfa with a current code
+func DisableMyMFA(db *gorm.DB) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		user, err := models.GetLoggedInUser(c, db)
+		if err != nil || auth.Default == nil {
+			return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"error": "unauthorized"})
+		}
+		req, err := parseMFARequest(c)
+		if err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid request body"})
+		}
+		if err := auth.Default.DisableMFA(c, user, req.Code); err != nil {
+			return mfaError(c, err)
+		}
+		return c.JSON(fiber.Map{"message": "MFA disabled"})
+	}
+}
+
+// RegenerateMyRecoveryCodes handles POST /me/mfa/recovery-codes with a current code
+func RegenerateMyRecoveryCodes(db *gorm.DB) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		user, err := models.GetLoggedInUser(c, db)
+		if err != nil || auth.Default == nil {
+			return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"error": "unauthorized"})
+		}
+		req, err := parseMFARequest(c)
+		if err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid request body"})
+		}
+		codes, err := auth.Default.RegenerateRecoveryCodes(c, user, req.Code)
+		if err != nil {
+			return mfaError(c, err)
+		}
+		return c.JSON(fiber.Map{"recovery_codes": codes})
+	}
+}
+
+// ResetUserMFA handles DELETE /api/users/:id/mfa: an admin clears a user's MFA
+// (e.g. a lost phone and no recovery codes); roles that require MFA enroll
+// again at their next login. Only users whose role is below the admin's can be
+// reset; admins never reset their own MFA, which would skip the code check.
+func ResetUserMFA(db *gorm.DB) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		admin, err := models.GetLoggedInUser(c, db)
+		if err != nil {
+			return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"error": "unauthorized"})
+		}
+		id, err := c.ParamsInt("id")
+		if err != nil || id <= 0 {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "invalid user id"})
+		}
+		user, err := models.FindUserByID(db, uint(id))
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "user not found"})
+		}
+		if err != nil {
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "failed to fetch user"})
+		}
+		if admin.ID == user.ID {
+			return c.Status(fiber.StatusForbidden).JSON(fiber.Map{"error": "use DELETE /me/mfa to turn off your own MFA"})
+		}
+		if !admin.Outranks(user) {
+			return c.Status(fiber.StatusForbidden).JSON(fiber.Map{"error": "can only reset MFA of users whose role is below yours"})
+		}
+		if err := models.DisableMFA(db, user); err != nil {
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "failed to reset MFA"})
+		}
+		logActivity(c, db, user, "mfa_reset", "by "+admin.Username)
+		return c.JSON(fiber.Map{"message": "MFA reset"})
+	}
+}
@@ -0,0 +1,72 @@
+package controllers
+
+import (
+	"errors"
+
+	"backend-meta-data/settings"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// ListSettings handles GET /api/settings with the resolved value and source of every setting
+func ListSettings() fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		list, err := settings.Default.All()
+		if err != nil {
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "failed to load settings"})
+		}
+		return c.JSON(fiber.Map{"data": list})
+	}
+}
+
+// GetSetting handles GET /api/settings/:key
+func GetSetting() fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		st, ok := settings.Default.Get(c.Params("key"))
+		if !ok && st.Source == "" {
+			return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "setting not found"})
+		}
+		return c.JSON(fiber.Map{"data": st})
+	}
+}
+
+// UpdateSetting handles PUT /api/settings/:key; the stored value takes precedence over the config files
+func UpdateSetting() fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		var req struct {
+			Value       *string `json:"value"`
+			Description string  `json:"description"`
+		}
+		if err := c.BodyParser(&req); err != nil || req.Value == nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "invalid payload, expected {\"value\": \"...\"}"})
+		}
+		key := c.Params("key")
+		if err := settings.Default.Set(key, *req.Value, req.Description); err != nil {
+			if errors.Is(err, settings.ErrInvalidKey) || errors.Is(err, settings.ErrFileOnly) {
+				return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
+			}
+			var invalid *settings.InvalidValueError
+			if errors.As(err, &invalid) {
+				return c.Status(fiber.StatusUnprocessableEntity).JSON(fiber.Map{"error": err.Error()})
+			}
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "failed to save setting"})
+		}
+		st, _ := settings.Default.Get(key)
+		return c.JSON(fiber.Map{"data": st})
+	}
+}
+
+// DeleteSetting handles DELETE /api/settings/:key, reverting to the file or default value
+func DeleteSetting() fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		key := c.Params("key")
+		if err := settings.Default.Delete(key); err != nil {
+			if errors.Is(err, settings.ErrInvalidKey) {
+				return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
+			}
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "failed to delete setting"})
+		}
+		st, _ := settings.Default.Get(key)
+		return c.JSON(fiber.Map{"data": st})
+	}
+}
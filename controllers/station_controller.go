@@ -6,6 +6,7 @@ import (
 	"github.com/gofiber/fiber/v2"
 	"gorm.io/gorm"
 
+	"backend-meta-data/middleware"
 	"backend-meta-data/models"
 )
 
@@ -16,7 +17,7 @@ func ListStations(db *gorm.DB) fiber.Handler {
 			_ = db.AutoMigrate(&models.Station{})
 		}
 		var list []models.Station
-		query := db.Order("id asc")
+		query := middleware.ReadFrom(c, db).Order("id asc")
 
 		if typeParam := c.Query("type"); typeParam != "" {
 			query = query.Where("station_type_id = ?", typeParam)
@@ -1,6 +1,7 @@
 package controllers
 
 import (
+	"backend-meta-data/middleware"
 	"backend-meta-data/models"
 
 	"github.com/gofiber/fiber/v2"
@@ -16,7 +17,7 @@ func ListStores(db *gorm.DB) fiber.Handler {
 		sortField := c.Query("sortField", "name")
 		sortOrder := c.Query("sortOrder", "asc")
 
-		rows, total, err := models.ListInventoryStores(db, search, page, size, sortField, sortOrder)
+		rows, total, err := models.ListInventoryStores(middleware.ReadFrom(c, db), search, page, size, sortField, sortOrder)
 		if err != nil {
 			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "failed to fetch stores"})
 		}
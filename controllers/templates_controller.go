@@ -12,11 +12,12 @@ import (
 
 var tmplNameRe = regexp.MustCompile(`(?i)Template\s*Name\s*:\s*(.+)`) // capture after 'Template Name:'
 
-func resolveMaintTemplatesDir() string {
+// resolveTemplatesDir finds email_templates/<group> from the usual working directories
+func resolveTemplatesDir(group string) string {
 	candidates := []string{
-		filepath.Join(".", "src", "backend", "email_templates", "maint_notice"),
-		filepath.Join(".", "backend", "email_templates", "maint_notice"),
-		filepath.Join(".", "email_templates", "maint_notice"),
+		filepath.Join(".", "src", "backend", "email_templates", group),
+		filepath.Join(".", "backend", "email_templates", group),
+		filepath.Join(".", "email_templates", group),
 	}
 	for _, p := range candidates {
 		if fi, err := os.Stat(p); err == nil && fi.IsDir() {
@@ -28,7 +29,7 @@ func resolveMaintTemplatesDir() string {
 
 func ListMaintNoticeTemplates() fiber.Handler {
 	return func(c *fiber.Ctx) error {
-		base := resolveMaintTemplatesDir()
+		base := resolveTemplatesDir("maint_notice")
 		entries, err := os.ReadDir(base)
 		if err != nil {
 			return c.Status(http.StatusInternalServerError).JSON(fiber.Map{"error": "failed to read templates"})
@@ -72,7 +73,7 @@ func GetMaintNoticeTemplate() fiber.Handler {
 		if ext := strings.ToLower(filepath.Ext(base)); ext != ".html" && ext != ".htm" {
 			return c.Status(http.StatusBadRequest).JSON(fiber.Map{"error": "unsupported template extension"})
 		}
-		dir := resolveMaintTemplatesDir()
+		dir := resolveTemplatesDir("maint_notice")
 		path := filepath.Join(dir, base)
 		b, err := os.ReadFile(path)
 		if err != nil {
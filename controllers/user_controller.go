@@ -1,94 +1,59 @@
 package controllers
 
 import (
+	"strings"
+
 	"backend-meta-data/middleware"
 	"backend-meta-data/models"
-	"database/sql"
-	"os"
-	"strconv"
 
-	"github.com/go-ldap/ldap/v3"
 	"github.com/gofiber/fiber/v2"
-	"github.com/gofiber/fiber/v2/middleware/session"
+	log "github.com/sirupsen/logrus"
 	"gorm.io/gorm"
 )
 
-var Store = session.New()
+// meActivityLimit is how many recent activity entries /me returns
+const meActivityLimit = 10
 
-func Login(dbConn *sql.DB) fiber.Handler {
+// Me returns the caller resolved by AuthMiddleware with their Casbin roles and
+// permissions, avatar link and recent activity
+func Me(db *gorm.DB) fiber.Handler {
 	return func(c *fiber.Ctx) error {
-		type LoginRequest struct {
-			Username string `json:"username"`
-			Password string `json:"password"`
-		}
-		var req LoginRequest
-		if err := c.BodyParser(&req); err != nil {
-			return c.Status(400).JSON(fiber.Map{"error": "Invalid request"})
-		}
-
-		ldapServer := os.Getenv("LDAP_SERVER")
-		ldapPort := 389
-		bindDN := req.Username
-		bindPassword := req.Password
-
-		l, err := ldap.Dial("tcp", ldapServer+":"+strconv.Itoa(ldapPort))
+		user, err := models.GetLoggedInUser(c, db)
 		if err != nil {
-			return c.Status(500).JSON(fiber.Map{"error": "LDAP connection failed"})
-		}
-		defer l.Close()
-
-		if err := l.Bind(bindDN, bindPassword); err != nil {
-			return c.Status(401).JSON(fiber.Map{"error": "Authentication failed"})
+			return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"error": "User not logged in"})
 		}
-		// TODO: Query database for user ID using username
-		userID := 123 // Placeholder for actual user ID
-		sess, err := Store.Get(c)
+		roles, perms, err := middleware.UserAccess(user.Username)
 		if err != nil {
-			return c.Status(500).JSON(fiber.Map{"error": "Session error"})
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "failed to load permissions"})
 		}
-		sess.Set("username", req.Username)
-		sess.Set("userID", userID)
-		sess.Save()
-		return c.JSON(fiber.Map{"message": "Authentication successful", "user": fiber.Map{"username": req.Username, "userID": userID}})
-	}
-}
-
-func Logout() fiber.Handler {
-	return func(c *fiber.Ctx) error {
-		sess, err := Store.Get(c)
-		if err == nil {
-			sess.Destroy()
+		var avatar *string
+		if av, err := models.GetUserAvatarInfo(db, user.ID); err == nil {
+			u := avatarURL(av)
+			avatar = &u
 		}
-		return c.JSON(fiber.Map{"message": "Logout successful"})
-	}
-}
-
-func Me(dbConn *sql.DB) fiber.Handler {
-	return func(c *fiber.Ctx) error {
-		sess, err := Store.Get(c)
+		activity, _, err := models.GetUserActivities(db, user.ID, 1, meActivityLimit)
 		if err != nil {
-			return c.Status(401).JSON(fiber.Map{"error": "Session not found"})
-		}
-		username := sess.Get("username")
-		userID := sess.Get("userID")
-		if username == nil || userID == nil {
-			return c.Status(401).JSON(fiber.Map{"error": "User not logged in"})
-		}
-		// Query database for full user object (placeholder)
-		// TODO: Replace with actual DB query
-		user := fiber.Map{
-			"userID":      userID,
-			"username":    username,
-			"email":       "user@example.com",
-			"displayName": "John Doe",
-		}
-		return c.JSON(fiber.Map{"user": user})
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "failed to load activity"})
+		}
+		return c.JSON(fiber.Map{
+			"user":            user,
+			"roles":           roles,
+			"permissions":     perms,
+			"avatar_url":      avatar,
+			"recent_activity": activity,
+		})
 	}
 }
 
-// CreateUser handles POST /api/users to insert a new user using the models.User helper
+// CreateUser handles POST /api/users. An empty role means the default role; an
+// unknown role is a 400 and one the caller cannot grant a 403. The Casbin
+// grouping is added in the same transaction as the user.
 func CreateUser(db *gorm.DB) fiber.Handler {
 	return func(c *fiber.Ctx) error {
+		caller, err := models.GetLoggedInUser(c, db)
+		if err != nil {
+			return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"error": "unauthorized"})
+		}
 		var req struct {
 			Username string `json:"username"`
 			Password string `json:"password"`
@@ -98,18 +63,31 @@ func CreateUser(db *gorm.DB) fiber.Handler {
 		if err := c.BodyParser(&req); err != nil {
 			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "invalid payload"})
 		}
-		u := models.User{Username: req.Username, Password: req.Password, Email: req.Email, Role: req.Role}
-		if err := models.CreateUser(db, &u); err != nil {
-			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
+		role := req.Role
+		if role == "" {
+			role = models.DefaultRole
+		}
+		if !models.ValidRole(role) {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "role must be one of Root, Admin, Inspector"})
+		}
+		if !caller.CanGrant(role) {
+			return c.Status(fiber.StatusForbidden).JSON(fiber.Map{"error": "forbidden"})
+		}
+		u := models.User{Username: req.Username, Password: req.Password, Email: req.Email, Role: role, Active: true, Deleted: "No"}
+		group := func(tx *gorm.DB) error {
+			return middleware.GroupInTx(tx, u.Username, role)
 		}
-		// assign role in Casbin if provided
-		if req.Role != "" {
-			_ = middleware.AssignRole(c.Context(), u.Username, req.Role)
+		if err := models.CreateUser(db, &u, group); err != nil {
+			if fe, ok := err.(*fiber.Error); ok {
+				return c.Status(fe.Code).JSON(fiber.Map{"error": fe.Message})
+			}
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "failed to create user"})
+		}
+		// the row is stored; a failure here is fixed by the next policy reload
+		if err := middleware.AssignRole(c.Context(), u.Username, role); err != nil {
+			log.Warnf("loading the %s grouping for %s failed: %v", role, u.Username, err)
 		}
-		u.Password = ""
-		// Ensure email is included from request since reload may not catch it
-		u.Email = req.Email
-		// Reload user to get all fields including email
+		logActivity(c, db, &u, "user_created", "by "+caller.Username)
 		if err := db.First(&u, u.ID).Error; err != nil {
 			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "failed to fetch created user"})
 		}
@@ -121,36 +99,22 @@ func CreateUser(db *gorm.DB) fiber.Handler {
 func ListUsers(db *gorm.DB) fiber.Handler {
 	return func(c *fiber.Ctx) error {
 		var users []models.User
-		if err := db.Order("id asc").Find(&users).Error; err != nil {
+		if err := middleware.ReadFrom(c, db).Order("id asc").Find(&users).Error; err != nil {
 			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "failed to fetch users"})
 		}
-		for i := range users {
-			users[i].Password = ""
-		}
 		return c.JSON(fiber.Map{"data": users})
 	}
 }
 
-// UpdateUser handles PATCH /api/users/:id
+// UpdateUser handles PATCH /api/users/:id. rbac has already checked the
+// route; the caller must also not be outranked by the user, and can only give
+// roles below their own.
 func UpdateUser(db *gorm.DB) fiber.Handler {
 	return func(c *fiber.Ctx) error {
-		// Ensure authentication
 		loggedInUser, err := models.GetLoggedInUser(c, db)
 		if err != nil {
 			return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"error": "unauthorized"})
 		}
-
-		// Check permission using Casbin middleware
-		obj := c.Path()   // e.g. "/api/users/123"
-		act := c.Method() // "PATCH"
-
-		ok, err := middleware.Enforcer.Enforce(loggedInUser.Username, obj, act)
-		if err != nil {
-			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "internal server error"})
-		}
-		if !ok {
-			return c.Status(fiber.StatusForbidden).JSON(fiber.Map{"error": "forbidden"})
-		}
 		id, err := c.ParamsInt("id")
 		if err != nil {
 			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "invalid user id"})
@@ -171,31 +135,121 @@ func UpdateUser(db *gorm.DB) fiber.Handler {
 		if err := db.First(&user, id).Error; err != nil {
 			return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "user not found"})
 		}
+		if !loggedInUser.CanManage(&user) {
+			return c.Status(fiber.StatusForbidden).JSON(fiber.Map{"error": "forbidden"})
+		}
 
 		// Update fields if provided in request
+		oldUsername := user.Username
 		if req.Username != "" {
 			user.Username = req.Username
 		}
-		if req.Email != "" {
+		renamed := user.Username != oldUsername
+		if req.Email != "" && req.Email != user.Email {
 			user.Email = req.Email
+			user.EmailVerifiedAt = nil
 		}
-		if req.Role != "" {
-			user.Role = req.Role
-			// Update role in Casbin if changed
-			if err := middleware.AssignRole(c.Context(), user.Username, req.Role); err != nil {
-				return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "failed to update role"})
+		roleChanged := req.Role != "" && req.Role != user.Role
+		if roleChanged {
+			if !models.ValidRole(req.Role) {
+				return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "role must be one of Root, Admin, Inspector"})
+			}
+			if !loggedInUser.CanGrant(req.Role) {
+				return c.Status(fiber.StatusForbidden).JSON(fiber.Map{"error": "forbidden"})
 			}
+			user.Role = req.Role
 		}
 		if req.Password != "" {
-			user.Password = req.Password
+			if err := user.SetPassword(req.Password); err != nil {
+				return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
+			}
 		}
 
-		if err := db.Save(&user).Error; err != nil {
+		// Casbin rules are keyed by username, so a rename moves them with the row
+		err = db.Transaction(func(tx *gorm.DB) error {
+			if err := tx.Save(&user).Error; err != nil {
+				return err
+			}
+			if renamed {
+				return middleware.RenameInTx(tx, oldUsername, user.Username)
+			}
+			return nil
+		})
+		if err != nil {
 			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "failed to update user"})
 		}
+		if renamed {
+			if err := middleware.RenameSubject(oldUsername, user.Username); err != nil {
+				return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "failed to update role"})
+			}
+		}
+		// The old base role is removed so its permissions do not linger
+		if roleChanged {
+			if err := middleware.ReplaceRole(c.Context(), user.Username, user.Role); err != nil {
+				return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "failed to update role"})
+			}
+		}
+
+		return c.JSON(fiber.Map{"data": user})
+	}
+}
 
-		// Clear password before returning
-		user.Password = ""
+// UpdateProfile handles PATCH /api/users/profile: the caller changes their own
+// email or password. Both need the current password, so a stolen session
+// cannot redirect password reset links.
+func UpdateProfile(db *gorm.DB) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		user, err := models.GetLoggedInUser(c, db)
+		if err != nil {
+			return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"error": "unauthorized"})
+		}
+		var req struct {
+			Email           string `json:"email"`
+			Password        string `json:"password"`
+			CurrentPassword string `json:"current_password"`
+		}
+		if err := c.BodyParser(&req); err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "invalid payload"})
+		}
+		updates := map[string]any{}
+		if req.Email != "" && req.Email != user.Email {
+			if !strings.Contains(req.Email, "@") {
+				return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "invalid email format"})
+			}
+			if user.Source != "local" {
+				return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "email is managed by " + user.Source})
+			}
+			if !user.CheckPassword(req.CurrentPassword) {
+				return c.Status(fiber.StatusForbidden).JSON(fiber.Map{"error": "current password is wrong"})
+			}
+			updates["email"] = req.Email
+			updates["email_verified_at"] = nil
+			user.Email, user.EmailVerifiedAt = req.Email, nil
+		}
+		if req.Password != "" {
+			if user.Source != "local" {
+				return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "password is managed by " + user.Source})
+			}
+			if !user.CheckPassword(req.CurrentPassword) {
+				return c.Status(fiber.StatusForbidden).JSON(fiber.Map{"error": "current password is wrong"})
+			}
+			if err := user.SetPassword(req.Password); err != nil {
+				return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
+			}
+			updates["password"] = user.Password
+		}
+		if len(updates) > 0 {
+			if err := db.Model(user).Updates(updates).Error; err != nil {
+				return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "failed to update profile"})
+			}
+		}
 		return c.JSON(fiber.Map{"data": user})
 	}
 }
+
+// logActivity records an admin action in the target user's activity log
+func logActivity(c *fiber.Ctx, db *gorm.DB, user *models.User, activity, details string) {
+	if err := models.LogActivity(db, user.ID, activity, details, c.IP(), c.Get(fiber.HeaderUserAgent)); err != nil {
+		log.Warnf("recording %s for %s failed: %v", activity, user.Username, err)
+	}
+}
@@ -2,91 +2,130 @@ package db
 
 import (
 	"backend-meta-data/config"
-	"backend-meta-data/models"
 	"fmt"
-	"os"
+	"net"
+	"net/url"
+	"strconv"
+	"strings"
 
+	"github.com/glebarez/sqlite"
 	"gorm.io/driver/mysql"
+	"gorm.io/driver/postgres"
+	"gorm.io/driver/sqlserver"
 	"gorm.io/gorm"
 )
 
+// Supported values for DBConfig.Type
+const (
+	DriverMySQL     = "mysql"
+	DriverPostgres  = "postgres"
+	DriverSQLServer = "sqlserver"
+	DriverSQLite    = "sqlite"
+)
+
+// Dialector returns the GORM dialector for cfg.Type; an empty type means mysql
+func Dialector(cfg *config.DBConfig) (gorm.Dialector, error) {
+	switch driver := driverName(cfg.Type); driver {
+	case DriverMySQL:
+		dsn := fmt.Sprintf("%s:%s@tcp(%s)/%s?parseTime=true&charset=utf8mb4",
+			cfg.User, cfg.Password, hostPort(cfg, 3306), cfg.Name)
+		return mysql.Open(dsn), nil
+	case DriverPostgres:
+		sslMode := cfg.SSLMode
+		if sslMode == "" {
+			sslMode = "disable"
+		}
+		port := cfg.Port
+		if port == 0 {
+			port = 5432
+		}
+		dsn := fmt.Sprintf("host=%s port=%d user=%s password=%s dbname=%s sslmode=%s",
+			pgQuote(cfg.Host), port, pgQuote(cfg.User), pgQuote(cfg.Password), pgQuote(cfg.Name), sslMode)
+		return postgres.Open(dsn), nil
+	case DriverSQLServer:
+		u := url.URL{
+			Scheme:   "sqlserver",
+			User:     url.UserPassword(cfg.User, cfg.Password),
+			Host:     hostPort(cfg, 1433),
+			RawQuery: url.Values{"database": {cfg.Name}}.Encode(),
+		}
+		return sqlserver.Open(u.String()), nil
+	case DriverSQLite:
+		// Name is the database file (or ":memory:"); foreign keys are off by default in SQLite
+		dsn := cfg.Name
+		if dsn == "" {
+			return nil, fmt.Errorf("sqlite requires db.name to be a file path or :memory:")
+		}
+		sep := "?"
+		if strings.Contains(dsn, "?") {
+			sep = "&"
+		}
+		return sqlite.Open(dsn + sep + "_pragma=foreign_keys(1)"), nil
+	default:
+		return nil, fmt.Errorf("unsupported database type %q (use mysql, postgres, sqlserver or sqlite)", cfg.Type)
+	}
+}
+
+// ConnectGormDB opens the application's single connection pool using the
+// driver selected by cfg.Type, plus any [[db.replicas]]. Use db.DB() for the
+// underlying *sql.DB of the primary.
 func ConnectGormDB(cfg *config.DBConfig) (*gorm.DB, error) {
-	dsn := fmt.Sprintf("%s:%s@tcp(%s:%d)/%s?parseTime=true", cfg.User, cfg.Password, cfg.Host, cfg.Port, cfg.Name)
-	db, err := gorm.Open(mysql.Open(dsn), &gorm.Config{})
+	dialector, err := Dialector(cfg)
 	if err != nil {
 		return nil, err
 	}
-	return db, nil
-}
-
-// InitDBIfNeeded centralizes schema migrations and one-off adjustments
-func InitDBIfNeeded(db *gorm.DB) error {
-	// Allow disabling auto-migration via environment flag
-	if os.Getenv("AUTO_MIGRATE") == "false" {
-		return nil
+	db, err := gorm.Open(dialector, &gorm.Config{})
+	if err != nil {
+		return nil, err
 	}
-
-	// Auto-migrate all core models here
-	if err := db.AutoMigrate(
-		&models.Configuration{},
-		&models.User{},
-		&models.StationType{},
-		&models.Station{},
-		&models.AuditLog{},
-		&models.InstrumentType{},
-		&models.Instrument{},
-		&models.InspectionRecord{},
-		&models.InspectionFormAttachment{},
-		&models.MaintenanceNotice{},
-		&models.InventoryStore{},
-		&models.StationPhoto{},
-		&models.InstrumentPhoto{},
-		&models.UserAvatar{},
-		&models.MaintNoticeTemplate{},
-	); err != nil {
-		return err
+	sqlDB, err := db.DB()
+	if err != nil {
+		return nil, err
 	}
-
-	// Seed maintenance notice templates from filesystem if table is empty
-	var count int64
-	if err := db.Model(&models.MaintNoticeTemplate{}).Count(&count).Error; err == nil && count == 0 {
-		dirs := []string{"./src/backend/email_templates/maint_notice", "./backend/email_templates/maint_notice", "./email_templates/maint_notice"}
-		for _, d := range dirs {
-			_ = models.SeedMaintNoticeTemplatesFromFS(db, d)
-		}
+	if cfg.MaxOpenConns > 0 {
+		sqlDB.SetMaxOpenConns(cfg.MaxOpenConns)
+	}
+	if cfg.MaxIdleConns > 0 {
+		sqlDB.SetMaxIdleConns(cfg.MaxIdleConns)
+	}
+	if cfg.ConnMaxLifetime > 0 {
+		sqlDB.SetConnMaxLifetime(cfg.ConnMaxLifetime)
+	}
+	if cfg.ConnMaxIdleTime > 0 {
+		sqlDB.SetConnMaxIdleTime(cfg.ConnMaxIdleTime)
+	}
+	if err := useReplicas(db, cfg); err != nil {
+		return nil, err
 	}
+	return db, nil
+}
 
-	// Ensure StationTypeID column exists on Stations and create FK constraint (only if missing)
-	if db.Migrator().HasTable(&models.Station{}) {
-		if !db.Migrator().HasColumn(&models.Station{}, "StationTypeID") {
-			if err := db.Migrator().AddColumn(&models.Station{}, "StationTypeID"); err != nil {
-				return err
-			}
-		}
-		if !db.Migrator().HasConstraint(&models.Station{}, "StationType") {
-			if err := db.Migrator().CreateConstraint(&models.Station{}, "StationType"); err != nil {
-				return err
-			}
-		}
+func driverName(t string) string {
+	switch strings.ToLower(strings.TrimSpace(t)) {
+	case "", "mysql", "mariadb":
+		return DriverMySQL
+	case "postgres", "postgresql", "pgsql":
+		return DriverPostgres
+	case "sqlserver", "mssql":
+		return DriverSQLServer
+	case "sqlite", "sqlite3":
+		return DriverSQLite
 	}
+	return t
+}
 
-	// InstrumentTypes: ensure Code values exist and are unique before unique index is enforced
-	if db.Migrator().HasTable(&models.InstrumentType{}) {
-		if !db.Migrator().HasColumn(&models.InstrumentType{}, "Code") {
-			if err := db.Migrator().AddColumn(&models.InstrumentType{}, "Code"); err != nil {
-				return err
-			}
-		}
-		// Backfill empty or NULL codes to unique values (e.g., IT{id}) to satisfy unique constraint
-		if err := db.Exec("UPDATE `InstrumentTypes` SET `code` = CONCAT('IT', id) WHERE `code` IS NULL OR `code` = ''").Error; err != nil {
-			return fmt.Errorf("backfill instrument type codes failed: %w", err)
-		}
+func hostPort(cfg *config.DBConfig, defaultPort int) string {
+	port := cfg.Port
+	if port == 0 {
+		port = defaultPort
 	}
+	return net.JoinHostPort(cfg.Host, strconv.Itoa(port))
+}
 
-	// Ensure Users.role ENUM matches allowed roles
-	if db.Migrator().HasTable(&models.User{}) {
-		// Try altering column to ENUM if DB supports it (MySQL)
-		_ = db.Exec("ALTER TABLE `Users` MODIFY COLUMN `role` ENUM('Root','Admin','Inspector') NOT NULL DEFAULT 'Inspector'").Error
+// pgQuote quotes a keyword/value DSN value when it is empty or contains spaces or quotes
+func pgQuote(s string) string {
+	if s != "" && !strings.ContainsAny(s, ` '\`) {
+		return s
 	}
-	return nil
+	return "'" + strings.NewReplacer(`\`, `\\`, `'`, `\'`).Replace(s) + "'"
 }
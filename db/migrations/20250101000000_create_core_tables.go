@@ -0,0 +1,263 @@
+package migrations
+
+import (
+	"encoding/json"
+	"time"
+
+	"backend-meta-data/db"
+
+	"github.com/shopspring/decimal"
+	"gorm.io/gorm"
+)
+
+// The core tables as they were when migrations were introduced. They are
+// frozen here rather than taken from models, so later migrations that add
+// columns or constraints still have something to add on a fresh database.
+
+type coreConfiguration struct {
+	ID          uint   `gorm:"primaryKey"`
+	Key         string `gorm:"unique;not null"`
+	Value       string `gorm:"not null"`
+	Description string
+	UpdatedAt   time.Time `gorm:"autoUpdateTime"`
+}
+
+func (coreConfiguration) TableName() string { return "Configurations" }
+
+type coreUser struct {
+	ID        uint   `gorm:"primaryKey"`
+	Username  string `gorm:"unique;not null"`
+	Password  string `gorm:"not null"`
+	Email     string `gorm:"size:255"`
+	Active    bool   `gorm:"default:true"`
+	Deleted   string `gorm:"size:3;default:'No'"`
+	Role      string `gorm:"size:20;default:'Inspector';index"`
+	CreatedAt time.Time
+}
+
+func (coreUser) TableName() string { return "Users" }
+
+type coreStationType struct {
+	ID          uint      `gorm:"primaryKey"`
+	Code        string    `gorm:"size:50;uniqueIndex;not null"`
+	Name        string    `gorm:"size:255;not null"`
+	Description string    `gorm:"type:text"`
+	CreatedAt   time.Time `gorm:"autoCreateTime"`
+	UpdatedAt   time.Time `gorm:"autoUpdateTime"`
+}
+
+func (coreStationType) TableName() string { return "StationTypes" }
+
+type coreStation struct {
+	ID            uint   `gorm:"primaryKey"`
+	Name          string `gorm:"not null"`
+	Location      string
+	Latitude      decimal.Decimal `gorm:"type:decimal(18,12)"`
+	Longitude     decimal.Decimal `gorm:"type:decimal(18,12)"`
+	Active        bool            `gorm:"default:true"`
+	StationTypeID uint            `gorm:"not null;index"`
+	StationType   coreStationType `gorm:"constraint:OnUpdate:CASCADE,OnDelete:RESTRICT;"`
+	CreatedAt     time.Time
+}
+
+func (coreStation) TableName() string { return "Stations" }
+
+type coreAuditLog struct {
+	ID        uint   `gorm:"primaryKey"`
+	Entity    string `gorm:"size:64;index:idx_entity,priority:1"`
+	EntityID  uint   `gorm:"index:idx_entity,priority:2"`
+	Action    string `gorm:"size:32;index"`
+	ActorID   *uint  `gorm:"index"`
+	Actor     string `gorm:"size:100"`
+	Details   string
+	Changes   json.RawMessage
+	CreatedAt time.Time `gorm:"autoCreateTime;index:idx_entity,priority:3"`
+}
+
+func (coreAuditLog) TableName() string { return "AuditLogs" }
+
+type coreInstrumentType struct {
+	ID          uint   `gorm:"primaryKey"`
+	Code        string `gorm:"size:50;unique;not null"`
+	Name        string `gorm:"not null;unique"`
+	Category    string
+	Status      string
+	Description string
+	CreatedAt   time.Time `gorm:"autoCreateTime"`
+	UpdatedAt   time.Time `gorm:"autoUpdateTime"`
+}
+
+func (coreInstrumentType) TableName() string { return "InstrumentTypes" }
+
+type coreInventoryStore struct {
+	ID        uint   `gorm:"primaryKey"`
+	Location  string `gorm:"size:200"`
+	Latitude  float64
+	Longitude float64
+	Name      string    `gorm:"size:200;not null"`
+	Code      string    `gorm:"size:100;unique;not null;index"`
+	Deleted   string    `gorm:"size:3;default:'No'"`
+	CreatedAt time.Time `gorm:"autoCreateTime"`
+	UpdatedAt time.Time `gorm:"autoUpdateTime"`
+}
+
+func (coreInventoryStore) TableName() string { return "Stores" }
+
+type coreInstrument struct {
+	ID             uint               `gorm:"primaryKey"`
+	Name           string             `gorm:"not null"`
+	Type           uint               `gorm:"not null;index"`
+	InstrumentType coreInstrumentType `gorm:"foreignKey:Type;references:ID;constraint:OnUpdate:CASCADE,OnDelete:RESTRICT;"`
+	SerialNumber   string             `gorm:"unique;not null"`
+	Location       string
+	Status         string
+	StoreID        *uint               `gorm:"index"`
+	Store          *coreInventoryStore `gorm:"constraint:OnUpdate:CASCADE,OnDelete:SET NULL;"`
+	CreatedAt      time.Time
+	UpdatedAt      time.Time `gorm:"autoUpdateTime"`
+}
+
+func (coreInstrument) TableName() string { return "Instruments" }
+
+type coreInspectionRecord struct {
+	ID            uint            `gorm:"primaryKey"`
+	StationID     uint            `gorm:"index:idx_insp_forms_station_id"`
+	Station       *coreStation    `gorm:"constraint:OnUpdate:CASCADE,OnDelete:RESTRICT;"`
+	InstrumentID  *uint           `gorm:"index"`
+	Instrument    *coreInstrument `gorm:"constraint:OnUpdate:CASCADE,OnDelete:SET NULL;"`
+	SubmittedByID *uint           `gorm:"index:idx_insp_forms_submitter"`
+	SubmittedBy   *coreUser       `gorm:"constraint:OnUpdate:CASCADE,OnDelete:SET NULL;"`
+	VisitDate     time.Time       `gorm:"index:idx_insp_forms_visit_date"`
+	Status        string          `gorm:"size:20;default:'submitted';index:idx_insp_forms_status"`
+	Title         string          `gorm:"size:200"`
+	Remarks       string
+	Data          json.RawMessage
+	Deleted       string    `gorm:"size:3;default:'No'"`
+	CreatedAt     time.Time `gorm:"autoCreateTime"`
+	UpdatedAt     time.Time `gorm:"autoUpdateTime"`
+}
+
+func (coreInspectionRecord) TableName() string { return "InspectionForms" }
+
+type coreInspectionFormAttachment struct {
+	ID               uint                  `gorm:"primaryKey"`
+	InspectionFormID uint                  `gorm:"index"`
+	InspectionForm   *coreInspectionRecord `gorm:"constraint:OnUpdate:CASCADE,OnDelete:CASCADE;"`
+	FileName         string
+	FilePath         string
+	ContentType      string
+	Size             int64
+	UploadedAt       time.Time `gorm:"autoCreateTime"`
+}
+
+func (coreInspectionFormAttachment) TableName() string { return "InspectionFormAttachments" }
+
+type coreMaintenanceNotice struct {
+	ID            uint   `gorm:"primaryKey"`
+	Title         string `gorm:"size:200;not null"`
+	Body          string
+	Severity      string     `gorm:"size:10;default:'info';index"`
+	Status        string     `gorm:"size:10;default:'published';index"`
+	Scope         string     `gorm:"size:10;default:'global';index"`
+	StationID     *uint      `gorm:"index"`
+	InstrumentID  *uint      `gorm:"index"`
+	EffectiveFrom *time.Time `gorm:"index"`
+	EffectiveTo   *time.Time `gorm:"index"`
+	Deleted       string     `gorm:"size:3;default:'No'"`
+	CreatedByID   *uint      `gorm:"index"`
+	CreatedAt     time.Time  `gorm:"autoCreateTime"`
+	UpdatedAt     time.Time  `gorm:"autoUpdateTime"`
+}
+
+func (coreMaintenanceNotice) TableName() string { return "MaintenanceNotices" }
+
+type coreStationPhoto struct {
+	ID          uint      `gorm:"primaryKey"`
+	STNID       uint      `gorm:"not null;index"`
+	Filename    string    `gorm:"size:255;not null"`
+	ContentType string    `gorm:"size:100;not null"`
+	Size        int64     `gorm:"not null"`
+	Data        []byte    `gorm:"not null"`
+	CreatedAt   time.Time `gorm:"autoCreateTime"`
+	UpdatedAt   time.Time `gorm:"autoUpdateTime"`
+}
+
+func (coreStationPhoto) TableName() string { return "StationPhotos" }
+
+type coreInstrumentPhoto struct {
+	ID           uint      `gorm:"primaryKey"`
+	InstrumentID uint      `gorm:"not null;index"`
+	Filename     string    `gorm:"size:255;not null"`
+	ContentType  string    `gorm:"size:100;not null"`
+	Size         int64     `gorm:"not null"`
+	Data         []byte    `gorm:"not null"`
+	CreatedAt    time.Time `gorm:"autoCreateTime"`
+	UpdatedAt    time.Time `gorm:"autoUpdateTime"`
+}
+
+func (coreInstrumentPhoto) TableName() string { return "InstrumentPhotos" }
+
+type coreUserAvatar struct {
+	UserID      uint `gorm:"primaryKey;index"`
+	Data        []byte
+	ContentType string `gorm:"size:100"`
+	Size        int64
+	UpdatedAt   time.Time `gorm:"autoUpdateTime"`
+	CreatedAt   time.Time `gorm:"autoCreateTime"`
+}
+
+func (coreUserAvatar) TableName() string { return "UserAvatars" }
+
+type coreMaintNoticeTemplate struct {
+	ID          uint   `gorm:"primaryKey"`
+	Name        string `gorm:"size:150;uniqueIndex;not null"`
+	Label       string `gorm:"size:200;not null"`
+	Description string
+	Engine      string `gorm:"size:10;default:'html'"`
+	Content     string
+	Active      bool      `gorm:"default:true;index"`
+	Deleted     string    `gorm:"size:3;default:'No';index"`
+	Version     int       `gorm:"default:1"`
+	CreatedByID *uint     `gorm:"index"`
+	UpdatedByID *uint     `gorm:"index"`
+	CreatedAt   time.Time `gorm:"autoCreateTime"`
+	UpdatedAt   time.Time `gorm:"autoUpdateTime"`
+}
+
+func (coreMaintNoticeTemplate) TableName() string { return "MaintNoticeTemplates" }
+
+// coreTables are listed in dependency order; they are dropped in reverse
+var coreTables = []interface{}{
+	&coreConfiguration{},
+	&coreUser{},
+	&coreStationType{},
+	&coreStation{},
+	&coreAuditLog{},
+	&coreInstrumentType{},
+	&coreInventoryStore{},
+	&coreInstrument{},
+	&coreInspectionRecord{},
+	&coreInspectionFormAttachment{},
+	&coreMaintenanceNotice{},
+	&coreStationPhoto{},
+	&coreInstrumentPhoto{},
+	&coreUserAvatar{},
+	&coreMaintNoticeTemplate{},
+}
+
+func init() {
+	db.RegisterMigration(db.Migration{
+		Version: "20250101000000_create_core_tables",
+		Up: func(tx *gorm.DB) error {
+			return tx.AutoMigrate(coreTables...)
+		},
+		Down: func(tx *gorm.DB) error {
+			for i := len(coreTables) - 1; i >= 0; i-- {
+				if err := tx.Migrator().DropTable(coreTables[i]); err != nil {
+					return err
+				}
+			}
+			return nil
+		},
+	})
+}
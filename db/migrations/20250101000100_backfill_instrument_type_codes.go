@@ -0,0 +1,25 @@
+package migrations
+
+import (
+	"fmt"
+
+	"backend-meta-data/db"
+	"backend-meta-data/models"
+
+	"gorm.io/gorm"
+)
+
+func init() {
+	db.RegisterMigration(db.Migration{
+		Version: "20250101000100_backfill_instrument_type_codes",
+		// Backfill empty or NULL codes to unique values derived from the name to satisfy the unique constraint
+		Up: func(tx *gorm.DB) error {
+			if _, err := models.BackfillEmptyInstrTypeCodes(tx); err != nil {
+				return fmt.Errorf("backfill instrument type codes failed: %w", err)
+			}
+			return nil
+		},
+		// Data backfill; nothing to undo
+		Down: func(tx *gorm.DB) error { return nil },
+	})
+}
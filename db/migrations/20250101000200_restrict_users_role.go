@@ -0,0 +1,31 @@
+package migrations
+
+import (
+	"backend-meta-data/db"
+	"backend-meta-data/models"
+
+	"gorm.io/gorm"
+)
+
+// usersRoleCheck is declared on models.User.Role; the core Users table is
+// created without it, so it is added here
+const usersRoleCheck = "chk_users_role"
+
+func init() {
+	db.RegisterMigration(db.Migration{
+		Version: "20250101000200_restrict_users_role",
+		// Restrict Users.role to the allowed roles with a CHECK constraint (portable replacement for the MySQL ENUM)
+		Up: func(tx *gorm.DB) error {
+			if tx.Migrator().HasConstraint(&models.User{}, usersRoleCheck) {
+				return nil
+			}
+			return tx.Migrator().CreateConstraint(&models.User{}, usersRoleCheck)
+		},
+		Down: func(tx *gorm.DB) error {
+			if !tx.Migrator().HasConstraint(&models.User{}, usersRoleCheck) {
+				return nil
+			}
+			return tx.Migrator().DropConstraint(&models.User{}, usersRoleCheck)
+		},
+	})
+}
@@ -0,0 +1,20 @@
+package migrations
+
+import (
+	"backend-meta-data/db"
+	"backend-meta-data/models"
+
+	"gorm.io/gorm"
+)
+
+func init() {
+	db.RegisterMigration(db.Migration{
+		Version: "20250101000300_create_maint_notice_emails_table",
+		Up: func(tx *gorm.DB) error {
+			return tx.AutoMigrate(&models.MaintNoticeEmail{})
+		},
+		Down: func(tx *gorm.DB) error {
+			return tx.Migrator().DropTable(&models.MaintNoticeEmail{})
+		},
+	})
+}
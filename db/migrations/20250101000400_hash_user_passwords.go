@@ -0,0 +1,25 @@
+package migrations
+
+import (
+	"fmt"
+
+	"backend-meta-data/db"
+	"backend-meta-data/models"
+
+	"gorm.io/gorm"
+)
+
+func init() {
+	db.RegisterMigration(db.Migration{
+		Version: "20250101000400_hash_user_passwords",
+		// Hash passwords stored as plaintext before hashing was introduced
+		Up: func(tx *gorm.DB) error {
+			if _, err := models.HashPlaintextPasswords(tx); err != nil {
+				return fmt.Errorf("hash user passwords failed: %w", err)
+			}
+			return nil
+		},
+		// Hashes cannot be reversed; nothing to undo
+		Down: func(tx *gorm.DB) error { return nil },
+	})
+}
@@ -0,0 +1,20 @@
+package migrations
+
+import (
+	"backend-meta-data/db"
+	"backend-meta-data/models"
+
+	"gorm.io/gorm"
+)
+
+func init() {
+	db.RegisterMigration(db.Migration{
+		Version: "20250101000500_create_user_activity_logs_table",
+		Up: func(tx *gorm.DB) error {
+			return tx.AutoMigrate(&models.UserActivityLog{})
+		},
+		Down: func(tx *gorm.DB) error {
+			return tx.Migrator().DropTable(&models.UserActivityLog{})
+		},
+	})
+}
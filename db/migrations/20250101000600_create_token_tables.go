@@ -0,0 +1,20 @@
+package migrations
+
+import (
+	"backend-meta-data/db"
+	"backend-meta-data/models"
+
+	"gorm.io/gorm"
+)
+
+func init() {
+	db.RegisterMigration(db.Migration{
+		Version: "20250101000600_create_token_tables",
+		Up: func(tx *gorm.DB) error {
+			return tx.AutoMigrate(&models.RefreshToken{}, &models.RevokedToken{})
+		},
+		Down: func(tx *gorm.DB) error {
+			return tx.Migrator().DropTable(&models.RevokedToken{}, &models.RefreshToken{})
+		},
+	})
+}
@@ -0,0 +1,41 @@
+package migrations
+
+import (
+	"backend-meta-data/db"
+	"backend-meta-data/models"
+
+	"gorm.io/gorm"
+)
+
+// usersDirectoryFields are declared on models.User; tables created before they
+// existed get them here
+var usersDirectoryFields = []string{"DisplayName", "Source"}
+
+func init() {
+	db.RegisterMigration(db.Migration{
+		Version: "20250101000700_add_directory_fields_to_users",
+		// Display name and login source mapped from LDAP / OIDC
+		Up: func(tx *gorm.DB) error {
+			for _, f := range usersDirectoryFields {
+				if tx.Migrator().HasColumn(&models.User{}, f) {
+					continue
+				}
+				if err := tx.Migrator().AddColumn(&models.User{}, f); err != nil {
+					return err
+				}
+			}
+			return nil
+		},
+		Down: func(tx *gorm.DB) error {
+			for _, f := range usersDirectoryFields {
+				if !tx.Migrator().HasColumn(&models.User{}, f) {
+					continue
+				}
+				if err := tx.Migrator().DropColumn(&models.User{}, f); err != nil {
+					return err
+				}
+			}
+			return nil
+		},
+	})
+}
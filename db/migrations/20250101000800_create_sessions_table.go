@@ -0,0 +1,32 @@
+package migrations
+
+import (
+	"backend-meta-data/db"
+	"backend-meta-data/models"
+
+	"gorm.io/gorm"
+)
+
+func init() {
+	db.RegisterMigration(db.Migration{
+		Version: "20250101000800_create_sessions_table",
+		// Login sessions shared by every instance, and the "log out everywhere" marker on Users
+		Up: func(tx *gorm.DB) error {
+			if err := tx.AutoMigrate(&models.Session{}); err != nil {
+				return err
+			}
+			if tx.Migrator().HasColumn(&models.User{}, "SessionsRevokedAt") {
+				return nil
+			}
+			return tx.Migrator().AddColumn(&models.User{}, "SessionsRevokedAt")
+		},
+		Down: func(tx *gorm.DB) error {
+			if tx.Migrator().HasColumn(&models.User{}, "SessionsRevokedAt") {
+				if err := tx.Migrator().DropColumn(&models.User{}, "SessionsRevokedAt"); err != nil {
+					return err
+				}
+			}
+			return tx.Migrator().DropTable(&models.Session{})
+		},
+	})
+}
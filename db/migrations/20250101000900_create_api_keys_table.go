@@ -0,0 +1,20 @@
+package migrations
+
+import (
+	"backend-meta-data/db"
+	"backend-meta-data/models"
+
+	"gorm.io/gorm"
+)
+
+func init() {
+	db.RegisterMigration(db.Migration{
+		Version: "20250101000900_create_api_keys_table",
+		Up: func(tx *gorm.DB) error {
+			return tx.AutoMigrate(&models.APIKey{})
+		},
+		Down: func(tx *gorm.DB) error {
+			return tx.Migrator().DropTable(&models.APIKey{})
+		},
+	})
+}
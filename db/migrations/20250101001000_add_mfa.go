@@ -0,0 +1,44 @@
+package migrations
+
+import (
+	"backend-meta-data/db"
+	"backend-meta-data/models"
+
+	"gorm.io/gorm"
+)
+
+// usersMFAFields are declared on models.User; tables created before they
+// existed get them here
+var usersMFAFields = []string{"MFASecret", "MFAEnabled", "MFALastStep"}
+
+func init() {
+	db.RegisterMigration(db.Migration{
+		Version: "20250101001000_add_mfa",
+		// TOTP secret and state on Users, and hashed recovery codes
+		Up: func(tx *gorm.DB) error {
+			for _, f := range usersMFAFields {
+				if tx.Migrator().HasColumn(&models.User{}, f) {
+					continue
+				}
+				if err := tx.Migrator().AddColumn(&models.User{}, f); err != nil {
+					return err
+				}
+			}
+			return tx.AutoMigrate(&models.MFARecoveryCode{})
+		},
+		Down: func(tx *gorm.DB) error {
+			if err := tx.Migrator().DropTable(&models.MFARecoveryCode{}); err != nil {
+				return err
+			}
+			for _, f := range usersMFAFields {
+				if !tx.Migrator().HasColumn(&models.User{}, f) {
+					continue
+				}
+				if err := tx.Migrator().DropColumn(&models.User{}, f); err != nil {
+					return err
+				}
+			}
+			return nil
+		},
+	})
+}
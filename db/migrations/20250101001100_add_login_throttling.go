@@ -0,0 +1,32 @@
+package migrations
+
+import (
+	"backend-meta-data/db"
+	"backend-meta-data/models"
+
+	"gorm.io/gorm"
+)
+
+func init() {
+	db.RegisterMigration(db.Migration{
+		Version: "20250101001100_add_login_throttling",
+		// Failed login counters and the temporary lock on Users
+		Up: func(tx *gorm.DB) error {
+			if err := tx.AutoMigrate(&models.LoginThrottle{}); err != nil {
+				return err
+			}
+			if tx.Migrator().HasColumn(&models.User{}, "LockedUntil") {
+				return nil
+			}
+			return tx.Migrator().AddColumn(&models.User{}, "LockedUntil")
+		},
+		Down: func(tx *gorm.DB) error {
+			if tx.Migrator().HasColumn(&models.User{}, "LockedUntil") {
+				if err := tx.Migrator().DropColumn(&models.User{}, "LockedUntil"); err != nil {
+					return err
+				}
+			}
+			return tx.Migrator().DropTable(&models.LoginThrottle{})
+		},
+	})
+}
@@ -0,0 +1,26 @@
+package migrations
+
+import (
+	"backend-meta-data/db"
+	"backend-meta-data/models"
+
+	"gorm.io/gorm"
+)
+
+func init() {
+	db.RegisterMigration(db.Migration{
+		Version: "20250101001200_add_email_verification",
+		Up: func(tx *gorm.DB) error {
+			if tx.Migrator().HasColumn(&models.User{}, "EmailVerifiedAt") {
+				return nil
+			}
+			return tx.Migrator().AddColumn(&models.User{}, "EmailVerifiedAt")
+		},
+		Down: func(tx *gorm.DB) error {
+			if !tx.Migrator().HasColumn(&models.User{}, "EmailVerifiedAt") {
+				return nil
+			}
+			return tx.Migrator().DropColumn(&models.User{}, "EmailVerifiedAt")
+		},
+	})
+}
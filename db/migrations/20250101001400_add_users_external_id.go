@@ -0,0 +1,38 @@
+package migrations
+
+import (
+	"backend-meta-data/db"
+	"backend-meta-data/models"
+
+	"gorm.io/gorm"
+)
+
+func init() {
+	db.RegisterMigration(db.Migration{
+		Version: "20250101001400_add_users_external_id",
+		Up: func(tx *gorm.DB) error {
+			m := tx.Migrator()
+			if !m.HasColumn(&models.User{}, "ExternalID") {
+				if err := m.AddColumn(&models.User{}, "ExternalID"); err != nil {
+					return err
+				}
+			}
+			if m.HasIndex(&models.User{}, "ExternalID") {
+				return nil
+			}
+			return m.CreateIndex(&models.User{}, "ExternalID")
+		},
+		Down: func(tx *gorm.DB) error {
+			m := tx.Migrator()
+			if m.HasIndex(&models.User{}, "ExternalID") {
+				if err := m.DropIndex(&models.User{}, "ExternalID"); err != nil {
+					return err
+				}
+			}
+			if !m.HasColumn(&models.User{}, "ExternalID") {
+				return nil
+			}
+			return m.DropColumn(&models.User{}, "ExternalID")
+		},
+	})
+}
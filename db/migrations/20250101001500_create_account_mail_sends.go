@@ -0,0 +1,28 @@
+package migrations
+
+import (
+	"backend-meta-data/db"
+	"backend-meta-data/models"
+
+	"gorm.io/gorm"
+)
+
+func init() {
+	db.RegisterMigration(db.Migration{
+		Version: "20250101001500_create_account_mail_sends",
+		// Account email spacing moves out of LoginThrottles, where its
+		// "mail:<purpose>:<id>" rows showed up as login throttles
+		Up: func(tx *gorm.DB) error {
+			if err := tx.AutoMigrate(&models.AccountMailSend{}); err != nil {
+				return err
+			}
+			if !tx.Migrator().HasTable(&models.LoginThrottle{}) {
+				return nil
+			}
+			return tx.Where("throttle_key LIKE ?", "mail:%").Delete(&models.LoginThrottle{}).Error
+		},
+		Down: func(tx *gorm.DB) error {
+			return tx.Migrator().DropTable(&models.AccountMailSend{})
+		},
+	})
+}
@@ -0,0 +1,6 @@
+// Package migrations holds the versioned schema migrations. Each file
+// registers one migration with db.RegisterMigration from init(); import the
+// package for its side effects wherever migrations are run.
+//
+// Create a new migration with `fibernova make:migration <name>`.
+package migrations
@@ -0,0 +1,189 @@
+package db
+
+import (
+	"fmt"
+	"sort"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Migration is a versioned, reversible schema change. Versions start with a
+// UTC timestamp (YYYYMMDDHHMMSS_name) so they sort in the order they were written.
+type Migration struct {
+	Version string
+	Up      func(tx *gorm.DB) error
+	Down    func(tx *gorm.DB) error
+}
+
+// SchemaMigration records an applied migration
+// TableName: schema_migrations
+type SchemaMigration struct {
+	Version   string    `gorm:"primaryKey;size:191" json:"version"`
+	Batch     int       `gorm:"not null;index" json:"batch"`
+	AppliedAt time.Time `gorm:"not null" json:"applied_at"`
+}
+
+func (SchemaMigration) TableName() string { return "schema_migrations" }
+
+// MigrationState describes one migration for migrate:status
+type MigrationState struct {
+	Version   string
+	Applied   bool
+	Batch     int
+	AppliedAt *time.Time
+	// Missing is true when the version is recorded in schema_migrations but no longer registered
+	Missing bool
+}
+
+var migrations = map[string]Migration{}
+
+// RegisterMigration adds a migration to the registry; migration files call it from init()
+func RegisterMigration(m Migration) {
+	if m.Version == "" || m.Up == nil {
+		panic("db: migration requires a version and an Up func")
+	}
+	if _, dup := migrations[m.Version]; dup {
+		panic(fmt.Sprintf("db: duplicate migration %s", m.Version))
+	}
+	migrations[m.Version] = m
+}
+
+// Migrations returns all registered migrations ordered by version
+func Migrations() []Migration {
+	out := make([]Migration, 0, len(migrations))
+	for _, m := range migrations {
+		out = append(out, m)
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Version < out[j].Version })
+	return out
+}
+
+func ensureMigrationTable(db *gorm.DB) error {
+	return db.AutoMigrate(&SchemaMigration{})
+}
+
+func appliedMigrations(db *gorm.DB) ([]SchemaMigration, error) {
+	var rows []SchemaMigration
+	if err := db.Order("batch ASC, version ASC").Find(&rows).Error; err != nil {
+		return nil, err
+	}
+	return rows, nil
+}
+
+// Migrate applies all pending migrations in version order as a single batch
+// and returns the versions that were applied
+func Migrate(db *gorm.DB) ([]string, error) {
+	if err := ensureMigrationTable(db); err != nil {
+		return nil, err
+	}
+	applied, err := appliedMigrations(db)
+	if err != nil {
+		return nil, err
+	}
+	done := make(map[string]bool, len(applied))
+	batch := 0
+	for _, a := range applied {
+		done[a.Version] = true
+		if a.Batch > batch {
+			batch = a.Batch
+		}
+	}
+	batch++
+
+	var ran []string
+	for _, m := range Migrations() {
+		if done[m.Version] {
+			continue
+		}
+		err := db.Transaction(func(tx *gorm.DB) error {
+			if err := m.Up(tx); err != nil {
+				return err
+			}
+			return tx.Create(&SchemaMigration{Version: m.Version, Batch: batch, AppliedAt: time.Now()}).Error
+		})
+		if err != nil {
+			return ran, fmt.Errorf("migration %s failed: %w", m.Version, err)
+		}
+		ran = append(ran, m.Version)
+	}
+	return ran, nil
+}
+
+// Rollback reverts applied migrations in reverse order. With steps <= 0 the
+// last batch is rolled back; otherwise the last steps migrations are.
+func Rollback(db *gorm.DB, steps int) ([]string, error) {
+	if err := ensureMigrationTable(db); err != nil {
+		return nil, err
+	}
+	applied, err := appliedMigrations(db)
+	if err != nil {
+		return nil, err
+	}
+	if len(applied) == 0 {
+		return nil, nil
+	}
+	var targets []SchemaMigration
+	if steps <= 0 {
+		last := applied[len(applied)-1].Batch
+		for i := len(applied) - 1; i >= 0 && applied[i].Batch == last; i-- {
+			targets = append(targets, applied[i])
+		}
+	} else {
+		for i := len(applied) - 1; i >= 0 && len(targets) < steps; i-- {
+			targets = append(targets, applied[i])
+		}
+	}
+
+	var reverted []string
+	for _, t := range targets {
+		m, ok := migrations[t.Version]
+		if !ok {
+			return reverted, fmt.Errorf("migration %s is applied but not registered", t.Version)
+		}
+		err := db.Transaction(func(tx *gorm.DB) error {
+			if m.Down != nil {
+				if err := m.Down(tx); err != nil {
+					return err
+				}
+			}
+			return tx.Delete(&SchemaMigration{}, "version = ?", t.Version).Error
+		})
+		if err != nil {
+			return reverted, fmt.Errorf("rollback %s failed: %w", t.Version, err)
+		}
+		reverted = append(reverted, t.Version)
+	}
+	return reverted, nil
+}
+
+// MigrationStatus reports every registered or recorded migration in version order
+func MigrationStatus(db *gorm.DB) ([]MigrationState, error) {
+	if err := ensureMigrationTable(db); err != nil {
+		return nil, err
+	}
+	applied, err := appliedMigrations(db)
+	if err != nil {
+		return nil, err
+	}
+	byVersion := make(map[string]SchemaMigration, len(applied))
+	for _, a := range applied {
+		byVersion[a.Version] = a
+	}
+	var out []MigrationState
+	for _, m := range Migrations() {
+		st := MigrationState{Version: m.Version}
+		if a, ok := byVersion[m.Version]; ok {
+			at := a.AppliedAt
+			st.Applied, st.Batch, st.AppliedAt = true, a.Batch, &at
+			delete(byVersion, m.Version)
+		}
+		out = append(out, st)
+	}
+	for _, a := range byVersion {
+		at := a.AppliedAt
+		out = append(out, MigrationState{Version: a.Version, Applied: true, Batch: a.Batch, AppliedAt: &at, Missing: true})
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Version < out[j].Version })
+	return out, nil
+}
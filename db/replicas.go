@@ -0,0 +1,64 @@
+package db
+
+import (
+	"backend-meta-data/config"
+
+	"gorm.io/gorm"
+	"gorm.io/plugin/dbresolver"
+)
+
+// ReplicaResolver names the dbresolver configuration for [[db.replicas]]. It
+// is registered as a named (not global) resolver, so queries use the primary
+// unless they opt in with Replica.
+const ReplicaResolver = "replicas"
+
+// useReplicas registers cfg.Replicas on db; writes through the resolver still go to the primary
+func useReplicas(db *gorm.DB, cfg *config.DBConfig) error {
+	if len(cfg.Replicas) == 0 {
+		return nil
+	}
+	var replicas []gorm.Dialector
+	for _, r := range cfg.Replicas {
+		rc := *cfg
+		if r.Host != "" {
+			rc.Host = r.Host
+		}
+		if r.Port != 0 {
+			rc.Port = r.Port
+		}
+		if r.User != "" {
+			rc.User = r.User
+		}
+		if r.Password != "" {
+			rc.Password = r.Password
+		}
+		if r.Name != "" {
+			rc.Name = r.Name
+		}
+		d, err := Dialector(&rc)
+		if err != nil {
+			return err
+		}
+		replicas = append(replicas, d)
+	}
+	resolver := dbresolver.Register(dbresolver.Config{Replicas: replicas}, ReplicaResolver)
+	if cfg.MaxOpenConns > 0 {
+		resolver.SetMaxOpenConns(cfg.MaxOpenConns)
+	}
+	if cfg.MaxIdleConns > 0 {
+		resolver.SetMaxIdleConns(cfg.MaxIdleConns)
+	}
+	if cfg.ConnMaxLifetime > 0 {
+		resolver.SetConnMaxLifetime(cfg.ConnMaxLifetime)
+	}
+	if cfg.ConnMaxIdleTime > 0 {
+		resolver.SetConnMaxIdleTime(cfg.ConnMaxIdleTime)
+	}
+	return db.Use(resolver)
+}
+
+// Replica returns db with reads routed to the configured replicas. Without
+// replicas it is equivalent to db.
+func Replica(db *gorm.DB) *gorm.DB {
+	return db.Clauses(dbresolver.Use(ReplicaResolver))
+}
@@ -0,0 +1,67 @@
+package db
+
+import (
+	"fmt"
+	"strings"
+
+	"gorm.io/gorm"
+)
+
+// Seeder brings part of the database to a known state. Run must be
+// idempotent: running a seeder twice leaves the same data as running it once.
+type Seeder interface {
+	Name() string
+	Run(db *gorm.DB) error
+}
+
+var seeders []Seeder
+
+// RegisterSeeder adds a seeder; seeders run in registration order
+func RegisterSeeder(s Seeder) {
+	for _, existing := range seeders {
+		if existing.Name() == s.Name() {
+			panic(fmt.Sprintf("db: duplicate seeder %s", s.Name()))
+		}
+	}
+	seeders = append(seeders, s)
+}
+
+// Seeders returns the registered seeders in run order
+func Seeders() []Seeder {
+	return append([]Seeder(nil), seeders...)
+}
+
+// FindSeeder looks up a seeder by name; the "Seeder" suffix and case are optional
+func FindSeeder(name string) (Seeder, bool) {
+	want := strings.TrimSuffix(strings.ToLower(name), "seeder")
+	for _, s := range seeders {
+		if strings.TrimSuffix(strings.ToLower(s.Name()), "seeder") == want {
+			return s, true
+		}
+	}
+	return nil, false
+}
+
+// Seed runs the named seeders (all of them when names is empty) and returns
+// the names that completed
+func Seed(db *gorm.DB, names ...string) ([]string, error) {
+	run := seeders
+	if len(names) > 0 {
+		run = nil
+		for _, n := range names {
+			s, ok := FindSeeder(n)
+			if !ok {
+				return nil, fmt.Errorf("unknown seeder %q", n)
+			}
+			run = append(run, s)
+		}
+	}
+	var done []string
+	for _, s := range run {
+		if err := s.Run(db); err != nil {
+			return done, fmt.Errorf("seeder %s failed: %w", s.Name(), err)
+		}
+		done = append(done, s.Name())
+	}
+	return done, nil
+}
@@ -0,0 +1,50 @@
+package seeders
+
+import (
+	"backend-meta-data/middleware"
+
+	"gorm.io/gorm"
+)
+
+// basePolicies are the role policies every environment starts with
+var basePolicies = [][]string{
+	// Root: full access
+	{"Root", "/api/*", "(GET|POST|PUT|PATCH|DELETE|OPTIONS)"},
+	// Admin: manage core resources
+	{"Admin", "/api/users*", "(GET|POST|PUT|PATCH|DELETE)"},
+	{"Admin", "/api/instrument-types*", "(GET|POST|PUT|PATCH|DELETE)"},
+	{"Admin", "/api/*", "GET"},
+	// Inspector: read the inspection resources and submit inspection forms.
+	// No /api/* rule: it would open the admin reads (settings, API keys,
+	// login throttles) too.
+	{"Inspector", "/api/inspection-forms*", "(GET|POST)"},
+	{"Inspector", "/api/instruments*", "GET"},
+	{"Inspector", "/api/instrument-types*", "GET"},
+	{"Inspector", "/api/station*", "GET"},
+	{"Inspector", "/api/stores*", "GET"},
+	{"Inspector", "/api/maint-notices*", "GET"},
+	{"Inspector", "/api/templates*", "GET"},
+	// All authenticated users can update their own profile
+	{"*", "/api/users/profile", "PATCH"},
+}
+
+// retiredPolicies are removed from databases seeded before they were dropped
+var retiredPolicies = [][]string{
+	{"Inspector", "/api/*", "GET"},
+}
+
+// CasbinPolicySeeder adds the base Root/Admin/Inspector policies if missing
+// and removes retired ones
+type CasbinPolicySeeder struct{}
+
+func (CasbinPolicySeeder) Name() string { return "CasbinPolicySeeder" }
+
+func (CasbinPolicySeeder) Run(db *gorm.DB) error {
+	if err := middleware.EnsureCasbin(db); err != nil {
+		return err
+	}
+	if err := middleware.AddPolicies(basePolicies); err != nil {
+		return err
+	}
+	return middleware.RemovePolicies(retiredPolicies)
+}
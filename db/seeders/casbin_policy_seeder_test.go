@@ -0,0 +1,70 @@
+package seeders
+
+import (
+	"context"
+	"testing"
+
+	"backend-meta-data/middleware"
+
+	"github.com/glebarez/sqlite"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+// seededEnforcer runs CasbinPolicySeeder on an empty database, first adding
+// the given legacy policies as an older seeder would have
+func seededEnforcer(t *testing.T, legacy ...[]string) {
+	t.Helper()
+	// InitCasbin loads config/casbin_model.conf from the project root
+	t.Chdir("../..")
+	db, err := gorm.Open(sqlite.Open("file::memory:"), &gorm.Config{Logger: logger.Discard})
+	if err != nil {
+		t.Fatal(err)
+	}
+	sqlDB, err := db.DB()
+	if err != nil {
+		t.Fatal(err)
+	}
+	sqlDB.SetMaxOpenConns(1)
+	if err := middleware.InitCasbin(db); err != nil {
+		t.Fatal(err)
+	}
+	if err := middleware.AddPolicies(legacy); err != nil {
+		t.Fatal(err)
+	}
+	if err := (CasbinPolicySeeder{}).Run(db); err != nil {
+		t.Fatal(err)
+	}
+	for user, role := range map[string]string{"ivy": "Inspector", "adam": "Admin", "root": "Root"} {
+		if err := middleware.AssignRole(context.Background(), user, role); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestAdminReadsAreClosedToInspectors(t *testing.T) {
+	seededEnforcer(t, []string{"Inspector", "/api/*", "GET"})
+	for _, tc := range []struct {
+		path, method string
+		inspector    bool
+		admin        bool
+	}{
+		{"/api/settings", "GET", false, true},
+		{"/api/settings/smtp.from_name", "GET", false, true},
+		{"/api/users/3/api-keys", "GET", false, true},
+		{"/api/login-throttles", "GET", false, true},
+		{"/api/inspection-forms", "GET", true, true},
+		{"/api/inspection-forms", "POST", true, false},
+		{"/api/instruments", "GET", true, true},
+	} {
+		for user, want := range map[string]bool{"ivy": tc.inspector, "adam": tc.admin, "root": true} {
+			ok, err := middleware.Enforcer.Enforce(user, tc.path, tc.method)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if ok != want {
+				t.Errorf("%s %s %s: allowed=%v, want %v", user, tc.method, tc.path, ok, want)
+			}
+		}
+	}
+}
@@ -0,0 +1,30 @@
+package seeders
+
+import (
+	"backend-meta-data/models"
+
+	"gorm.io/gorm"
+)
+
+var defaultInstrumentTypes = []models.InstrumentType{
+	{Code: "THERMOMETER", Name: "Thermometer", Description: "Air temperature sensor"},
+	{Code: "HYGROMETER", Name: "Hygrometer", Description: "Relative humidity sensor"},
+	{Code: "BAROMETER", Name: "Barometer", Description: "Atmospheric pressure sensor"},
+	{Code: "ANEMOMETER", Name: "Anemometer", Description: "Wind speed and direction sensor"},
+	{Code: "RAIN-GAUGE", Name: "Rain Gauge", Description: "Rainfall accumulation sensor"},
+}
+
+// InstrumentTypeSeeder creates the default instrument types, leaving existing codes untouched
+type InstrumentTypeSeeder struct{}
+
+func (InstrumentTypeSeeder) Name() string { return "InstrumentTypeSeeder" }
+
+func (InstrumentTypeSeeder) Run(db *gorm.DB) error {
+	for _, it := range defaultInstrumentTypes {
+		row := it
+		if err := db.Where(models.InstrumentType{Code: row.Code}).Attrs(row).FirstOrCreate(&row).Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
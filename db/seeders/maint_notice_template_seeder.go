@@ -0,0 +1,28 @@
+package seeders
+
+import (
+	"backend-meta-data/models"
+
+	"gorm.io/gorm"
+)
+
+// templateDirs are searched for maintenance notice templates, relative to the working directory
+var templateDirs = []string{"./src/backend/email_templates/maint_notice", "./backend/email_templates/maint_notice", "./email_templates/maint_notice"}
+
+// MaintNoticeTemplateSeeder loads maintenance notice templates from the filesystem if the table is empty
+type MaintNoticeTemplateSeeder struct{}
+
+func (MaintNoticeTemplateSeeder) Name() string { return "MaintNoticeTemplateSeeder" }
+
+func (MaintNoticeTemplateSeeder) Run(db *gorm.DB) error {
+	var count int64
+	if err := db.Model(&models.MaintNoticeTemplate{}).Count(&count).Error; err != nil || count > 0 {
+		return err
+	}
+	for _, d := range templateDirs {
+		if err := models.SeedMaintNoticeTemplatesFromFS(db, d); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,64 @@
+package seeders
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"fmt"
+	"os"
+
+	"backend-meta-data/middleware"
+	"backend-meta-data/models"
+
+	"gorm.io/gorm"
+)
+
+// RootUserSeeder creates the initial Root account when no Root user exists.
+// The username and password come from FIBERNOVA_ROOT_USERNAME (default "root")
+// and FIBERNOVA_ROOT_PASSWORD; a random password is generated and printed if unset.
+type RootUserSeeder struct{}
+
+func (RootUserSeeder) Name() string { return "RootUserSeeder" }
+
+func (RootUserSeeder) Run(db *gorm.DB) error {
+	if err := middleware.EnsureCasbin(db); err != nil {
+		return err
+	}
+	var roots []models.User
+	if err := db.Where("role = ?", "Root").Find(&roots).Error; err != nil {
+		return err
+	}
+	if len(roots) > 0 {
+		// Re-link existing Root accounts whose Casbin grouping is missing
+		for _, u := range roots {
+			if err := middleware.AssignRole(context.Background(), u.Username, u.Role); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	username := os.Getenv("FIBERNOVA_ROOT_USERNAME")
+	if username == "" {
+		username = "root"
+	}
+	password := os.Getenv("FIBERNOVA_ROOT_PASSWORD")
+	generated := password == ""
+	if generated {
+		b := make([]byte, 18)
+		if _, err := rand.Read(b); err != nil {
+			return err
+		}
+		password = base64.RawURLEncoding.EncodeToString(b)
+	}
+	u := models.User{Username: username, Password: password, Role: "Root", Active: true, Deleted: "No"}
+	if err := models.CreateUser(db, &u); err != nil {
+		return err
+	}
+	if err := middleware.AssignRole(context.Background(), u.Username, u.Role); err != nil {
+		return err
+	}
+	if generated {
+		fmt.Printf("Created Root user %q with password %s (change it after first login)\n", username, password)
+	}
+	return nil
+}
@@ -0,0 +1,14 @@
+// Package seeders holds the idempotent database seeders run by
+// `fibernova db:seed`. Import the package for its side effects.
+package seeders
+
+import "backend-meta-data/db"
+
+func init() {
+	// Order matters: roles and policies exist before users are assigned to them
+	db.RegisterSeeder(CasbinPolicySeeder{})
+	db.RegisterSeeder(RootUserSeeder{})
+	db.RegisterSeeder(StationTypeSeeder{})
+	db.RegisterSeeder(InstrumentTypeSeeder{})
+	db.RegisterSeeder(MaintNoticeTemplateSeeder{})
+}
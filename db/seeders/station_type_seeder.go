@@ -0,0 +1,28 @@
+package seeders
+
+import (
+	"backend-meta-data/models"
+
+	"gorm.io/gorm"
+)
+
+var defaultStationTypes = []models.StationType{
+	{Code: "AWS", Name: "Automatic Weather Station", Description: "Unattended station reporting surface observations"},
+	{Code: "RAIN", Name: "Rain Gauge Station", Description: "Station measuring rainfall only"},
+	{Code: "MANUAL", Name: "Manual Observation Station", Description: "Station staffed by observers"},
+}
+
+// StationTypeSeeder creates the default station types, leaving existing codes untouched
+type StationTypeSeeder struct{}
+
+func (StationTypeSeeder) Name() string { return "StationTypeSeeder" }
+
+func (StationTypeSeeder) Run(db *gorm.DB) error {
+	for _, st := range defaultStationTypes {
+		row := st
+		if err := db.Where(models.StationType{Code: row.Code}).Attrs(row).FirstOrCreate(&row).Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -9,13 +9,17 @@ import (
 	"strings"
 	"syscall"
 
+	"backend-meta-data/auth"
+	"backend-meta-data/auth/password"
 	"backend-meta-data/config"
 	"backend-meta-data/db"
+	_ "backend-meta-data/db/migrations"
+	_ "backend-meta-data/db/seeders"
 	"backend-meta-data/middleware"
 	"backend-meta-data/routes"
+	"backend-meta-data/settings"
 
 	"github.com/gofiber/fiber/v2"
-	"github.com/gofiber/fiber/v2/middleware/cors"
 	logrus "github.com/sirupsen/logrus"
 )
 
@@ -28,52 +32,70 @@ func main() {
 
 	logrus.Info("Welcome to the AWS Meta Data backend services.")
 
-	// Load config
-	configPath := "./.env"
-	cfg, err := config.LoadConfig(configPath)
+	// Load config.toml, config.<env>.toml and FIBERNOVA_* overrides
+	cfg, err := config.Load(".")
 	if err != nil {
 		log.Fatalf("Error loading config: %v", err)
 	}
-	// Export AUTO_MIGRATE env for lower-level controls
-	if cfg.App.AutoMigrate {
-		os.Setenv("AUTO_MIGRATE", "true")
-	} else {
-		os.Setenv("AUTO_MIGRATE", "false")
+	logrus.Infof("Loaded config from %s", strings.Join(cfg.Files, ", "))
+	if err := middleware.SetLogLevel(cfg.App.LogLevel); err != nil {
+		log.Fatalf("Error setting log level: %v", err)
 	}
-
-	// Connect to DB
-	dbConn, err := db.ConnectDB(&cfg.DB)
-	if err != nil {
-		log.Fatalf("Error connecting to DB: %v", err)
+	if err := password.SetDefault(cfg.Auth.PasswordParams()); err != nil {
+		log.Fatalf("Error configuring password hashing: %v", err)
 	}
-	defer dbConn.Close()
 
-	// GORM connection for migration
+	// Connect to DB; this pool is shared by every handler, migrations and Casbin
 	gormDB, err := db.ConnectGormDB(&cfg.DB)
 	if err != nil {
-		log.Fatalf("Error connecting to GORM DB: %v", err)
+		log.Fatalf("Error connecting to DB: %v", err)
 	}
-	// Only run migrations if enabled or in local/dev
-	if os.Getenv("AUTO_MIGRATE") != "false" && (cfg.App.Env == "local" || cfg.App.Env == "dev" || cfg.App.Env == "development") {
-		if err := db.InitDBIfNeeded(gormDB); err != nil {
-			log.Fatalf("Error running AutoMigrate: %v", err)
+	if sqlDB, err := gormDB.DB(); err == nil {
+		defer sqlDB.Close()
+	}
+	// Only run migrations if enabled or in local/dev; other environments use `fibernova migrate`
+	if cfg.App.AutoMigrate && cfg.App.IsDevelopment() {
+		ran, err := db.Migrate(gormDB)
+		if err != nil {
+			log.Fatalf("Error running migrations: %v", err)
+		}
+		for _, v := range ran {
+			logrus.Infof("Migrated: %s", v)
+		}
+		// Base policies and templates; the remaining seeders run on demand via `fibernova db:seed`
+		if _, err := db.Seed(gormDB, "CasbinPolicySeeder", "MaintNoticeTemplateSeeder"); err != nil {
+			log.Fatalf("Error running seeders: %v", err)
 		}
 	}
 
-	app := fiber.New()
+	// c.IP() reads proxy_header only on requests from trusted_proxies
+	app := fiber.New(fiber.Config{
+		ProxyHeader:             cfg.App.ProxyHeader,
+		EnableTrustedProxyCheck: true,
+		TrustedProxies:          cfg.App.TrustedProxyList(),
+		EnableIPValidation:      true,
+	})
 
-	// Enable CORS for frontend connection
-	app.Use(cors.New(cors.Config{
-		AllowOrigins:     "http://localhost:3000",
-		AllowHeaders:     "Origin, Content-Type, Accept, Authorization",
-		AllowMethods:     "GET,POST,PUT,PATCH,DELETE,OPTIONS",
-		AllowCredentials: true,
-	}))
+	// Enable CORS for frontend connection; [cors] is re-applied on config reload
+	middleware.SetCORS(cfg.CORS)
+	app.Use(middleware.CORSMiddleware)
 
 	app.Use(middleware.LoggerMiddleware)
+	// Keep reads on the primary for writes and shortly after them (see [[db.replicas]])
+	app.Use(middleware.ReadPrimaryMiddleware(cfg.DB.StickyWindow))
+
+	// Runtime settings: Configurations table over the file config
+	settings.Init(gormDB, cfg)
 
-	// Register routes with both dbConn and gormDB
-	routes.RegisterRoutes(app, dbConn, gormDB)
+	// Login providers, sessions and tokens shared by every auth route and middleware
+	authService, err := auth.Init(gormDB, cfg)
+	if err != nil {
+		log.Fatalf("Error initializing auth: %v", err)
+	}
+	authService.AssignRole = middleware.AssignRole
+	authService.SetRole = middleware.ReplaceRole
+
+	routes.RegisterRoutes(app, gormDB, cfg)
 
 	// Initialize Casbin and attach middleware
 	if err := middleware.InitCasbin(gormDB); err != nil {
@@ -88,6 +110,14 @@ func main() {
 		return c.Next()
 	})
 
+	// Reload config and Casbin on SIGHUP, and on file changes when app.watch_interval > 0
+	rl := newReloader(".", cfg, gormDB)
+	go rl.handleSignals()
+	if cfg.App.WatchInterval > 0 {
+		go rl.watch(cfg.App.WatchInterval)
+	}
+	go rl.pollPolicies(cfg.App.PolicyPollInterval)
+
 	// Signal handling for graceful shutdown
 	quit := make(chan os.Signal, 1)
 	signal.Notify(quit, os.Interrupt, syscall.SIGTERM)
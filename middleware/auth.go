@@ -3,52 +3,46 @@
 package middleware
 
 import (
-	"log"
-	"os"
-	"strings"
+	"errors"
+
+	"backend-meta-data/auth"
+	"backend-meta-data/models"
 
 	"github.com/gofiber/fiber/v2"
-	"github.com/golang-jwt/jwt/v4"
 )
 
-// AuthMiddleware validates JWT Bearer tokens and blocks unauthorized access
-func AuthMiddleware(c *fiber.Ctx) error {
-	authHeader := c.Get("Authorization")
-	if authHeader == "" {
-		log.Printf("Auth failed: No Authorization header")
-		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"error": "Missing Authorization header"})
-	}
-	log.Printf("Received Authorization header: %s", authHeader)
-
-	var tokenString string
-	if strings.HasPrefix(authHeader, "Bearer ") {
-		tokenString = strings.TrimPrefix(authHeader, "Bearer ")
-	} else {
-		// Fallback: treat the whole header as token
-		tokenString = authHeader
-	}
-
-	jwtSecret := os.Getenv("JWT_SECRET")
-	if jwtSecret == "" {
-		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Server JWT secret not configured"})
+// OIDCMiddleware accepts only Bearer tokens from the [oidc] issuer, for APIs
+// called by clients that authenticate there directly
+func OIDCMiddleware(c *fiber.Ctx) error {
+	if auth.Default == nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Authentication is not configured"})
 	}
-
-	token, err := jwt.Parse(tokenString, func(token *jwt.Token) (interface{}, error) {
-		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
-			return nil, fiber.ErrUnauthorized
+	if _, err := auth.Default.IdentifyWith(c, "oidc"); err != nil {
+		switch {
+		case errors.Is(err, models.ErrAccountDisabled):
+			return c.Status(fiber.StatusForbidden).JSON(fiber.Map{"error": "Account is disabled"})
+		case errors.Is(err, auth.ErrUnknownProvider):
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "OIDC is not configured"})
 		}
-		return []byte(jwtSecret), nil
-	})
-	if err != nil || !token.Valid {
 		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"error": "Invalid or expired token"})
 	}
+	return c.Next()
+}
 
-	if claims, ok := token.Claims.(jwt.MapClaims); ok {
-		c.Locals("jwt_claims", claims)
-		if u, ok := claims["username"].(string); ok {
-			c.Locals("username", u)
+// AuthMiddleware resolves the caller from an API key, a Bearer token or the
+// login session (auth.Service.Identify) and blocks requests without one
+func AuthMiddleware(c *fiber.Ctx) error {
+	if auth.Default == nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Authentication is not configured"})
+	}
+	if _, err := auth.Default.Identify(c); err != nil {
+		switch {
+		case errors.Is(err, models.ErrAccountDisabled):
+			return c.Status(fiber.StatusForbidden).JSON(fiber.Map{"error": "Account is disabled"})
+		case errors.Is(err, auth.ErrAPIKeyScope):
+			return c.Status(fiber.StatusForbidden).JSON(fiber.Map{"error": err.Error()})
 		}
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"error": "Not logged in or token expired"})
 	}
-
 	return c.Next()
 }
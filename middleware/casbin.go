@@ -1,26 +1,41 @@
 package middleware
 
 import (
-	"backend-meta-data/models"
+	"backend-meta-data/auth"
 	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
 	"log"
+	"sync"
 
 	"github.com/casbin/casbin/v2"
 	"github.com/casbin/casbin/v2/util"
 	gormadapter "github.com/casbin/gorm-adapter/v3"
 	"github.com/gofiber/fiber/v2"
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
 
 var Enforcer *casbin.Enforcer
 
-// InitCasbin sets up the Casbin enforcer with GORM adapter and seeds base roles/policies.
+// CasbinModelPath is the Casbin model loaded by InitCasbin
+const CasbinModelPath = "config/casbin_model.conf"
+
+// enforcerMu guards Enforcer: enforcing and reading take the read lock,
+// swapping it and changing policies the write lock
+var enforcerMu sync.RWMutex
+
+// InitCasbin sets up the Casbin enforcer with GORM adapter. Base roles/policies are
+// created by seeders.CasbinPolicySeeder (`fibernova db:seed`). Calling it again
+// reloads the model file and policies; Enforcer is only replaced on success.
 func InitCasbin(db *gorm.DB) error {
 	adapter, err := gormadapter.NewAdapterByDB(db)
 	if err != nil {
 		return err
 	}
-	e, err := casbin.NewEnforcer("config/casbin_model.conf", adapter)
+	e, err := casbin.NewEnforcer(CasbinModelPath, adapter)
 	if err != nil {
 		return err
 	}
@@ -48,52 +63,137 @@ func InitCasbin(db *gorm.DB) error {
 		return util.RegexMatch(s, p), nil
 	})
 
+	// Load under the lock so a role change saved meanwhile is not lost with the old enforcer
+	enforcerMu.Lock()
+	defer enforcerMu.Unlock()
+	digest, err := policyDigest(db)
+	if err != nil {
+		return err
+	}
 	if err := e.LoadPolicy(); err != nil {
 		return err
 	}
 	e.EnableAutoSave(true)
+	Enforcer = e
+	loadedDigest = digest
+	return nil
+}
+
+// loadedDigest is the policyDigest of the rules Enforcer last loaded; guarded by enforcerMu
+var loadedDigest string
 
-	// Seed base roles and policies if missing
-	seed := [][]string{
-		// Root: full access
-		{"p", "Root", "/api/*", "(GET|POST|PUT|PATCH|DELETE|OPTIONS)"},
-		// Admin: manage core resources
-		{"p", "Admin", "/api/users*", "(GET|POST|PUT|PATCH|DELETE)"},
-		{"p", "Admin", "/api/instrument-types*", "(GET|POST|PUT|PATCH|DELETE)"},
-		{"p", "Admin", "/api/*", "GET"},
-		// Inspector: read-only plus submit inspection forms
-		{"p", "Inspector", "/api/inspection-forms*", "(GET|POST)"},
-		{"p", "Inspector", "/api/*", "GET"},
-		// All authenticated users can update their own profile
-		{"p", "*", "/api/users/profile", "PATCH"},
+// policyDigest hashes every stored rule, so a change by another process shows
+// up even when the row count stays the same
+func policyDigest(db *gorm.DB) (string, error) {
+	var rules []gormadapter.CasbinRule
+	if err := db.Table(casbinRuleTable).Order("id").Find(&rules).Error; err != nil {
+		return "", err
 	}
-	for _, rule := range seed {
-		_, _ = e.AddPolicy(rule[1], rule[2], rule[3])
+	h := sha256.New()
+	for _, r := range rules {
+		fmt.Fprintf(h, "%q %q %q %q %q %q %q\n", r.Ptype, r.V0, r.V1, r.V2, r.V3, r.V4, r.V5)
 	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
 
-	Enforcer = e
+// ReloadChangedPolicies re-reads the policies when casbin_rule no longer
+// matches what was loaded, e.g. after fibernova ldap:sync changed a role, and
+// reports whether it did
+func ReloadChangedPolicies(db *gorm.DB) (bool, error) {
+	enforcerMu.Lock()
+	defer enforcerMu.Unlock()
+	if Enforcer == nil {
+		return false, nil
+	}
+	digest, err := policyDigest(db)
+	if err != nil || digest == loadedDigest {
+		return false, err
+	}
+	if err := Enforcer.LoadPolicy(); err != nil {
+		return false, err
+	}
+	loadedDigest = digest
+	return true, nil
+}
+
+// EnsureCasbin runs InitCasbin unless an enforcer is already loaded
+func EnsureCasbin(db *gorm.DB) error {
+	enforcerMu.RLock()
+	loaded := Enforcer != nil
+	enforcerMu.RUnlock()
+	if loaded {
+		return nil
+	}
+	return InitCasbin(db)
+}
+
+// AddPolicies adds the given sub/obj/act rules, skipping those already present
+func AddPolicies(rules [][]string) error {
+	enforcerMu.Lock()
+	defer enforcerMu.Unlock()
+	if Enforcer == nil {
+		return nil
+	}
+	for _, rule := range rules {
+		if _, err := Enforcer.AddPolicy(rule[0], rule[1], rule[2]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// RemovePolicies removes the given sub/obj/act rules if present
+func RemovePolicies(rules [][]string) error {
+	enforcerMu.Lock()
+	defer enforcerMu.Unlock()
+	if Enforcer == nil {
+		return nil
+	}
+	for _, rule := range rules {
+		if _, err := Enforcer.RemovePolicy(rule[0], rule[1], rule[2]); err != nil {
+			return err
+		}
+	}
 	return nil
 }
 
+// Policies returns all "p" rules
+func Policies() ([][]string, error) {
+	enforcerMu.RLock()
+	defer enforcerMu.RUnlock()
+	if Enforcer == nil {
+		return [][]string{}, nil
+	}
+	return Enforcer.GetPolicy()
+}
+
 // CasbinMiddleware enforces RBAC on incoming requests.
 func CasbinMiddleware() fiber.Handler {
 	return func(c *fiber.Ctx) error {
-		if Enforcer == nil {
+		enforcerMu.RLock()
+		e := Enforcer
+		enforcerMu.RUnlock()
+		if e == nil {
 			return c.Next()
 		}
-		// Resolve subject from session
-		sess, err := models.Store.Get(c)
-		if err != nil {
+		// Same caller as AuthMiddleware; Identify reuses its result when it ran first
+		if auth.Default == nil {
 			return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"error": "unauthorized"})
 		}
-		sub, _ := sess.Get("username").(string)
-		if sub == "" {
+		user, err := auth.Default.Identify(c)
+		if errors.Is(err, auth.ErrAPIKeyScope) {
+			return c.Status(fiber.StatusForbidden).JSON(fiber.Map{"error": "forbidden"})
+		}
+		if err != nil {
 			return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"error": "unauthorized"})
 		}
+		sub := user.Username
 		obj := string(c.OriginalURL())
 		act := string(c.Method())
 
-		ok, err := Enforcer.Enforce(sub, obj, act)
+		enforcerMu.RLock()
+		ok, err := e.Enforce(sub, obj, act)
+		enforcerMu.RUnlock()
 		if err != nil {
 			log.Printf("casbin enforce error: %v", err)
 			return c.Status(fiber.StatusForbidden).JSON(fiber.Map{"error": "forbidden"})
@@ -105,11 +205,136 @@ func CasbinMiddleware() fiber.Handler {
 	}
 }
 
+// Permission is one object/action pair a subject may access
+type Permission struct {
+	Object string `json:"object"`
+	Action string `json:"action"`
+}
+
+// UserAccess returns the roles username holds (directly or inherited) and the
+// permissions they grant, deduplicated
+func UserAccess(username string) ([]string, []Permission, error) {
+	enforcerMu.RLock()
+	defer enforcerMu.RUnlock()
+	e := Enforcer
+	if e == nil {
+		return []string{}, []Permission{}, nil
+	}
+	roles, err := e.GetImplicitRolesForUser(username)
+	if err != nil {
+		return nil, nil, err
+	}
+	rules, err := e.GetImplicitPermissionsForUser(username)
+	if err != nil {
+		return nil, nil, err
+	}
+	perms := make([]Permission, 0, len(rules))
+	seen := map[Permission]bool{}
+	for _, r := range rules {
+		if len(r) < 3 {
+			continue
+		}
+		p := Permission{Object: r[1], Action: r[2]}
+		if !seen[p] {
+			seen[p] = true
+			perms = append(perms, p)
+		}
+	}
+	return roles, perms, nil
+}
+
+// casbinRuleTable is where gormadapter.NewAdapterByDB keeps the policies
+const casbinRuleTable = "casbin_rule"
+
+// baseRoles are the roles stored in Users.Role; a user holds one of them
+var baseRoles = []string{"Root", "Admin", "Inspector"}
+
+// ReplaceRole makes role the user's only base role in Casbin; roles outside
+// baseRoles are kept.
+func ReplaceRole(ctx context.Context, username, role string) error {
+	enforcerMu.Lock()
+	defer enforcerMu.Unlock()
+	e := Enforcer
+	if e == nil {
+		return nil
+	}
+	for _, r := range baseRoles {
+		if r == role {
+			continue
+		}
+		if _, err := e.RemoveGroupingPolicy(username, r); err != nil {
+			return err
+		}
+	}
+	_, err := e.AddGroupingPolicy(username, role)
+	return err
+}
+
 // AssignRole assigns a role to a username in Casbin policies.
 func AssignRole(ctx context.Context, username, role string) error {
-	if Enforcer == nil {
+	enforcerMu.Lock()
+	defer enforcerMu.Unlock()
+	e := Enforcer
+	if e == nil {
 		return nil
 	}
-	_, err := Enforcer.AddGroupingPolicy(username, role)
+	_, err := e.AddGroupingPolicy(username, role)
 	return err
 }
+
+// GroupInTx writes username's grouping into role with tx, so it commits or
+// rolls back with the caller's other changes; AssignRole then loads it into
+// the enforcer.
+func GroupInTx(tx *gorm.DB, username, role string) error {
+	rule := gormadapter.CasbinRule{Ptype: "g", V0: username, V1: role}
+	return tx.Table(casbinRuleTable).Clauses(clause.OnConflict{DoNothing: true}).Create(&rule).Error
+}
+
+// RenameInTx moves the rules naming oldName as subject to newName with tx;
+// RenameSubject then applies the same change to the enforcer.
+func RenameInTx(tx *gorm.DB, oldName, newName string) error {
+	return tx.Table(casbinRuleTable).Where("ptype IN ? AND v0 = ?", []string{"p", "g"}, oldName).Update("v0", newName).Error
+}
+
+// RenameSubject moves oldName's rules to newName in the loaded policies; the
+// stored rows were already moved by RenameInTx
+func RenameSubject(oldName, newName string) error {
+	enforcerMu.Lock()
+	defer enforcerMu.Unlock()
+	e := Enforcer
+	if e == nil {
+		return nil
+	}
+	e.EnableAutoSave(false)
+	defer e.EnableAutoSave(true)
+	groupings, err := e.GetFilteredGroupingPolicy(0, oldName)
+	if err != nil {
+		return err
+	}
+	for _, rule := range groupings {
+		if _, err := e.RemoveGroupingPolicy(rule); err != nil {
+			return err
+		}
+		if _, err := e.AddGroupingPolicy(renamed(rule, newName)); err != nil {
+			return err
+		}
+	}
+	policies, err := e.GetFilteredPolicy(0, oldName)
+	if err != nil {
+		return err
+	}
+	for _, rule := range policies {
+		if _, err := e.RemovePolicy(rule); err != nil {
+			return err
+		}
+		if _, err := e.AddPolicy(renamed(rule, newName)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// renamed returns a copy of rule with subject as its first field
+func renamed(rule []string, subject string) []string {
+	return append([]string{subject}, rule[1:]...)
+}
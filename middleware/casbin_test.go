@@ -0,0 +1,83 @@
+package middleware
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/casbin/casbin/v2"
+	gormadapter "github.com/casbin/gorm-adapter/v3"
+	"github.com/glebarez/sqlite"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+func openTestDB(t *testing.T, path string) *gorm.DB {
+	t.Helper()
+	db, err := gorm.Open(sqlite.Open(path), &gorm.Config{Logger: logger.Discard})
+	if err != nil {
+		t.Fatal(err)
+	}
+	sqlDB, err := db.DB()
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { sqlDB.Close() })
+	return db
+}
+
+func TestDemotionByAnotherProcessIsReloaded(t *testing.T) {
+	// InitCasbin loads config/casbin_model.conf from the project root
+	t.Chdir("..")
+	path := filepath.Join(t.TempDir(), "app.db")
+	server := openTestDB(t, path)
+	if err := InitCasbin(server); err != nil {
+		t.Fatal(err)
+	}
+	if err := AddPolicies([][]string{{"Admin", "/api/users*", "GET"}}); err != nil {
+		t.Fatal(err)
+	}
+	if err := AssignRole(t.Context(), "dave", "Admin"); err != nil {
+		t.Fatal(err)
+	}
+	allowed := func() bool {
+		t.Helper()
+		ok, err := Enforcer.Enforce("dave", "/api/users", "GET")
+		if err != nil {
+			t.Fatal(err)
+		}
+		return ok
+	}
+	if !allowed() {
+		t.Fatal("Admin dave was refused before the demotion")
+	}
+
+	// fibernova ldap:sync demotes dave with its own connection and enforcer
+	adapter, err := gormadapter.NewAdapterByDB(openTestDB(t, path))
+	if err != nil {
+		t.Fatal(err)
+	}
+	other, err := casbin.NewEnforcer(CasbinModelPath, adapter)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := other.RemoveGroupingPolicy("dave", "Admin"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := other.AddGroupingPolicy("dave", "Inspector"); err != nil {
+		t.Fatal(err)
+	}
+
+	changed, err := ReloadChangedPolicies(server)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !changed {
+		t.Fatal("the demotion was not noticed")
+	}
+	if allowed() {
+		t.Fatal("demoted dave still reads /api/users")
+	}
+	if changed, err := ReloadChangedPolicies(server); err != nil || changed {
+		t.Fatalf("second poll: changed=%v err=%v, want no reload", changed, err)
+	}
+}
@@ -0,0 +1,33 @@
+package middleware
+
+import (
+	"sync/atomic"
+
+	"backend-meta-data/config"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/gofiber/fiber/v2/middleware/cors"
+)
+
+var corsHandler atomic.Pointer[fiber.Handler]
+
+// SetCORS builds the CORS handler from cfg; calling it again (on config
+// reload) swaps the handler for subsequent requests
+func SetCORS(cfg config.CORSConfig) {
+	h := cors.New(cors.Config{
+		AllowOrigins:     cfg.AllowOrigins,
+		AllowHeaders:     cfg.AllowHeaders,
+		AllowMethods:     cfg.AllowMethods,
+		AllowCredentials: cfg.AllowCredentials,
+	})
+	corsHandler.Store(&h)
+}
+
+// CORSMiddleware applies the handler installed by SetCORS
+func CORSMiddleware(c *fiber.Ctx) error {
+	h := corsHandler.Load()
+	if h == nil {
+		return c.Next()
+	}
+	return (*h)(c)
+}
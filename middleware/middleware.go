@@ -16,6 +16,16 @@ func InitLogger() {
 	log.SetLevel(log.InfoLevel)
 }
 
+// SetLogLevel changes the logrus level ("debug", "info", ...) at runtime
+func SetLogLevel(level string) error {
+	lvl, err := log.ParseLevel(level)
+	if err != nil {
+		return err
+	}
+	log.SetLevel(lvl)
+	return nil
+}
+
 // LoggerMiddleware logs each request using logrus
 func LoggerMiddleware(c *fiber.Ctx) error {
 	log.WithFields(log.Fields{
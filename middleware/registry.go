@@ -0,0 +1,78 @@
+package middleware
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/gofiber/fiber/v2/middleware/limiter"
+)
+
+// Named middleware registry so routes can attach middleware by name
+// (e.g. "auth", "oidc", "rbac", "throttle") instead of importing handlers directly.
+var (
+	registryMu sync.RWMutex
+	registry   = map[string]fiber.Handler{}
+	// handlerNames maps a handler's code pointer back to its registered name
+	handlerNames = map[uintptr]string{}
+)
+
+func init() {
+	Register("auth", AuthMiddleware)
+	Register("oidc", OIDCMiddleware)
+	Register("rbac", CasbinMiddleware())
+	Register("throttle", limiter.New(limiter.Config{
+		Max:        60,
+		Expiration: time.Minute,
+		LimitReached: func(c *fiber.Ctx) error {
+			return c.Status(fiber.StatusTooManyRequests).JSON(fiber.Map{"error": "too many requests"})
+		},
+	}))
+}
+
+// Register adds or replaces a named middleware
+func Register(name string, h fiber.Handler) {
+	registryMu.Lock()
+	defer registryMu.Unlock()
+	registry[name] = h
+	handlerNames[reflect.ValueOf(h).Pointer()] = name
+}
+
+// Named resolves middleware names to handlers in the given order. Unknown
+// names panic so a typo in a route file fails at boot rather than leaving a route open.
+func Named(names ...string) []fiber.Handler {
+	registryMu.RLock()
+	defer registryMu.RUnlock()
+	out := make([]fiber.Handler, 0, len(names))
+	for _, name := range names {
+		h, ok := registry[name]
+		if !ok {
+			panic(fmt.Sprintf("middleware: %q is not registered", name))
+		}
+		out = append(out, h)
+	}
+	return out
+}
+
+// NameOf returns the registered name of a handler, if any
+func NameOf(h fiber.Handler) (string, bool) {
+	registryMu.RLock()
+	defer registryMu.RUnlock()
+	name, ok := handlerNames[reflect.ValueOf(h).Pointer()]
+	return name, ok
+}
+
+// RegisteredNames lists all registered middleware names, sorted
+func RegisteredNames() []string {
+	registryMu.RLock()
+	defer registryMu.RUnlock()
+	names := make([]string, 0, len(registry))
+	for name := range registry {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
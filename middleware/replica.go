@@ -0,0 +1,57 @@
+package middleware
+
+import (
+	"strings"
+	"time"
+
+	"backend-meta-data/db"
+
+	"github.com/gofiber/fiber/v2"
+	"gorm.io/gorm"
+)
+
+const (
+	// ReadPrimaryHeader forces a request's reads onto the primary ("1" or "true")
+	ReadPrimaryHeader = "X-Read-Primary"
+	// readPrimaryCookie is set after a successful write so the client's next reads see it
+	readPrimaryCookie = "read_primary"
+	readPrimaryLocal  = "read_primary"
+
+	defaultStickyWindow = 5 * time.Second
+)
+
+// ReadPrimaryMiddleware decides per request whether ReadFrom may use a replica.
+// Reads go to the primary when the request writes (non-GET/HEAD), when it
+// sends X-Read-Primary, or when the same client wrote within window.
+func ReadPrimaryMiddleware(window time.Duration) fiber.Handler {
+	if window <= 0 {
+		window = defaultStickyWindow
+	}
+	return func(c *fiber.Ctx) error {
+		write := c.Method() != fiber.MethodGet && c.Method() != fiber.MethodHead
+		h := strings.ToLower(c.Get(ReadPrimaryHeader))
+		if write || h == "1" || h == "true" || c.Cookies(readPrimaryCookie) != "" {
+			c.Locals(readPrimaryLocal, true)
+		}
+		err := c.Next()
+		if write && err == nil && c.Response().StatusCode() < fiber.StatusBadRequest {
+			c.Cookie(&fiber.Cookie{
+				Name:     readPrimaryCookie,
+				Value:    "1",
+				Expires:  time.Now().Add(window),
+				HTTPOnly: true,
+				SameSite: fiber.CookieSameSiteLaxMode,
+			})
+		}
+		return err
+	}
+}
+
+// ReadFrom returns the handle a read-only query should use for this request:
+// a replica by default, or the primary when ReadPrimaryMiddleware asked for it
+func ReadFrom(c *fiber.Ctx, gormDB *gorm.DB) *gorm.DB {
+	if primary, _ := c.Locals(readPrimaryLocal).(bool); primary {
+		return gormDB
+	}
+	return db.Replica(gormDB)
+}
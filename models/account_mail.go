@@ -0,0 +1,38 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// AccountMailSend records when a user was last sent an account email of one
+// purpose (password_reset, verify_email), so every instance spaces them out
+type AccountMailSend struct {
+	UserID  uint      `gorm:"primaryKey;autoIncrement:false"`
+	Purpose string    `gorm:"primaryKey;size:40"`
+	SentAt  time.Time `gorm:"not null"`
+}
+
+func (AccountMailSend) TableName() string { return "AccountMailSends" }
+
+// ClaimAccountMail records a purpose email to userID at now and reports true,
+// unless one was already sent within interval
+func ClaimAccountMail(db *gorm.DB, userID uint, purpose string, now time.Time, interval time.Duration) (bool, error) {
+	allowed := false
+	err := db.Transaction(func(tx *gorm.DB) error {
+		var row AccountMailSend
+		err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Where("user_id = ? AND purpose = ?", userID, purpose).Limit(1).Find(&row).Error
+		if err != nil {
+			return err
+		}
+		if row.UserID != 0 && now.Sub(row.SentAt) < interval {
+			return nil
+		}
+		allowed = true
+		return tx.Save(&AccountMailSend{UserID: userID, Purpose: purpose, SentAt: now}).Error
+	})
+	return allowed, err
+}
@@ -0,0 +1,66 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// APIKey is a personal access token or service key acting as its user. Only
+// the SHA-256 hash of the key is stored; Prefix identifies it in listings.
+type APIKey struct {
+	ID         uint       `gorm:"primaryKey" json:"id"`
+	UserID     uint       `gorm:"index;not null" json:"user_id"`
+	Name       string     `gorm:"size:100;not null" json:"name"`
+	Prefix     string     `gorm:"size:16;not null" json:"prefix"`
+	KeyHash    string     `gorm:"size:64;uniqueIndex;not null" json:"-"`
+	Scopes     string     `gorm:"size:500;not null" json:"scopes"` // comma separated resource:read|write, see auth.ScopeAllows
+	ExpiresAt  time.Time  `gorm:"index" json:"expires_at"`
+	LastUsedAt *time.Time `json:"last_used_at"`
+	LastUsedIP string     `gorm:"size:45" json:"last_used_ip"`
+	RevokedAt  *time.Time `json:"revoked_at,omitempty"`
+	CreatedAt  time.Time  `gorm:"autoCreateTime" json:"created_at"`
+}
+
+func (APIKey) TableName() string { return "APIKeys" }
+
+// CreateAPIKey stores a new key
+func CreateAPIKey(db *gorm.DB, k *APIKey) error {
+	return db.Create(k).Error
+}
+
+// FindAPIKey looks a key up by its hash
+func FindAPIKey(db *gorm.DB, hash string) (*APIKey, error) {
+	var k APIKey
+	if err := db.Where("key_hash = ?", hash).First(&k).Error; err != nil {
+		return nil, err
+	}
+	return &k, nil
+}
+
+// ListAPIKeys returns userID's keys, newest first, including revoked and expired ones
+func ListAPIKeys(db *gorm.DB, userID uint) ([]APIKey, error) {
+	keys := []APIKey{}
+	err := db.Where("user_id = ?", userID).Order("id DESC").Find(&keys).Error
+	return keys, err
+}
+
+// RevokeAPIKey revokes key id of userID; gorm.ErrRecordNotFound when the user
+// has no such active key
+func RevokeAPIKey(db *gorm.DB, userID, id uint, now time.Time) error {
+	res := db.Model(&APIKey{}).Where("id = ? AND user_id = ? AND revoked_at IS NULL", id, userID).
+		Update("revoked_at", now)
+	if res.Error != nil {
+		return res.Error
+	}
+	if res.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound
+	}
+	return nil
+}
+
+// TouchAPIKey records a use of the key
+func TouchAPIKey(db *gorm.DB, id uint, ip string, now time.Time) error {
+	return db.Model(&APIKey{}).Where("id = ?", id).
+		Updates(map[string]any{"last_used_at": now, "last_used_ip": ip}).Error
+}
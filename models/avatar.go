@@ -14,7 +14,7 @@ import (
 
 type UserAvatar struct {
 	UserID      uint      `gorm:"primaryKey;index" json:"user_id"`
-	Data        []byte    `gorm:"type:longblob" json:"-"`
+	Data        []byte    `json:"-"`
 	ContentType string    `gorm:"size:100" json:"content_type"`
 	Size        int64     `json:"size"`
 	UpdatedAt   time.Time `gorm:"autoUpdateTime" json:"updated_at"`
@@ -23,6 +23,9 @@ type UserAvatar struct {
 
 func (UserAvatar) TableName() string { return "UserAvatars" }
 
+// MaxAvatarSize is the largest avatar UpsertUserAvatar accepts
+const MaxAvatarSize = 2 << 20
+
 var allowedAvatarMIMEs = map[string]struct{}{
 	"image/png":  {},
 	"image/jpeg": {},
@@ -34,6 +37,9 @@ func UpsertUserAvatar(db *gorm.DB, userID uint, data []byte, contentType string)
 	if len(data) == 0 {
 		return fiber.NewError(fiber.StatusBadRequest, "avatar image is required")
 	}
+	if len(data) > MaxAvatarSize {
+		return fiber.NewError(fiber.StatusRequestEntityTooLarge, "avatar must be at most 2 MB")
+	}
 	ct := contentType
 	if ct == "" {
 		ct = http.DetectContentType(data)
@@ -58,3 +64,14 @@ func GetUserAvatar(db *gorm.DB, userID uint) (*UserAvatar, error) {
 	}
 	return &ua, nil
 }
+
+// GetUserAvatarInfo retrieves a user's avatar metadata without the image
+func GetUserAvatarInfo(db *gorm.DB, userID uint) (*UserAvatar, error) {
+	var ua UserAvatar
+	err := db.Select("user_id", "content_type", "size", "updated_at", "created_at").
+		First(&ua, "user_id = ?", userID).Error
+	if err != nil {
+		return nil, err
+	}
+	return &ua, nil
+}
@@ -23,10 +23,13 @@ func (Configuration) TableName() string {
 	return "Configurations"
 }
 
+// "key" is a reserved word in MySQL, so queries go through GORM clauses which
+// quote the column for the active dialect instead of hand-written backticks.
+
 // GetConfigByKey retrieves a single configuration by its key
 func GetConfigByKey(db *gorm.DB, key string) (*Configuration, error) {
 	var cfg Configuration
-	if err := db.Where("`key` = ?", key).First(&cfg).Error; err != nil {
+	if err := db.Where(&Configuration{Key: key}).First(&cfg).Error; err != nil {
 		return nil, err
 	}
 	return &cfg, nil
@@ -37,9 +40,9 @@ func ListConfigs(db *gorm.DB, prefix string) ([]Configuration, error) {
 	var items []Configuration
 	q := db.Model(&Configuration{})
 	if prefix != "" {
-		q = q.Where("`key` LIKE ?", prefix+"%")
+		q = q.Where(clause.Like{Column: clause.Column{Name: "key"}, Value: prefix + "%"})
 	}
-	if err := q.Order("`key` asc").Find(&items).Error; err != nil {
+	if err := q.Order(clause.OrderByColumn{Column: clause.Column{Name: "key"}}).Find(&items).Error; err != nil {
 		return nil, err
 	}
 	return items, nil
@@ -47,7 +50,7 @@ func ListConfigs(db *gorm.DB, prefix string) ([]Configuration, error) {
 
 // SetConfig updates an existing configuration by key or creates it if missing
 func SetConfig(db *gorm.DB, key, value, description string) error {
-	res := db.Model(&Configuration{}).Where("`key` = ?", key).Updates(map[string]interface{}{
+	res := db.Model(&Configuration{}).Where(&Configuration{Key: key}).Updates(map[string]interface{}{
 		"value":       value,
 		"description": description,
 	})
@@ -68,3 +71,8 @@ func UpsertConfig(db *gorm.DB, cfg *Configuration) error {
 		DoUpdates: clause.AssignmentColumns([]string{"value", "description", "updated_at"}),
 	}).Create(cfg).Error
 }
+
+// DeleteConfig removes a configuration by key
+func DeleteConfig(db *gorm.DB, key string) error {
+	return db.Where(&Configuration{Key: key}).Delete(&Configuration{}).Error
+}
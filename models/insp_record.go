@@ -21,11 +21,11 @@ type InspectionRecord struct {
 	SubmittedByID *uint           `gorm:"index:idx_insp_forms_submitter" json:"submitted_by_id,omitempty"`
 	SubmittedBy   *User           `gorm:"constraint:OnUpdate:CASCADE,OnDelete:SET NULL;" json:"-"`
 	VisitDate     time.Time       `gorm:"index:idx_insp_forms_visit_date" json:"visit_date"`
-	Status        string          `gorm:"type:ENUM('draft','submitted','approved','rejected');default:'submitted';index:idx_insp_forms_status" json:"status"`
+	Status        string          `gorm:"size:20;default:'submitted';index:idx_insp_forms_status" json:"status"`
 	Title         string          `gorm:"size:200" json:"title"`
 	Remarks       string          `json:"remarks"`
 	Data          json.RawMessage `json:"data"`
-	Deleted       string          `gorm:"type:ENUM('Yes','No');default:'No'" json:"deleted"`
+	Deleted       string          `gorm:"size:3;default:'No'" json:"deleted"`
 	CreatedAt     time.Time       `gorm:"autoCreateTime" json:"created_at"`
 	UpdatedAt     time.Time       `gorm:"autoUpdateTime" json:"updated_at"`
 }
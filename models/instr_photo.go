@@ -20,7 +20,7 @@ type InstrumentPhoto struct {
 	Filename     string    `gorm:"size:255;not null" json:"filename"`
 	ContentType  string    `gorm:"size:100;not null" json:"content_type"`
 	Size         int64     `gorm:"not null" json:"size"`
-	Data         []byte    `gorm:"type:longblob;not null" json:"-"`
+	Data         []byte    `gorm:"not null" json:"-"`
 	CreatedAt    time.Time `gorm:"autoCreateTime" json:"created_at"`
 	UpdatedAt    time.Time `gorm:"autoUpdateTime" json:"updated_at"`
 }
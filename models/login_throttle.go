@@ -0,0 +1,79 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// LoginThrottle counts recent failed logins for one key, "user:<name>" or
+// "ip:<address>", so every instance sharing the database applies the same
+// backoff.
+type LoginThrottle struct {
+	Key           string     `gorm:"column:throttle_key;primaryKey;size:200" json:"key"`
+	Failures      int        `gorm:"not null;default:0" json:"failures"`
+	LastFailureAt time.Time  `json:"last_failure_at"`
+	BlockedUntil  *time.Time `gorm:"index" json:"blocked_until"`
+}
+
+func (LoginThrottle) TableName() string { return "LoginThrottles" }
+
+// FindLoginThrottles returns the rows for keys that exist
+func FindLoginThrottles(db *gorm.DB, keys ...string) ([]LoginThrottle, error) {
+	var rows []LoginThrottle
+	err := db.Where("throttle_key IN ?", keys).Find(&rows).Error
+	return rows, err
+}
+
+// RecordLoginFailure adds a failure to key, starting over when the last one is
+// older than window, and sets BlockedUntil to block(failures) from now when
+// that is positive. It returns the updated row.
+func RecordLoginFailure(db *gorm.DB, key string, now time.Time, window time.Duration, block func(failures int) time.Duration) (*LoginThrottle, error) {
+	var row LoginThrottle
+	err := db.Transaction(func(tx *gorm.DB) error {
+		err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Where("throttle_key = ?", key).Limit(1).Find(&row).Error
+		if err != nil {
+			return err
+		}
+		if row.Key == "" || now.Sub(row.LastFailureAt) > window {
+			row = LoginThrottle{Key: key}
+		}
+		row.Failures++
+		row.LastFailureAt = now
+		if d := block(row.Failures); d > 0 {
+			until := now.Add(d)
+			row.BlockedUntil = &until
+		}
+		return tx.Save(&row).Error
+	})
+	return &row, err
+}
+
+// ClearLoginFailures forgets the failures counted for keys
+func ClearLoginFailures(db *gorm.DB, keys ...string) error {
+	return db.Where("throttle_key IN ?", keys).Delete(&LoginThrottle{}).Error
+}
+
+// ListLoginThrottles returns keys with failures since since, most failures first
+func ListLoginThrottles(db *gorm.DB, since time.Time) ([]LoginThrottle, error) {
+	rows := []LoginThrottle{}
+	err := db.Where("last_failure_at > ?", since).Order("failures DESC").Find(&rows).Error
+	return rows, err
+}
+
+// PruneLoginThrottles deletes rows whose last failure is before since
+func PruneLoginThrottles(db *gorm.DB, since time.Time) (int64, error) {
+	res := db.Where("last_failure_at <= ?", since).Delete(&LoginThrottle{})
+	return res.RowsAffected, res.Error
+}
+
+// LockUser sets LockedUntil; Service.Login refuses the account until then
+func LockUser(db *gorm.DB, userID uint, until time.Time) error {
+	return db.Model(&User{}).Where("id = ?", userID).Update("locked_until", until).Error
+}
+
+// UnlockUser clears LockedUntil
+func UnlockUser(db *gorm.DB, userID uint) error {
+	return db.Model(&User{}).Where("id = ?", userID).Update("locked_until", nil).Error
+}
@@ -15,14 +15,14 @@ type MaintenanceNotice struct {
 	ID            uint       `gorm:"primaryKey" json:"id"`
 	Title         string     `gorm:"size:200;not null" json:"title"`
 	Body          string     `json:"body"`
-	Severity      string     `gorm:"type:ENUM('info','warning','critical');default:'info';index" json:"severity"`
-	Status        string     `gorm:"type:ENUM('draft','published','archived');default:'published';index" json:"status"`
-	Scope         string     `gorm:"type:ENUM('global','station','instrument');default:'global';index" json:"scope"`
+	Severity      string     `gorm:"size:10;default:'info';index" json:"severity"`
+	Status        string     `gorm:"size:10;default:'published';index" json:"status"`
+	Scope         string     `gorm:"size:10;default:'global';index" json:"scope"`
 	StationID     *uint      `gorm:"index" json:"station_id,omitempty"`
 	InstrumentID  *uint      `gorm:"index" json:"instrument_id,omitempty"`
 	EffectiveFrom *time.Time `gorm:"index" json:"effective_from,omitempty"`
 	EffectiveTo   *time.Time `gorm:"index" json:"effective_to,omitempty"`
-	Deleted       string     `gorm:"type:ENUM('Yes','No');default:'No'" json:"deleted"`
+	Deleted       string     `gorm:"size:3;default:'No'" json:"deleted"`
 	CreatedByID   *uint      `gorm:"index" json:"created_by_id,omitempty"`
 	CreatedAt     time.Time  `gorm:"autoCreateTime" json:"created_at"`
 	UpdatedAt     time.Time  `gorm:"autoUpdateTime" json:"updated_at"`
@@ -132,7 +132,8 @@ func ListMaintenanceNotices(db *gorm.DB, f MaintenanceNoticeFilter) ([]Maintenan
 		return nil, 0, err
 	}
 	var items []MaintenanceNotice
-	if err := q.Order("effective_from DESC NULLS LAST, id DESC").Limit(f.PageSize).Offset((f.Page - 1) * f.PageSize).Find(&items).Error; err != nil {
+	// NULLS LAST is not portable (MySQL, SQL Server), so sort NULLs last with a CASE
+	if err := q.Order("CASE WHEN effective_from IS NULL THEN 1 ELSE 0 END, effective_from DESC, id DESC").Limit(f.PageSize).Offset((f.Page - 1) * f.PageSize).Find(&items).Error; err != nil {
 		return nil, 0, err
 	}
 	return items, total, nil
@@ -11,7 +11,7 @@ type MaintNoticeEmail struct {
 	To           string     `gorm:"size:255" json:"to"`
 	Template     string     `gorm:"size:150" json:"template"`
 	Subject      string     `gorm:"size:255" json:"subject"`
-	Body         string     `gorm:"type:longtext" json:"body"`
+	Body         string     `json:"body"`
 	SentBy       string     `gorm:"size:100;index" json:"sent_by"`
 	SentAt       time.Time  `json:"sent_at"`
 	CreatedAt    time.Time  `gorm:"autoCreateTime" json:"created_at"`
@@ -15,10 +15,10 @@ type MaintNoticeTemplate struct {
 	Name        string    `gorm:"size:150;uniqueIndex;not null" json:"name"` // e.g. default.html
 	Label       string    `gorm:"size:200;not null" json:"label"`
 	Description string    `json:"description"`
-	Engine      string    `gorm:"type:ENUM('html','quill','gohtml');default:'html'" json:"engine"`
-	Content     string    `gorm:"type:longtext" json:"content"`
+	Engine      string    `gorm:"size:10;default:'html'" json:"engine"`
+	Content     string    `json:"content"`
 	Active      bool      `gorm:"default:true;index" json:"active"`
-	Deleted     string    `gorm:"type:ENUM('Yes','No');default:'No';index" json:"deleted"`
+	Deleted     string    `gorm:"size:3;default:'No';index" json:"deleted"`
 	Version     int       `gorm:"default:1" json:"version"`
 	CreatedByID *uint     `gorm:"index" json:"created_by_id,omitempty"`
 	UpdatedByID *uint     `gorm:"index" json:"updated_by_id,omitempty"`
@@ -65,7 +65,7 @@ func UpsertMaintNoticeTemplate(db *gorm.DB, t *MaintNoticeTemplate) error {
 
 func GetMaintNoticeTemplateByName(db *gorm.DB, name string) (*MaintNoticeTemplate, error) {
 	var t MaintNoticeTemplate
-	if err := db.Where("name = ? AND deleted = 'No' AND active = 1", name).First(&t).Error; err != nil {
+	if err := db.Where("name = ? AND deleted = 'No' AND active = ?", name, true).First(&t).Error; err != nil {
 		return nil, err
 	}
 	return &t, nil
@@ -73,7 +73,7 @@ func GetMaintNoticeTemplateByName(db *gorm.DB, name string) (*MaintNoticeTemplat
 
 func ListMaintNoticeTemplates(db *gorm.DB) ([]MaintNoticeTemplate, error) {
 	var list []MaintNoticeTemplate
-	if err := db.Where("deleted = 'No' AND active = 1").Order("label ASC").Find(&list).Error; err != nil {
+	if err := db.Where("deleted = 'No' AND active = ?", true).Order("label ASC").Find(&list).Error; err != nil {
 		return nil, err
 	}
 	return list, nil
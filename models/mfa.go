@@ -0,0 +1,107 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// MFARecoveryCode is a single-use code that replaces a TOTP code when the
+// authenticator is lost; only its SHA-256 hash is stored
+type MFARecoveryCode struct {
+	ID        uint       `gorm:"primaryKey" json:"id"`
+	UserID    uint       `gorm:"index;not null" json:"user_id"`
+	CodeHash  string     `gorm:"size:64;not null" json:"-"`
+	UsedAt    *time.Time `json:"used_at,omitempty"`
+	CreatedAt time.Time  `gorm:"autoCreateTime" json:"created_at"`
+}
+
+func (MFARecoveryCode) TableName() string { return "MFARecoveryCodes" }
+
+// SetMFASecret stores a pending secret; MFA stays off until EnableMFA
+func SetMFASecret(db *gorm.DB, user *User, secret string) error {
+	if err := db.Model(user).Updates(map[string]any{"mfa_secret": secret, "mfa_enabled": false, "mfa_last_step": 0}).Error; err != nil {
+		return err
+	}
+	user.MFASecret, user.MFAEnabled, user.MFALastStep = secret, false, 0
+	return nil
+}
+
+// EnableMFA turns MFA on and replaces the user's recovery codes with hashes
+func EnableMFA(db *gorm.DB, user *User, step int64, codeHashes []string) error {
+	return db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(user).Updates(map[string]any{"mfa_enabled": true, "mfa_last_step": step}).Error; err != nil {
+			return err
+		}
+		if err := ReplaceRecoveryCodes(tx, user.ID, codeHashes); err != nil {
+			return err
+		}
+		user.MFAEnabled, user.MFALastStep = true, step
+		return nil
+	})
+}
+
+// DisableMFA clears the secret and deletes the recovery codes
+func DisableMFA(db *gorm.DB, user *User) error {
+	return db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(user).Updates(map[string]any{"mfa_secret": "", "mfa_enabled": false, "mfa_last_step": 0}).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("user_id = ?", user.ID).Delete(&MFARecoveryCode{}).Error; err != nil {
+			return err
+		}
+		user.MFASecret, user.MFAEnabled, user.MFALastStep = "", false, 0
+		return nil
+	})
+}
+
+// UseMFAStep records the TOTP step just accepted; it fails with
+// ErrInvalidCredentials when a concurrent request used the same or a later step
+func UseMFAStep(db *gorm.DB, user *User, step int64) error {
+	res := db.Model(&User{}).Where("id = ? AND mfa_last_step < ?", user.ID, step).Update("mfa_last_step", step)
+	if res.Error != nil {
+		return res.Error
+	}
+	if res.RowsAffected == 0 {
+		return ErrInvalidCredentials
+	}
+	user.MFALastStep = step
+	return nil
+}
+
+// ReplaceRecoveryCodes deletes userID's recovery codes and stores new hashes
+func ReplaceRecoveryCodes(db *gorm.DB, userID uint, codeHashes []string) error {
+	if err := db.Where("user_id = ?", userID).Delete(&MFARecoveryCode{}).Error; err != nil {
+		return err
+	}
+	codes := make([]MFARecoveryCode, len(codeHashes))
+	for i, h := range codeHashes {
+		codes[i] = MFARecoveryCode{UserID: userID, CodeHash: h}
+	}
+	if len(codes) == 0 {
+		return nil
+	}
+	return db.Create(&codes).Error
+}
+
+// UseRecoveryCode marks the unused code with hash as used; ErrInvalidCredentials
+// when there is none
+func UseRecoveryCode(db *gorm.DB, userID uint, hash string, now time.Time) error {
+	res := db.Model(&MFARecoveryCode{}).
+		Where("user_id = ? AND code_hash = ? AND used_at IS NULL", userID, hash).
+		Update("used_at", now)
+	if res.Error != nil {
+		return res.Error
+	}
+	if res.RowsAffected == 0 {
+		return ErrInvalidCredentials
+	}
+	return nil
+}
+
+// CountRecoveryCodes returns how many unused recovery codes userID has left
+func CountRecoveryCodes(db *gorm.DB, userID uint) (int64, error) {
+	var n int64
+	err := db.Model(&MFARecoveryCode{}).Where("user_id = ? AND used_at IS NULL", userID).Count(&n).Error
+	return n, err
+}
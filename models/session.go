@@ -0,0 +1,179 @@
+package models
+
+import (
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/gofiber/fiber/v2/middleware/session"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// Store holds login sessions for every auth provider (see auth.Service). It
+// starts in memory; auth.Init replaces it with InitSessionStore.
+var Store = session.New()
+
+// sessionAbsoluteTimeout ends a session this long after login however active
+// it is; 0 disables the limit
+var sessionAbsoluteTimeout time.Duration
+
+// sessionTouchInterval limits how often an active session's idle expiry is pushed back
+const sessionTouchInterval = time.Minute
+
+// Session is one stored login session, keyed by the session cookie. Data is
+// fiber's encoded session; UserID is set at login so all of a user's
+// sessions can be ended at once.
+type Session struct {
+	ID        string     `gorm:"primaryKey;size:64" json:"-"`
+	Data      []byte     `gorm:"not null" json:"-"`
+	UserID    uint       `gorm:"index" json:"user_id"`
+	ExpiresAt *time.Time `gorm:"index" json:"expires_at"`
+	CreatedAt time.Time  `gorm:"autoCreateTime" json:"created_at"`
+}
+
+func (Session) TableName() string { return "Sessions" }
+
+// SessionStorage is a fiber.Storage over the Sessions table, so every
+// instance sharing the database sees the same sessions
+type SessionStorage struct {
+	db *gorm.DB
+}
+
+// NewSessionStorage returns a storage using db
+func NewSessionStorage(db *gorm.DB) *SessionStorage {
+	return &SessionStorage{db: db}
+}
+
+// Get returns the data of an unexpired session, or nil
+func (s *SessionStorage) Get(key string) ([]byte, error) {
+	if key == "" {
+		return nil, nil
+	}
+	var row Session
+	err := s.db.Where("id = ? AND (expires_at IS NULL OR expires_at > ?)", key, time.Now()).
+		Limit(1).Find(&row).Error
+	if err != nil || row.ID == "" {
+		return nil, err
+	}
+	return row.Data, nil
+}
+
+// Set stores val under key; exp 0 never expires. UserID is left untouched.
+func (s *SessionStorage) Set(key string, val []byte, exp time.Duration) error {
+	if key == "" || len(val) == 0 {
+		return nil
+	}
+	row := Session{ID: key, Data: val}
+	if exp > 0 {
+		t := time.Now().Add(exp)
+		row.ExpiresAt = &t
+	}
+	return s.db.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "id"}},
+		DoUpdates: clause.AssignmentColumns([]string{"data", "expires_at"}),
+	}).Create(&row).Error
+}
+
+// Delete removes the session key
+func (s *SessionStorage) Delete(key string) error {
+	if key == "" {
+		return nil
+	}
+	return s.db.Where("id = ?", key).Delete(&Session{}).Error
+}
+
+// Reset deletes every session
+func (s *SessionStorage) Reset() error {
+	return s.db.Where("1 = 1").Delete(&Session{}).Error
+}
+
+// Close is a no-op; the database connection belongs to the app
+func (s *SessionStorage) Close() error { return nil }
+
+// InitSessionStore replaces Store with one kept in the Sessions table. cfg.Expiration
+// is the idle timeout; absolute limits a session's total length.
+func InitSessionStore(db *gorm.DB, cfg session.Config, absolute time.Duration) {
+	cfg.Storage = NewSessionStorage(db)
+	Store = session.New(cfg)
+	sessionAbsoluteTimeout = absolute
+}
+
+// StartSession binds a fresh session to user; the ID is regenerated so a
+// session cookie planted before login cannot be reused
+func StartSession(c *fiber.Ctx, db *gorm.DB, user *User, values map[string]any) error {
+	sess, err := Store.Get(c)
+	if err != nil {
+		return err
+	}
+	if err := sess.Regenerate(); err != nil {
+		return err
+	}
+	now := time.Now().Unix()
+	sess.Set("userID", user.ID)
+	sess.Set("username", user.Username)
+	sess.Set("loginAt", now)
+	sess.Set("seenAt", now)
+	for k, v := range values {
+		sess.Set(k, v)
+	}
+	id := sess.ID()
+	if err := sess.Save(); err != nil {
+		return err
+	}
+	return db.Model(&Session{}).Where("id = ?", id).Update("user_id", user.ID).Error
+}
+
+// SessionUserID returns the user ID of the caller's session. Sessions past the
+// absolute timeout are destroyed; active ones have their idle expiry extended
+// at most once per sessionTouchInterval.
+func SessionUserID(c *fiber.Ctx) (uint, bool, error) {
+	sess, err := Store.Get(c)
+	if err != nil {
+		return 0, false, err
+	}
+	userID, ok := sess.Get("userID").(uint)
+	if !ok {
+		return 0, false, nil
+	}
+	now := time.Now()
+	loginAt, _ := sess.Get("loginAt").(int64)
+	if sessionAbsoluteTimeout > 0 && now.After(time.Unix(loginAt, 0).Add(sessionAbsoluteTimeout)) {
+		return 0, false, sess.Destroy()
+	}
+	if seenAt, _ := sess.Get("seenAt").(int64); now.Sub(time.Unix(seenAt, 0)) >= sessionTouchInterval {
+		sess.Set("seenAt", now.Unix())
+		if err := sess.Save(); err != nil {
+			return 0, false, err
+		}
+	}
+	return userID, true, nil
+}
+
+// EndSession destroys the caller's session and clears its cookie
+func EndSession(c *fiber.Ctx) error {
+	sess, err := Store.Get(c)
+	if err != nil {
+		return err
+	}
+	return sess.Destroy()
+}
+
+// EndUserSessions ends every session and refresh token of userID and marks
+// access tokens issued before now as revoked (see User.SessionsRevokedAt)
+func EndUserSessions(db *gorm.DB, userID uint, now time.Time) error {
+	return db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("user_id = ?", userID).Delete(&Session{}).Error; err != nil {
+			return err
+		}
+		if err := RevokeUserRefreshTokens(tx, userID); err != nil {
+			return err
+		}
+		return tx.Model(&User{}).Where("id = ?", userID).Update("sessions_revoked_at", now).Error
+	})
+}
+
+// PruneExpiredSessions deletes sessions whose idle timeout has passed
+func PruneExpiredSessions(db *gorm.DB, now time.Time) (int64, error) {
+	res := db.Where("expires_at IS NOT NULL AND expires_at <= ?", now).Delete(&Session{})
+	return res.RowsAffected, res.Error
+}
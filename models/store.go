@@ -19,7 +19,7 @@ type InventoryStore struct {
 	Longitude float64   `json:"longitude"`
 	Name      string    `gorm:"size:200;not null" json:"name"`
 	Code      string    `gorm:"size:100;unique;not null;index" json:"code"`
-	Deleted   string    `gorm:"type:ENUM('Yes','No');default:'No'" json:"deleted"`
+	Deleted   string    `gorm:"size:3;default:'No'" json:"deleted"`
 	CreatedAt time.Time `gorm:"autoCreateTime" json:"created_at"`
 	UpdatedAt time.Time `gorm:"autoUpdateTime" json:"updated_at"`
 }
@@ -0,0 +1,122 @@
+package models
+
+import (
+	"errors"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// RefreshToken is one issued refresh token, stored as a SHA-256 hash. Tokens
+// rotated from the same login share a FamilyID so reuse of an old one can
+// revoke the whole chain.
+type RefreshToken struct {
+	ID           uint       `gorm:"primaryKey" json:"id"`
+	UserID       uint       `gorm:"index;not null" json:"user_id"`
+	FamilyID     string     `gorm:"size:32;index;not null" json:"family_id"`
+	TokenHash    string     `gorm:"size:64;uniqueIndex;not null" json:"-"`
+	Provider     string     `gorm:"size:20" json:"provider"`
+	IPAddress    string     `gorm:"size:45" json:"ip_address"`
+	UserAgent    string     `gorm:"size:512" json:"user_agent"`
+	ExpiresAt    time.Time  `gorm:"index" json:"expires_at"`
+	RevokedAt    *time.Time `json:"revoked_at,omitempty"`
+	ReplacedByID *uint      `json:"replaced_by_id,omitempty"`
+	CreatedAt    time.Time  `gorm:"autoCreateTime" json:"created_at"`
+}
+
+func (RefreshToken) TableName() string { return "RefreshTokens" }
+
+// RevokedToken denylists an access token's jti until the token would expire anyway
+type RevokedToken struct {
+	JTI       string    `gorm:"primaryKey;size:32" json:"jti"`
+	UserID    uint      `gorm:"index" json:"user_id"`
+	ExpiresAt time.Time `gorm:"index" json:"expires_at"`
+	CreatedAt time.Time `gorm:"autoCreateTime" json:"created_at"`
+}
+
+func (RevokedToken) TableName() string { return "RevokedTokens" }
+
+// ErrTokenRotated is returned by RotateRefreshToken when another request
+// rotated the token first
+var ErrTokenRotated = errors.New("refresh token was already used")
+
+// FindRefreshToken looks a refresh token up by its hash
+func FindRefreshToken(db *gorm.DB, hash string) (*RefreshToken, error) {
+	var t RefreshToken
+	if err := db.Where("token_hash = ?", hash).First(&t).Error; err != nil {
+		return nil, err
+	}
+	return &t, nil
+}
+
+// CreateRefreshToken stores a new refresh token
+func CreateRefreshToken(db *gorm.DB, t *RefreshToken) error {
+	return db.Create(t).Error
+}
+
+// RotateRefreshToken revokes old and stores next in its place in one
+// transaction; only one caller can rotate a given token
+func RotateRefreshToken(db *gorm.DB, old *RefreshToken, next *RefreshToken) error {
+	return db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(next).Error; err != nil {
+			return err
+		}
+		res := tx.Model(&RefreshToken{}).
+			Where("id = ? AND revoked_at IS NULL", old.ID).
+			Updates(map[string]any{"revoked_at": time.Now(), "replaced_by_id": next.ID})
+		if res.Error != nil {
+			return res.Error
+		}
+		if res.RowsAffected != 1 {
+			return ErrTokenRotated
+		}
+		return nil
+	})
+}
+
+// RevokeRefreshFamily revokes every live token of a rotation chain
+func RevokeRefreshFamily(db *gorm.DB, familyID string) error {
+	return db.Model(&RefreshToken{}).
+		Where("family_id = ? AND revoked_at IS NULL", familyID).
+		Update("revoked_at", time.Now()).Error
+}
+
+// RevokeUserRefreshTokens revokes every live refresh token of a user
+func RevokeUserRefreshTokens(db *gorm.DB, userID uint) error {
+	return db.Model(&RefreshToken{}).
+		Where("user_id = ? AND revoked_at IS NULL", userID).
+		Update("revoked_at", time.Now()).Error
+}
+
+// RevokeAccessToken adds jti to the denylist
+func RevokeAccessToken(db *gorm.DB, jti string, userID uint, expiresAt time.Time) error {
+	t := RevokedToken{JTI: jti, UserID: userID, ExpiresAt: expiresAt}
+	return db.Where(RevokedToken{JTI: jti}).FirstOrCreate(&t).Error
+}
+
+// UseToken denylists jti and reports whether this call did so, making a
+// signed token single-use even when two requests present it at once
+func UseToken(db *gorm.DB, jti string, userID uint, expiresAt time.Time) (bool, error) {
+	t := RevokedToken{JTI: jti, UserID: userID, ExpiresAt: expiresAt}
+	res := db.Clauses(clause.OnConflict{DoNothing: true}).Create(&t)
+	return res.RowsAffected == 1, res.Error
+}
+
+// IsAccessTokenRevoked reports whether jti is on the denylist
+func IsAccessTokenRevoked(db *gorm.DB, jti string) (bool, error) {
+	var n int64
+	err := db.Model(&RevokedToken{}).Where("jti = ?", jti).Count(&n).Error
+	return n > 0, err
+}
+
+// PruneExpiredTokens deletes refresh tokens and denylist entries that have
+// expired, as they can no longer be used either way
+func PruneExpiredTokens(db *gorm.DB, now time.Time) (int64, error) {
+	refresh := db.Where("expires_at < ?", now).Delete(&RefreshToken{})
+	if refresh.Error != nil {
+		return 0, refresh.Error
+	}
+	revoked := db.Where("expires_at < ?", now).Delete(&RevokedToken{})
+	return refresh.RowsAffected + revoked.RowsAffected, revoked.Error
+}
@@ -1,26 +1,52 @@
 package models
 
 import (
+	"crypto/subtle"
+	"errors"
+	"fmt"
 	"strings"
+	"sync"
 	"time"
 
+	"backend-meta-data/auth/password"
+
 	"github.com/gofiber/fiber/v2"
-	"github.com/gofiber/fiber/v2/middleware/session"
 	"gorm.io/gorm"
 )
 
-var Store = session.New()
-
 // User model
 type User struct {
-	ID        uint      `gorm:"primaryKey" json:"id"`
-	Username  string    `gorm:"unique;not null" json:"username"`
-	Password  string    `gorm:"not null" json:"password"`
-	Email     string    `gorm:"size:255" json:"email"`
-	Active    bool      `gorm:"default:true" json:"active"`
-	Deleted   string    `gorm:"type:ENUM('Yes','No');default:'No'" json:"deleted"`
-	Role      string    `gorm:"type:ENUM('Root','Admin','Inspector');default:'Inspector';index" json:"role"`
-	CreatedAt time.Time `json:"created_at"`
+	ID          uint      `gorm:"primaryKey" json:"id"`
+	Username    string    `gorm:"unique;not null" json:"username"`
+	Password    string    `gorm:"not null" json:"-"` // argon2id or bcrypt hash, see auth/password
+	Email       string    `gorm:"size:255" json:"email"`
+	DisplayName string    `gorm:"size:255" json:"display_name"`          // from LDAP or OIDC
+	Source      string    `gorm:"size:20;default:'local'" json:"source"` // local, ldap or oidc
+	Active      bool      `gorm:"default:true" json:"active"`
+	Deleted     string    `gorm:"size:3;default:'No'" json:"deleted"`
+	Role        string    `gorm:"size:20;default:'Inspector';index;check:chk_users_role,role IN ('Root','Admin','Inspector')" json:"role"`
+	CreatedAt   time.Time `json:"created_at"`
+
+	// SessionsRevokedAt is set by "log out everywhere"; access tokens issued
+	// before it are refused
+	SessionsRevokedAt *time.Time `json:"-"`
+
+	// TOTP second factor, see auth/totp. MFASecret is set at enrollment and
+	// MFAEnabled once a code confirmed it; MFALastStep stops code replay.
+	MFASecret   string `gorm:"size:64" json:"-"`
+	MFAEnabled  bool   `gorm:"default:false" json:"mfa_enabled"`
+	MFALastStep int64  `gorm:"default:0" json:"-"`
+
+	// EmailVerifiedAt is set when the user opens the link sent to Email and
+	// cleared whenever Email changes
+	EmailVerifiedAt *time.Time `json:"email_verified_at,omitempty"`
+
+	// LockedUntil is set after [auth] lockout_failures failed logins; see auth/throttle.go
+	LockedUntil *time.Time `json:"locked_until,omitempty"`
+
+	// ExternalID is the identity provider's stable id for the user (the OIDC
+	// sub); logins are matched on Source and ExternalID, not on Username
+	ExternalID string `gorm:"size:255;index" json:"-"`
 }
 
 // TableName sets the table name to 'Users' for GORM
@@ -28,8 +54,151 @@ func (User) TableName() string {
 	return "Users"
 }
 
-// CreateUser creates a new user in the database with validation and transaction
-func CreateUser(db *gorm.DB, user *User) error {
+// BeforeCreate hashes a plaintext Password so it is never stored as given
+func (u *User) BeforeCreate(tx *gorm.DB) error { return u.hashPassword() }
+
+// BeforeUpdate hashes a plaintext Password assigned before Save
+func (u *User) BeforeUpdate(tx *gorm.DB) error { return u.hashPassword() }
+
+func (u *User) hashPassword() error {
+	if u.Password == "" || password.IsHash(u.Password) {
+		return nil
+	}
+	return u.SetPassword(u.Password)
+}
+
+// SetPassword replaces u.Password with a hash of plain; the caller saves u
+func (u *User) SetPassword(plain string) error {
+	hash, err := password.Hash(plain)
+	if err != nil {
+		return err
+	}
+	u.Password = hash
+	return nil
+}
+
+// dummyHash is verified against when a login names an unknown user, so both
+// cases take about as long
+var dummyHash = sync.OnceValue(func() string {
+	h, _ := password.Hash("dummy password")
+	return h
+})
+
+var (
+	// ErrInvalidCredentials is returned by AuthenticateUser for any failed login
+	ErrInvalidCredentials = errors.New("invalid username or password")
+	// ErrAccountDisabled is returned by CanLogin for inactive or deleted users
+	ErrAccountDisabled = errors.New("account is disabled")
+	// ErrAccountLocked is returned by IsLocked while LockedUntil is in the future
+	ErrAccountLocked = errors.New("account is temporarily locked")
+)
+
+// DefaultRole is the role of accounts created without one
+const DefaultRole = "Inspector"
+
+// roleRank orders the base roles, lowest first
+var roleRank = map[string]int{"Inspector": 1, "Admin": 2, "Root": 3}
+
+// ValidRole reports whether role is one of Root, Admin or Inspector
+func ValidRole(role string) bool {
+	return roleRank[role] > 0
+}
+
+// CanManage reports whether u may act on target's account: targets whose
+// role outranks u's are refused
+func (u *User) CanManage(target *User) bool {
+	return roleRank[u.Role] >= roleRank[target.Role]
+}
+
+// Outranks reports whether u's role is strictly above target's, which acting
+// for another account (API keys, MFA resets) requires
+func (u *User) Outranks(target *User) bool {
+	return roleRank[u.Role] > roleRank[target.Role]
+}
+
+// CanGrant reports whether u may give an account role: Root grants any role,
+// everyone else only roles below their own
+func (u *User) CanGrant(role string) bool {
+	return u.Role == "Root" || roleRank[u.Role] > roleRank[role]
+}
+
+// CanLogin reports ErrAccountDisabled unless the user is active and not deleted
+func (u *User) CanLogin() error {
+	if !u.Active || u.Deleted == "Yes" {
+		return ErrAccountDisabled
+	}
+	return nil
+}
+
+// IsLocked reports ErrAccountLocked while too many failed logins lock the
+// account. Existing sessions and tokens keep working; only new logins are refused.
+func (u *User) IsLocked(now time.Time) error {
+	if u.LockedUntil != nil && now.Before(*u.LockedUntil) {
+		return ErrAccountLocked
+	}
+	return nil
+}
+
+// AuthenticateUser checks username and plain against the Users table. Legacy
+// plaintext rows and hashes made with old parameters are rehashed on success.
+func AuthenticateUser(db *gorm.DB, username, plain string) (*User, error) {
+	var user User
+	if err := db.Where("username = ?", username).First(&user).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			_, _ = password.Verify(plain, dummyHash())
+			return nil, ErrInvalidCredentials
+		}
+		return nil, err
+	}
+	if !user.CheckPassword(plain) {
+		return nil, ErrInvalidCredentials
+	}
+	if password.NeedsRehash(user.Password) {
+		if err := user.SetPassword(plain); err == nil {
+			// UpdateColumn skips the hooks and the rest of the row
+			_ = db.Model(&user).UpdateColumn("password", user.Password).Error
+		}
+	}
+	return &user, nil
+}
+
+// CheckPassword reports whether plain matches the stored password in constant time
+func (u *User) CheckPassword(plain string) bool {
+	if !password.IsHash(u.Password) {
+		// Rows written before passwords were hashed; see `fibernova users:hash-passwords`
+		_, _ = password.Verify(plain, dummyHash())
+		return u.Password != "" && subtle.ConstantTimeCompare([]byte(u.Password), []byte(plain)) == 1
+	}
+	ok, err := password.Verify(plain, u.Password)
+	return ok && err == nil
+}
+
+// HashPlaintextPasswords hashes every password that is not already a hash and
+// returns how many rows were updated
+func HashPlaintextPasswords(db *gorm.DB) (int, error) {
+	var users []User
+	if err := db.Select("id", "password").Find(&users).Error; err != nil {
+		return 0, err
+	}
+	n := 0
+	for _, u := range users {
+		if u.Password == "" || password.IsHash(u.Password) {
+			continue
+		}
+		if err := u.SetPassword(u.Password); err != nil {
+			return n, fmt.Errorf("user %d: %w", u.ID, err)
+		}
+		if err := db.Model(&User{ID: u.ID}).UpdateColumn("password", u.Password).Error; err != nil {
+			return n, fmt.Errorf("user %d: %w", u.ID, err)
+		}
+		n++
+	}
+	return n, nil
+}
+
+// CreateUser creates a new user in the database with validation and transaction;
+// inTx run in the same transaction after the insert
+func CreateUser(db *gorm.DB, user *User, inTx ...func(tx *gorm.DB) error) error {
 	// Validate required fields
 	if user.Username == "" || user.Password == "" {
 		return fiber.NewError(fiber.StatusBadRequest, "username and password are required")
@@ -61,12 +230,25 @@ func CreateUser(db *gorm.DB, user *User) error {
 	// Set created_at timestamp
 	user.CreatedAt = time.Now()
 
+	if err := user.SetPassword(user.Password); err != nil {
+		tx.Rollback()
+		return fiber.NewError(fiber.StatusBadRequest, err.Error())
+	}
+
 	// Create user
 	if err := tx.Create(user).Error; err != nil {
 		tx.Rollback()
 		return fiber.NewError(fiber.StatusInternalServerError, "failed to create user")
 	}
 
+	// Anything that must succeed with the insert, such as the Casbin grouping
+	for _, fn := range inTx {
+		if err := fn(tx); err != nil {
+			tx.Rollback()
+			return err
+		}
+	}
+
 	return tx.Commit().Error
 }
 
@@ -94,21 +276,105 @@ func FindUserByID(db *gorm.DB, userID uint) (*User, error) {
 	return &user, nil
 }
 
-// GetLoggedInUser retrieves the logged-in user model from the session
+// UpdateExternalProfile stores the email and display name reported by the
+// directory or OIDC issuer, writing only the fields that changed
+func UpdateExternalProfile(db *gorm.DB, user *User, source, email, displayName string) error {
+	updates := map[string]any{}
+	if source != "" && user.Source != source {
+		updates["source"] = source
+	}
+	if email != "" && user.Email != email {
+		updates["email"] = email
+		updates["email_verified_at"] = nil
+	}
+	if displayName != "" && user.DisplayName != displayName {
+		updates["display_name"] = displayName
+	}
+	if len(updates) == 0 {
+		return nil
+	}
+	return db.Model(user).Updates(updates).Error
+}
+
+// UpdatePassword hashes plain and writes only the password column
+func UpdatePassword(db *gorm.DB, user *User, plain string) error {
+	if err := user.SetPassword(plain); err != nil {
+		return err
+	}
+	return db.Model(user).UpdateColumn("password", user.Password).Error
+}
+
+// SetEmailVerified records that user confirmed email; it fails with
+// gorm.ErrRecordNotFound when the address changed in the meantime
+func SetEmailVerified(db *gorm.DB, user *User, email string, now time.Time) error {
+	res := db.Model(&User{}).Where("id = ? AND email = ?", user.ID, email).Update("email_verified_at", now)
+	if res.Error != nil {
+		return res.Error
+	}
+	if res.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound
+	}
+	user.EmailVerifiedAt = &now
+	return nil
+}
+
+// FindUsersByEmail returns the users with email, compared case-insensitively
+func FindUsersByEmail(db *gorm.DB, email string) ([]User, error) {
+	var users []User
+	err := db.Where("LOWER(email) = ?", strings.ToLower(email)).Find(&users).Error
+	return users, err
+}
+
+// SetUserRole updates the Role column; Casbin grouping policies are separate
+func SetUserRole(db *gorm.DB, user *User, role string) error {
+	if user.Role == role {
+		return nil
+	}
+	if err := db.Model(user).Update("role", role).Error; err != nil {
+		return err
+	}
+	user.Role = role
+	return nil
+}
+
+// FindUserByUsername retrieves a user by username
+func FindUserByUsername(db *gorm.DB, username string) (*User, error) {
+	var user User
+	if err := db.Where("username = ?", username).First(&user).Error; err != nil {
+		return nil, err
+	}
+	return &user, nil
+}
+
+// FindUserByExternalID retrieves the user that source knows as id
+func FindUserByExternalID(db *gorm.DB, source, id string) (*User, error) {
+	var user User
+	if err := db.Where("source = ? AND external_id = ?", source, id).First(&user).Error; err != nil {
+		return nil, err
+	}
+	return &user, nil
+}
+
+// GetLoggedInUser returns the user resolved by AuthMiddleware, or the one
+// named by the session's userID on routes without it
 func GetLoggedInUser(c *fiber.Ctx, db *gorm.DB) (*User, error) {
-	sess, err := Store.Get(c)
+	if user, ok := c.Locals("user").(*User); ok {
+		return user, nil
+	}
+	userID, ok, err := SessionUserID(c)
 	if err != nil {
 		return nil, err
 	}
-	userIDVal := sess.Get("userID")
-	userID, ok := userIDVal.(int)
 	if !ok {
 		return nil, fiber.ErrUnauthorized
 	}
-	user, err := FindUserByID(db, uint(userID))
+	user, err := FindUserByID(db, userID)
 	if err != nil {
 		return nil, err
 	}
+	if err := user.CanLogin(); err != nil {
+		return nil, fiber.ErrUnauthorized
+	}
 	return user, nil
 }
 
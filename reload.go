@@ -0,0 +1,162 @@
+package main
+
+import (
+	"os"
+	"os/signal"
+	"path/filepath"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"backend-meta-data/config"
+	"backend-meta-data/middleware"
+	"backend-meta-data/settings"
+
+	logrus "github.com/sirupsen/logrus"
+	"gorm.io/gorm"
+)
+
+// liveConfigKey reports whether a changed key takes effect without a restart:
+// the log level, CORS, and file keys that are read through the settings service
+func liveConfigKey(key string) bool {
+	return key == "app.log_level" || strings.HasPrefix(key, "cors.") || settings.Defined(key)
+}
+
+// reloader re-reads the config files and Casbin model/policies on SIGHUP or
+// when a watched file changes
+type reloader struct {
+	dir string
+	db  *gorm.DB
+
+	mu     sync.Mutex
+	cfg    *config.Config
+	mtimes map[string]time.Time
+}
+
+func newReloader(dir string, cfg *config.Config, db *gorm.DB) *reloader {
+	r := &reloader{dir: dir, db: db, cfg: cfg, mtimes: map[string]time.Time{}}
+	r.changedFiles() // record the current modification times
+	return r
+}
+
+// watchedFiles are the loaded config files, the environment layer (which may not
+// exist yet) and the Casbin model
+func (r *reloader) watchedFiles() []string {
+	files := append([]string(nil), r.cfg.Files...)
+	files = append(files,
+		filepath.Join(r.dir, "config.toml"),
+		filepath.Join(r.dir, "config."+r.cfg.App.Env+".toml"),
+		middleware.CasbinModelPath,
+	)
+	return files
+}
+
+// changedFiles returns the watched files whose modification time changed since the last call
+func (r *reloader) changedFiles() []string {
+	var changed []string
+	seen := map[string]bool{}
+	for _, f := range r.watchedFiles() {
+		if seen[f] {
+			continue
+		}
+		seen[f] = true
+		var mtime time.Time
+		if fi, err := os.Stat(f); err == nil {
+			mtime = fi.ModTime()
+		}
+		if prev, ok := r.mtimes[f]; ok && !prev.Equal(mtime) {
+			changed = append(changed, f)
+		}
+		r.mtimes[f] = mtime
+	}
+	return changed
+}
+
+// reloadConfig loads the config again and applies the live keys; an invalid
+// config is logged and the running one kept
+func (r *reloader) reloadConfig(reason string) {
+	next, err := config.Load(r.dir)
+	if err != nil {
+		logrus.Errorf("Config reload (%s) failed, keeping the running config: %v", reason, err)
+		return
+	}
+	changes := config.Diff(r.cfg, next)
+	if len(changes) == 0 {
+		logrus.Infof("Config reloaded (%s): no changes", reason)
+		r.cfg = next
+		return
+	}
+	if err := middleware.SetLogLevel(next.App.LogLevel); err != nil {
+		logrus.Errorf("Config reload: %v", err)
+	}
+	middleware.SetCORS(next.CORS)
+	settings.Default.SetConfig(next)
+	for _, ch := range changes {
+		if liveConfigKey(ch.Key) {
+			logrus.Infof("Config reloaded (%s): %s", reason, ch)
+		} else {
+			logrus.Warnf("Config reloaded (%s): %s takes effect after a restart", reason, ch)
+		}
+	}
+	r.cfg = next
+}
+
+// reloadCasbin rebuilds the enforcer from the model file and policies
+func (r *reloader) reloadCasbin(reason string) {
+	if err := middleware.InitCasbin(r.db); err != nil {
+		logrus.Errorf("Casbin reload (%s) failed, keeping the running policies: %v", reason, err)
+		return
+	}
+	logrus.Infof("Casbin model and policies reloaded (%s)", reason)
+}
+
+// handleSignals reloads everything on SIGHUP
+func (r *reloader) handleSignals() {
+	hup := make(chan os.Signal, 1)
+	signal.Notify(hup, syscall.SIGHUP)
+	for range hup {
+		r.mu.Lock()
+		r.reloadConfig("SIGHUP")
+		r.reloadCasbin("SIGHUP")
+		r.changedFiles()
+		r.mu.Unlock()
+	}
+}
+
+// pollPolicies reloads the Casbin policies every interval when casbin_rule was
+// changed by another process
+func (r *reloader) pollPolicies(interval time.Duration) {
+	for range time.Tick(interval) {
+		r.mu.Lock()
+		changed, err := middleware.ReloadChangedPolicies(r.db)
+		if err != nil {
+			logrus.Errorf("Casbin policy reload failed, keeping the running policies: %v", err)
+		} else if changed {
+			logrus.Infof("Casbin policies reloaded (casbin_rule changed)")
+		}
+		r.mu.Unlock()
+	}
+}
+
+// watch polls the watched files every interval
+func (r *reloader) watch(interval time.Duration) {
+	for range time.Tick(interval) {
+		r.mu.Lock()
+		var configChanged, modelChanged bool
+		for _, f := range r.changedFiles() {
+			if f == middleware.CasbinModelPath {
+				modelChanged = true
+			} else {
+				configChanged = true
+			}
+		}
+		if configChanged {
+			r.reloadConfig("file changed")
+		}
+		if modelChanged {
+			r.reloadCasbin(middleware.CasbinModelPath + " changed")
+		}
+		r.mu.Unlock()
+	}
+}
@@ -1,42 +1,62 @@
 package routes
 
 import (
+	"backend-meta-data/config"
 	"backend-meta-data/controllers"
-	"backend-meta-data/middleware"
-	"database/sql"
 
 	"github.com/gofiber/fiber/v2"
 	"gorm.io/gorm"
 )
 
 // RegisterAPIRoutes registers the /api group endpoints
-func RegisterAPIRoutes(app *fiber.App, dbConn *sql.DB, gormDB *gorm.DB) {
+func RegisterAPIRoutes(app *fiber.App, gormDB *gorm.DB, cfg *config.Config) {
 	api := app.Group("/api")
 
+	// Named groups: every route below is bound through exactly one of these,
+	// so its middleware can be audited here (and with `fibernova route:list`).
+	public := NewGroup(api)
+	protected := NewGroup(api, "auth")
+	admin := NewGroup(api, "auth", "rbac")
+
 	// Instrument Types
-	api.Post("/instrument-types", middleware.AuthMiddleware, controllers.CreateInstrumentType(gormDB))
-	api.Get("/instrument-types", controllers.ListInstrumentTypes(gormDB))
-	api.Patch("/instrument-types/:id", middleware.AuthMiddleware, controllers.UpdateInstrumentType(gormDB))
+	protected.Post("/instrument-types", controllers.CreateInstrumentType(gormDB))
+	public.Get("/instrument-types", controllers.ListInstrumentTypes(gormDB))
+	protected.Patch("/instrument-types/:id", controllers.UpdateInstrumentType(gormDB))
 
 	// Users
-	api.Post("/users", controllers.CreateUser(gormDB))
-	api.Get("/users", controllers.ListUsers(gormDB))
-	api.Patch("/users/:id", controllers.UpdateUser(gormDB))
-	api.Patch("/users/profile", middleware.AuthMiddleware, controllers.UpdateUser(gormDB))
+	admin.Post("/users", controllers.CreateUser(gormDB))
+	public.Get("/users", controllers.ListUsers(gormDB))
+	// /users/profile before /users/:id, which would otherwise match it
+	protected.Patch("/users/profile", controllers.UpdateProfile(gormDB))
+	admin.Patch("/users/:id", controllers.UpdateUser(gormDB))
+	protected.Get("/users/:id/avatar", controllers.GetUserAvatar(gormDB))
+	admin.Delete("/users/:id/mfa", controllers.ResetUserMFA(gormDB))
+	admin.Post("/users/:id/unlock", controllers.UnlockUser(gormDB))
+	admin.Get("/login-throttles", controllers.ListLoginThrottles(gormDB))
+	admin.Delete("/login-throttles/:key", controllers.ClearLoginThrottle(gormDB))
+	admin.Get("/users/:id/api-keys", controllers.ListAPIKeys(gormDB))
+	admin.Post("/users/:id/api-keys", controllers.CreateAPIKey(gormDB))
+	admin.Delete("/users/:id/api-keys/:keyId", controllers.RevokeAPIKey(gormDB))
 
 	// Stations
-	api.Post("/station/batch", controllers.StationBatch())
-	api.Get("/station", controllers.ListStations(gormDB))
+	public.Post("/station/batch", controllers.StationBatch())
+	public.Get("/station", controllers.ListStations(gormDB))
 
 	// Stores
-	api.Get("/stores", controllers.ListStores(gormDB))
-	api.Post("/stores", controllers.CreateStore(gormDB))
+	public.Get("/stores", controllers.ListStores(gormDB))
+	public.Post("/stores", controllers.CreateStore(gormDB))
 
 	// Templates
-	api.Get("/templates/maint_notice", controllers.ListMaintNoticeTemplates())
-	api.Get("/templates/maint_notice/:name", controllers.GetMaintNoticeTemplate())
-	api.Post("/maint-notices", controllers.CreateMaintNotice())
-	api.Get("/templates", controllers.ListTemplates())
-	api.Get("/stn", middleware.AuthMiddleware, controllers.ListStations(gormDB))
-	api.Get("/instruments", controllers.ListInstruments(gormDB))
+	public.Get("/templates/maint_notice", controllers.ListMaintNoticeTemplates())
+	public.Get("/templates/maint_notice/:name", controllers.GetMaintNoticeTemplate())
+	public.Post("/maint-notices", controllers.CreateMaintNotice(gormDB, cfg))
+	public.Get("/templates", controllers.ListTemplates())
+	protected.Get("/stn", controllers.ListStations(gormDB))
+	public.Get("/instruments", controllers.ListInstruments(gormDB))
+
+	// Runtime settings (Casbin: Admin may read, Root may change)
+	admin.Get("/settings", controllers.ListSettings())
+	admin.Get("/settings/:key", controllers.GetSetting())
+	admin.Put("/settings/:key", controllers.UpdateSetting())
+	admin.Delete("/settings/:key", controllers.DeleteSetting())
 }
@@ -1,18 +1,41 @@
 package routes
 
 import (
+	"backend-meta-data/config"
 	"backend-meta-data/controllers"
-	"database/sql"
 
 	"github.com/gofiber/fiber/v2"
 	"gorm.io/gorm"
 )
 
-// RegisterAuthRoutes registers authentication-related endpoints
-func RegisterAuthRoutes(app *fiber.App, dbConn *sql.DB, gormDB *gorm.DB) {
-	app.Post("/login", controllers.Login(dbConn))
-	app.Post("/logout", controllers.Logout())
-	app.Get("/me", controllers.Me(dbConn))
-	app.Get("/auth/sso", controllers.SSOController(gormDB))
-	app.Post("/auth/ad-login", controllers.ADLoginHandler)
+// RegisterAuthRoutes registers authentication-related endpoints. Every login
+// route goes through auth.Default and returns the same session and token.
+func RegisterAuthRoutes(app *fiber.App, gormDB *gorm.DB, cfg *config.Config) {
+	public := NewGroup(app)
+	protected := NewGroup(app, "auth")
+
+	public.Post("/login", controllers.Login(""))
+	public.Post("/auth/ad-login", controllers.Login("ldap"))
+	public.Get("/auth/sso", controllers.Login("sso"))
+	public.Post("/auth/oidc", controllers.Login("oidc"))
+	public.Post("/auth/mfa/enroll", controllers.MFAEnrollWithToken())
+	public.Post("/auth/mfa/verify", controllers.MFAVerify())
+	public.Post("/auth/refresh", controllers.RefreshToken())
+	public.Post("/auth/password/forgot", controllers.ForgotPassword(cfg))
+	public.Post("/auth/password/reset", controllers.ResetPassword())
+	public.Post("/auth/email/verify", controllers.VerifyEmail())
+	public.Post("/auth/logout", controllers.Logout())
+	public.Post("/logout", controllers.Logout())
+	protected.Post("/auth/logout-all", controllers.LogoutAll())
+	protected.Get("/me", controllers.Me(gormDB))
+	protected.Post("/me/email/verification", controllers.RequestEmailVerification(gormDB, cfg))
+	protected.Put("/me/avatar", controllers.UploadMyAvatar(gormDB))
+	protected.Delete("/me/avatar", controllers.DeleteMyAvatar(gormDB))
+	protected.Post("/me/mfa", controllers.EnrollMyMFA(gormDB))
+	protected.Post("/me/mfa/confirm", controllers.ConfirmMyMFA(gormDB))
+	protected.Delete("/me/mfa", controllers.DisableMyMFA(gormDB))
+	protected.Post("/me/mfa/recovery-codes", controllers.RegenerateMyRecoveryCodes(gormDB))
+	protected.Get("/me/api-keys", controllers.ListAPIKeys(gormDB))
+	protected.Post("/me/api-keys", controllers.CreateAPIKey(gormDB))
+	protected.Delete("/me/api-keys/:keyId", controllers.RevokeAPIKey(gormDB))
 }
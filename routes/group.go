@@ -0,0 +1,50 @@
+package routes
+
+import (
+	"backend-meta-data/middleware"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// Group binds routes on a router with a fixed stack of named middleware.
+// Unlike fiber's Group with handlers, the middleware only runs for routes
+// bound through this Group, so public and protected groups can share a prefix.
+type Group struct {
+	router     fiber.Router
+	middleware []string
+	handlers   []fiber.Handler
+}
+
+// NewGroup creates a Group on r that runs the named middleware before each handler
+func NewGroup(r fiber.Router, middlewareNames ...string) *Group {
+	return &Group{router: r, middleware: middlewareNames, handlers: middleware.Named(middlewareNames...)}
+}
+
+// Middleware returns the names of the middleware attached to the group
+func (g *Group) Middleware() []string { return g.middleware }
+
+func (g *Group) chain(h []fiber.Handler) []fiber.Handler {
+	out := make([]fiber.Handler, 0, len(g.handlers)+len(h))
+	out = append(out, g.handlers...)
+	return append(out, h...)
+}
+
+func (g *Group) Get(path string, h ...fiber.Handler) fiber.Router {
+	return g.router.Get(path, g.chain(h)...)
+}
+
+func (g *Group) Post(path string, h ...fiber.Handler) fiber.Router {
+	return g.router.Post(path, g.chain(h)...)
+}
+
+func (g *Group) Put(path string, h ...fiber.Handler) fiber.Router {
+	return g.router.Put(path, g.chain(h)...)
+}
+
+func (g *Group) Patch(path string, h ...fiber.Handler) fiber.Router {
+	return g.router.Patch(path, g.chain(h)...)
+}
+
+func (g *Group) Delete(path string, h ...fiber.Handler) fiber.Router {
+	return g.router.Delete(path, g.chain(h)...)
+}
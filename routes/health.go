@@ -2,19 +2,20 @@ package routes
 
 import (
 	"backend-meta-data/controllers"
-	"database/sql"
 
 	"github.com/gofiber/fiber/v2"
+	"gorm.io/gorm"
 )
 
 // RegisterHealthRoutes registers root, dbcheck and health endpoints
-func RegisterHealthRoutes(app *fiber.App, dbConn *sql.DB) {
+func RegisterHealthRoutes(app *fiber.App, gormDB *gorm.DB) {
 	app.Get("/", func(c *fiber.Ctx) error {
 		return c.SendString("Hello, World!")
 	})
 
 	app.Get("/dbcheck", func(c *fiber.Ctx) error {
-		if err := dbConn.Ping(); err != nil {
+		sqlDB, err := gormDB.DB()
+		if err != nil || sqlDB.Ping() != nil {
 			return c.Status(500).SendString("DB connection failed")
 		}
 		return c.SendString("DB connection successful")
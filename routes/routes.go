@@ -1,16 +1,16 @@
 package routes
 
 import (
-	"database/sql"
+	"backend-meta-data/config"
 
 	"github.com/gofiber/fiber/v2"
 	"gorm.io/gorm"
 )
 
 // RegisterRoutes aggregates sub-route registrations
-func RegisterRoutes(app *fiber.App, dbConn *sql.DB, gormDB *gorm.DB) {
-	RegisterHealthRoutes(app, dbConn)
-	RegisterAuthRoutes(app, dbConn, gormDB)
-	RegisterAPIRoutes(app, dbConn, gormDB)
-	RegisterExportRoutes(app, dbConn)
+func RegisterRoutes(app *fiber.App, gormDB *gorm.DB, cfg *config.Config) {
+	RegisterHealthRoutes(app, gormDB)
+	RegisterAuthRoutes(app, gormDB, cfg)
+	RegisterAPIRoutes(app, gormDB, cfg)
+	RegisterExportRoutes(app, gormDB)
 }
@@ -0,0 +1,95 @@
+package settings
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"time"
+)
+
+// Kind is the type a setting's value must parse as
+type Kind string
+
+const (
+	KindString   Kind = "string"
+	KindBool     Kind = "bool"
+	KindInt      Kind = "int"
+	KindDuration Kind = "duration"
+	KindJSON     Kind = "json"
+)
+
+// InvalidValueError is returned when a value does not parse as its setting's Kind
+type InvalidValueError struct {
+	Kind Kind
+	Err  error
+}
+
+func (e *InvalidValueError) Error() string {
+	return fmt.Sprintf("value must be a %s: %v", e.Kind, e.Err)
+}
+
+func (k Kind) check(value string) error {
+	var err error
+	switch k {
+	case KindBool:
+		_, err = strconv.ParseBool(value)
+	case KindInt:
+		_, err = strconv.Atoi(value)
+	case KindDuration:
+		_, err = time.ParseDuration(value)
+	case KindJSON:
+		if !json.Valid([]byte(value)) {
+			err = fmt.Errorf("not valid JSON")
+		}
+	}
+	if err != nil {
+		return &InvalidValueError{Kind: k, Err: err}
+	}
+	return nil
+}
+
+// Definition describes a known setting
+type Definition struct {
+	Key         string
+	Kind        Kind
+	Default     string
+	Description string
+}
+
+var definitions = map[string]Definition{}
+
+// Define registers a setting; defining a file config key (e.g. "auth.token_ttl")
+// lets the database override it at runtime
+func Define(d Definition) {
+	if !keyPattern.MatchString(d.Key) {
+		panic(fmt.Sprintf("settings: invalid key %q", d.Key))
+	}
+	if d.Kind == "" {
+		d.Kind = KindString
+	}
+	if d.Default != "" {
+		if err := d.Kind.check(d.Default); err != nil {
+			panic(fmt.Sprintf("settings: default for %s: %v", d.Key, err))
+		}
+	}
+	definitions[d.Key] = d
+}
+
+func init() {
+	Define(Definition{Key: "notice.default_template", Default: "default.html",
+		Description: "Template used for maintenance notices that do not name one"})
+	Define(Definition{Key: "notice.send_email", Kind: KindBool, Default: "true",
+		Description: "Send maintenance notice emails; when false notices are only recorded"})
+	Define(Definition{Key: "notice.default_recipients", Kind: KindJSON, Default: "[]",
+		Description: "JSON list of addresses the notice form suggests by default"})
+	Define(Definition{Key: "smtp.from_name", Kind: KindString,
+		Description: "Display name on outgoing mail (overrides [smtp] from_name)"})
+	Define(Definition{Key: "auth.token_ttl", Kind: KindDuration,
+		Description: "Lifetime of access tokens (overrides [auth] token_ttl)"})
+}
+
+// Defined reports whether key was registered with Define
+func Defined(key string) bool {
+	_, ok := definitions[key]
+	return ok
+}
@@ -0,0 +1,265 @@
+// Package settings resolves runtime settings stored in the Configurations
+// table. A value is taken from, in order of precedence:
+//
+//  1. the Configurations table (editable at runtime through /api/settings)
+//  2. the file config, when the key is registered with Define and names one of
+//     its fields (e.g. "smtp.from_name")
+//  3. the default registered with Define
+//
+// File config keys can only be read or overridden here when they are
+// registered with Define; everything else under [app], [db], [auth], [ldap],
+// [smtp] and [cors] stays file-only and is never returned, so secrets such as
+// auth.jwt_secret cannot be read through /api/settings.
+package settings
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"backend-meta-data/config"
+	"backend-meta-data/models"
+
+	log "github.com/sirupsen/logrus"
+	"gorm.io/gorm"
+)
+
+// Source tells where a resolved value came from
+type Source string
+
+const (
+	SourceDatabase Source = "database"
+	SourceFile     Source = "file"
+	SourceDefault  Source = "default"
+)
+
+// cacheTTL bounds how long another instance's writes can go unnoticed
+const cacheTTL = time.Minute
+
+var (
+	keyPattern = regexp.MustCompile(`^[a-z0-9_]+(\.[a-z0-9_-]+)+$`)
+
+	// ErrInvalidKey is returned for keys that are not dotted lower_snake_case
+	ErrInvalidKey = errors.New("setting keys look like section.name (lowercase letters, digits, _ and -)")
+	// ErrFileOnly is returned when writing a file config key that is not registered with Define
+	ErrFileOnly = errors.New("this key can only be changed in the config files")
+)
+
+// Setting is one resolved value
+type Setting struct {
+	Key         string     `json:"key"`
+	Value       string     `json:"value"`
+	Kind        Kind       `json:"kind"`
+	Source      Source     `json:"source"`
+	Description string     `json:"description"`
+	UpdatedAt   *time.Time `json:"updated_at,omitempty"`
+}
+
+// Service resolves settings with an in-process cache of the Configurations table
+type Service struct {
+	db  *gorm.DB
+	cfg *config.Config
+
+	mu       sync.RWMutex
+	rows     map[string]models.Configuration
+	loadedAt time.Time
+}
+
+// Default is the service used by handlers; set by Init at startup
+var Default *Service
+
+// New returns a service reading from db with cfg as the file layer
+func New(db *gorm.DB, cfg *config.Config) *Service {
+	return &Service{db: db, cfg: cfg}
+}
+
+// Init creates the Default service
+func Init(db *gorm.DB, cfg *config.Config) *Service {
+	Default = New(db, cfg)
+	return Default
+}
+
+// SetConfig replaces the file layer after a config reload
+func (s *Service) SetConfig(cfg *config.Config) {
+	s.mu.Lock()
+	s.cfg = cfg
+	s.mu.Unlock()
+}
+
+func (s *Service) fileConfig() *config.Config {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.cfg
+}
+
+// Invalidate drops the cache; the next read reloads the table
+func (s *Service) Invalidate() {
+	s.mu.Lock()
+	s.rows = nil
+	s.mu.Unlock()
+}
+
+func (s *Service) load() (map[string]models.Configuration, error) {
+	s.mu.RLock()
+	rows, fresh := s.rows, time.Since(s.loadedAt) < cacheTTL
+	s.mu.RUnlock()
+	if rows != nil && fresh {
+		return rows, nil
+	}
+	items, err := models.ListConfigs(s.db, "")
+	if err != nil {
+		return nil, err
+	}
+	rows = make(map[string]models.Configuration, len(items))
+	for _, it := range items {
+		rows[it.Key] = it
+	}
+	s.mu.Lock()
+	s.rows, s.loadedAt = rows, time.Now()
+	s.mu.Unlock()
+	return rows, nil
+}
+
+// Get resolves key; ok is false when no layer has a non-empty value. A nil *Service
+// (before Init) resolves registered defaults only.
+func (s *Service) Get(key string) (Setting, bool) {
+	def, defined := definitions[key]
+	st := Setting{Key: key, Kind: KindString}
+	if defined {
+		st.Kind, st.Description = def.Kind, def.Description
+	}
+	if defined {
+		st.Value, st.Source = def.Default, SourceDefault
+	}
+	if s == nil {
+		return st, st.Value != ""
+	}
+	rows, err := s.load()
+	if err != nil {
+		log.Warnf("settings: loading Configurations failed: %v", err)
+	}
+	if row, ok := rows[key]; ok {
+		at := row.UpdatedAt
+		st.Value, st.Source, st.UpdatedAt = row.Value, SourceDatabase, &at
+		if row.Description != "" {
+			st.Description = row.Description
+		}
+		return st, true
+	}
+	if cfg := s.fileConfig(); defined && cfg != nil {
+		if v, ok := cfg.Lookup(key); ok {
+			st.Value, st.Source = v, SourceFile
+			return st, true
+		}
+	}
+	return st, st.Value != ""
+}
+
+// All returns every defined setting plus any other stored row, sorted by key
+func (s *Service) All() ([]Setting, error) {
+	rows, err := s.load()
+	if err != nil {
+		return nil, err
+	}
+	keys := make(map[string]bool, len(definitions)+len(rows))
+	for k := range definitions {
+		keys[k] = true
+	}
+	for k := range rows {
+		keys[k] = true
+	}
+	out := make([]Setting, 0, len(keys))
+	for k := range keys {
+		if st, ok := s.Get(k); ok || st.Source == SourceDefault {
+			out = append(out, st)
+		}
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Key < out[j].Key })
+	return out, nil
+}
+
+// Set stores value for key after checking it against the key's Kind
+func (s *Service) Set(key, value, description string) error {
+	if !keyPattern.MatchString(key) {
+		return ErrInvalidKey
+	}
+	def, defined := definitions[key]
+	if cfg := s.fileConfig(); !defined && cfg != nil {
+		if _, fileKey := cfg.Lookup(key); fileKey {
+			return ErrFileOnly
+		}
+	}
+	if defined {
+		if err := def.Kind.check(value); err != nil {
+			return err
+		}
+		if description == "" {
+			description = def.Description
+		}
+	}
+	defer s.Invalidate()
+	return models.SetConfig(s.db, key, value, description)
+}
+
+// Delete removes the stored value so the file or default value applies again
+func (s *Service) Delete(key string) error {
+	if !keyPattern.MatchString(key) {
+		return ErrInvalidKey
+	}
+	defer s.Invalidate()
+	return models.DeleteConfig(s.db, key)
+}
+
+// String returns the value of key, or def when it is not set anywhere
+func (s *Service) String(key, def string) string {
+	if st, ok := s.Get(key); ok {
+		return st.Value
+	}
+	return def
+}
+
+// Bool returns key parsed with strconv.ParseBool, or def
+func (s *Service) Bool(key string, def bool) bool {
+	return typed(s, key, def, strconv.ParseBool)
+}
+
+// Int returns key parsed as a base-10 integer, or def
+func (s *Service) Int(key string, def int) int {
+	return typed(s, key, def, strconv.Atoi)
+}
+
+// Duration returns key parsed with time.ParseDuration ("90s", "24h"), or def
+func (s *Service) Duration(key string, def time.Duration) time.Duration {
+	return typed(s, key, def, time.ParseDuration)
+}
+
+// JSON decodes key into out; it returns false when the key is not set anywhere
+func (s *Service) JSON(key string, out any) (bool, error) {
+	st, ok := s.Get(key)
+	if !ok || st.Value == "" {
+		return false, nil
+	}
+	if err := json.Unmarshal([]byte(st.Value), out); err != nil {
+		return true, fmt.Errorf("setting %s: %w", key, err)
+	}
+	return true, nil
+}
+
+// typed parses a resolved value, logging and falling back to def when it is malformed
+func typed[T any](s *Service, key string, def T, parse func(string) (T, error)) T {
+	st, ok := s.Get(key)
+	if !ok || st.Value == "" {
+		return def
+	}
+	v, err := parse(st.Value)
+	if err != nil {
+		log.Warnf("settings: %s=%q (%s) is invalid, using %v: %v", key, st.Value, st.Source, def, err)
+		return def
+	}
+	return v
+}